@@ -0,0 +1,112 @@
+// Package attrcompress compresses large string attributes with gzip, so that items with large
+// text fields fit within DynamoDB's 400KB item size limit.
+package attrcompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// DefaultMarkerAttribute is the default name of the attribute which lists the compressed
+// attributes of an item.
+const DefaultMarkerAttribute = "_compressed"
+
+// Compressor compresses string attributes which are larger than the Threshold. The names of the
+// compressed attributes are stored in a string set in the MarkerAttribute, so that they can be
+// decompressed.
+type Compressor struct {
+	// Threshold is the size in bytes above which string attributes are compressed.
+	Threshold int
+	// Binary stores compressed values as binary attributes. If false, they are stored as base64
+	// encoded strings, which are a third larger, but can be read by tools which expect strings.
+	Binary bool
+	// MarkerAttribute is the name of the attribute which lists the compressed attributes.
+	MarkerAttribute string
+	// KeyNames are the names of the key attributes of the table and its indexes, which are never
+	// compressed, so that the item can still be found by its keys.
+	KeyNames []string
+}
+
+// Compress the large string attributes of the item in place.
+func (c Compressor) Compress(item map[string]*dynamodb.AttributeValue) error {
+	var compressed []*string
+	for name, av := range item {
+		if av.S == nil || len(*av.S) <= c.Threshold || name == c.MarkerAttribute || c.isKey(name) {
+			continue
+		}
+		b, err := gzipBytes([]byte(*av.S))
+		if err != nil {
+			return fmt.Errorf("attrcompress: %s: %w", name, err)
+		}
+		if c.Binary {
+			item[name] = &dynamodb.AttributeValue{B: b}
+		} else {
+			item[name] = &dynamodb.AttributeValue{S: aws.String(base64.StdEncoding.EncodeToString(b))}
+		}
+		compressed = append(compressed, aws.String(name))
+	}
+	if len(compressed) > 0 {
+		item[c.MarkerAttribute] = &dynamodb.AttributeValue{SS: compressed}
+	}
+	return nil
+}
+
+func (c Compressor) isKey(name string) bool {
+	for _, k := range c.KeyNames {
+		if k == name {
+			return true
+		}
+	}
+	return false
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decompress the attributes listed in the item's marker attribute in place, and remove the
+// marker attribute.
+func Decompress(item map[string]*dynamodb.AttributeValue, markerAttribute string) error {
+	marker, ok := item[markerAttribute]
+	if !ok {
+		return nil
+	}
+	for _, name := range aws.StringValueSlice(marker.SS) {
+		av, ok := item[name]
+		if !ok {
+			continue
+		}
+		b := av.B
+		if av.S != nil {
+			var err error
+			if b, err = base64.StdEncoding.DecodeString(*av.S); err != nil {
+				return fmt.Errorf("attrcompress: %s: %w", name, err)
+			}
+		}
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return fmt.Errorf("attrcompress: %s: %w", name, err)
+		}
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("attrcompress: %s: %w", name, err)
+		}
+		item[name] = &dynamodb.AttributeValue{S: aws.String(string(data))}
+	}
+	delete(item, markerAttribute)
+	return nil
+}
@@ -0,0 +1,45 @@
+package attrcompress
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("lorem ipsum ", 1000)
+	for _, binary := range []bool{false, true} {
+		original := map[string]*dynamodb.AttributeValue{
+			"id":    {S: aws.String(large)},
+			"small": {S: aws.String("small")},
+			"text":  {S: aws.String(large)},
+			"n":     {N: aws.String("1")},
+		}
+		item := make(map[string]*dynamodb.AttributeValue, len(original))
+		for k, v := range original {
+			item[k] = v
+		}
+		c := Compressor{Threshold: 100, Binary: binary, MarkerAttribute: DefaultMarkerAttribute, KeyNames: []string{"id"}}
+		if err := c.Compress(item); err != nil {
+			t.Fatalf("failed to compress: %v", err)
+		}
+		if diff := cmp.Diff([]*string{aws.String("text")}, item[DefaultMarkerAttribute].SS); diff != "" {
+			t.Errorf("marker: %s", diff)
+		}
+		if binary && item["text"].B == nil {
+			t.Error("expected a binary attribute")
+		}
+		if !binary && len(*item["text"].S) >= len(large) {
+			t.Errorf("expected the attribute to be compressed, got %d bytes", len(*item["text"].S))
+		}
+		if err := Decompress(item, DefaultMarkerAttribute); err != nil {
+			t.Fatalf("failed to decompress: %v", err)
+		}
+		if diff := cmp.Diff(original, item); diff != "" {
+			t.Errorf("binary=%v: %s", binary, diff)
+		}
+	}
+}
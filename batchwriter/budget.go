@@ -0,0 +1,37 @@
+package batchwriter
+
+import (
+	"github.com/a-h/ddbimport/overflow"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// MinProvisionedWCU returns the lowest provisioned write capacity of the table and its global
+// secondary indexes, or zero if the table is on-demand.
+func MinProvisionedWCU(table *dynamodb.TableDescription) (wcu int64) {
+	if table.ProvisionedThroughput == nil || aws.Int64Value(table.ProvisionedThroughput.WriteCapacityUnits) == 0 {
+		return 0
+	}
+	wcu = aws.Int64Value(table.ProvisionedThroughput.WriteCapacityUnits)
+	for _, gsi := range table.GlobalSecondaryIndexes {
+		if gsi.ProvisionedThroughput == nil {
+			continue
+		}
+		if gsiWCU := aws.Int64Value(gsi.ProvisionedThroughput.WriteCapacityUnits); gsiWCU > 0 && gsiWCU < wcu {
+			wcu = gsiWCU
+		}
+	}
+	return wcu
+}
+
+// WriteUnits estimates the write capacity units consumed by writing the items. Each item
+// consumes one unit for each 1KB, rounded up.
+func WriteUnits(items []map[string]*dynamodb.AttributeValue) (units int) {
+	for _, item := range items {
+		units += (overflow.ItemSize(item) + 1023) / 1024
+		if len(item) == 0 {
+			units++
+		}
+	}
+	return units
+}
@@ -0,0 +1,59 @@
+package batchwriter
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestMinProvisionedWCU(t *testing.T) {
+	throughput := func(wcu int64) *dynamodb.ProvisionedThroughputDescription {
+		return &dynamodb.ProvisionedThroughputDescription{WriteCapacityUnits: aws.Int64(wcu)}
+	}
+	tests := []struct {
+		name     string
+		table    *dynamodb.TableDescription
+		expected int64
+	}{
+		{
+			name:     "on-demand",
+			table:    &dynamodb.TableDescription{ProvisionedThroughput: throughput(0)},
+			expected: 0,
+		},
+		{
+			name: "an index with less capacity than the table",
+			table: &dynamodb.TableDescription{
+				ProvisionedThroughput: throughput(1000),
+				GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndexDescription{
+					{ProvisionedThroughput: throughput(2000)},
+					{ProvisionedThroughput: throughput(300)},
+				},
+			},
+			expected: 300,
+		},
+		{
+			name: "indexes with more capacity than the table",
+			table: &dynamodb.TableDescription{
+				ProvisionedThroughput: throughput(1000),
+				GlobalSecondaryIndexes: []*dynamodb.GlobalSecondaryIndexDescription{
+					{ProvisionedThroughput: throughput(2000)},
+				},
+			},
+			expected: 1000,
+		},
+	}
+	for _, test := range tests {
+		if actual := MinProvisionedWCU(test.table); actual != test.expected {
+			t.Errorf("%s: expected %d, got %d", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestWriteUnits(t *testing.T) {
+	small := map[string]*dynamodb.AttributeValue{"id": {S: aws.String("1")}}
+	large := map[string]*dynamodb.AttributeValue{"id": {S: aws.String(string(make([]byte, 2000)))}}
+	if units := WriteUnits([]map[string]*dynamodb.AttributeValue{small, small, large}); units != 4 {
+		t.Errorf("expected 4 units, got %d", units)
+	}
+}
@@ -0,0 +1,114 @@
+package batchwriter
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// NewChaos creates a Chaos which doesn't inject any faults until its rates are set. The seed
+// determines which requests and items are affected.
+func NewChaos(seed int64) *Chaos {
+	return &Chaos{
+		random: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Chaos injects faults into BatchWriteItem requests, so that operators can rehearse how an
+// import behaves under stress before running it against a production table. Faults are
+// injected within the AWS SDK, so they are retried in the same way as real faults. It is safe
+// for concurrent use.
+type Chaos struct {
+	// ThrottleRate is the fraction of requests which fail with a
+	// ProvisionedThroughputExceededException.
+	ThrottleRate float64
+	// NetworkErrorRate is the fraction of requests which fail with a connection reset error.
+	NetworkErrorRate float64
+	// UnprocessedRate is the fraction of items which are returned as UnprocessedItems. The items
+	// are written, so retrying them writes them again.
+	UnprocessedRate float64
+
+	m      sync.Mutex
+	random *rand.Rand
+	counts ChaosCounts
+}
+
+// ChaosCounts are the number of faults injected.
+type ChaosCounts struct {
+	Throttles     int64
+	NetworkErrors int64
+	Unprocessed   int64
+}
+
+// Inject faults into the BatchWriteItem requests of clients created from the handlers, e.g.
+// the Handlers of a session.
+func (c *Chaos) Inject(handlers *request.Handlers) {
+	handlers.Send.PushFront(c.send)
+	handlers.Unmarshal.PushBack(c.unmarshal)
+}
+
+// Counts returns the number of faults injected so far.
+func (c *Chaos) Counts() ChaosCounts {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.counts
+}
+
+func (c *Chaos) roll(rate float64) bool {
+	return rate > 0 && c.random.Float64() < rate
+}
+
+var errConnectionReset = errors.New("read: connection reset by peer")
+
+// send fails requests before they are sent.
+func (c *Chaos) send(r *request.Request) {
+	if r.Operation.Name != "BatchWriteItem" {
+		return
+	}
+	c.m.Lock()
+	switch {
+	case c.roll(c.ThrottleRate):
+		c.counts.Throttles++
+		r.Error = awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "injected by chaos mode", nil)
+		r.HTTPResponse = &http.Response{StatusCode: http.StatusBadRequest, Header: http.Header{}, Body: http.NoBody}
+	case c.roll(c.NetworkErrorRate):
+		c.counts.NetworkErrors++
+		r.Error = awserr.New(request.ErrCodeRequestError, "send request failed, injected by chaos mode",
+			&url.Error{Op: r.HTTPRequest.Method, URL: r.HTTPRequest.URL.String(), Err: errConnectionReset})
+		r.HTTPResponse = &http.Response{StatusCode: 0, Header: http.Header{}, Body: http.NoBody}
+	}
+	c.m.Unlock()
+	if r.Error != nil {
+		// Stop the remaining handlers from sending the request.
+		r.Handlers.Send.AfterEachFn = request.HandlerListStopOnError
+	}
+}
+
+// unmarshal moves items of successful requests to the UnprocessedItems of the output.
+func (c *Chaos) unmarshal(r *request.Request) {
+	input, isBatchWrite := r.Params.(*dynamodb.BatchWriteItemInput)
+	output, hasOutput := r.Data.(*dynamodb.BatchWriteItemOutput)
+	if !isBatchWrite || !hasOutput || r.Error != nil {
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	for table, requests := range input.RequestItems {
+		for _, wr := range requests {
+			if !c.roll(c.UnprocessedRate) {
+				continue
+			}
+			c.counts.Unprocessed++
+			if output.UnprocessedItems == nil {
+				output.UnprocessedItems = make(map[string][]*dynamodb.WriteRequest)
+			}
+			output.UnprocessedItems[table] = append(output.UnprocessedItems[table], wr)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package batchwriter
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+)
+
+// chaosWriter creates a BatchWriter which writes to a server that processes every item, with
+// the chaos injected.
+func chaosWriter(t *testing.T, chaos *Chaos, maxRetries int) (bw BatchWriter, requests *int64) {
+	sess, requests := chaosSession(t, chaos, maxRetries)
+	bw = NewWithSession(sess, "table")
+	bw.Backoff = NewBackoff(0)
+	return bw, requests
+}
+
+// chaosSession creates a session for a server that processes every item, with the chaos
+// injected.
+func chaosSession(t *testing.T, chaos *Chaos, maxRetries int) (sess *session.Session, requests *int64) {
+	requests = new(int64)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(requests, 1)
+		w.Write([]byte(`{"UnprocessedItems":{}}`))
+	}))
+	t.Cleanup(s.Close)
+	sess = session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String("eu-west-2"),
+		Endpoint:    aws.String(s.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		// Retry without the SDK's usual delays, to keep the tests fast.
+		Retryer: client.DefaultRetryer{
+			NumMaxRetries:    maxRetries,
+			MinRetryDelay:    time.Millisecond,
+			MaxRetryDelay:    time.Millisecond,
+			MinThrottleDelay: time.Millisecond,
+			MaxThrottleDelay: time.Millisecond,
+		},
+	}))
+	chaos.Inject(&sess.Handlers)
+	return sess, requests
+}
+
+var chaosRecords = []map[string]*dynamodb.AttributeValue{
+	{"pk": {S: aws.String("1")}},
+	{"pk": {S: aws.String("2")}},
+}
+
+func TestChaosThrottle(t *testing.T) {
+	chaos := NewChaos(1)
+	chaos.ThrottleRate = 1
+	bw, requests := chaosWriter(t, chaos, 2)
+	var throttles int64
+	bw.Hooks.OnThrottle = func(err error) { throttles++ }
+	err := bw.Write(chaosRecords)
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) || awsErr.Code() != dynamodb.ErrCodeProvisionedThroughputExceededException {
+		t.Fatalf("expected a throttling error, got %v", err)
+	}
+	if *requests != 0 {
+		t.Errorf("expected no requests to be sent, got %d", *requests)
+	}
+	// The request is retried twice by the AWS SDK.
+	if diff := cmp.Diff(ChaosCounts{Throttles: 3}, chaos.Counts()); diff != "" {
+		t.Error(diff)
+	}
+	// Each throttle is seen once, by the SDK's retry handler.
+	if throttles != 3 {
+		t.Errorf("expected 3 throttle hooks, got %d", throttles)
+	}
+}
+
+func TestChaosNetworkError(t *testing.T) {
+	chaos := NewChaos(1)
+	chaos.NetworkErrorRate = 1
+	bw, requests := chaosWriter(t, chaos, 1)
+	err := bw.Write(chaosRecords)
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) || awsErr.Code() != request.ErrCodeRequestError {
+		t.Fatalf("expected a request error, got %v", err)
+	}
+	if *requests != 0 {
+		t.Errorf("expected no requests to be sent, got %d", *requests)
+	}
+	if diff := cmp.Diff(ChaosCounts{NetworkErrors: 2}, chaos.Counts()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestChaosUnprocessed(t *testing.T) {
+	chaos := NewChaos(1)
+	chaos.UnprocessedRate = 1
+	bw, requests := chaosWriter(t, chaos, 0)
+	var unprocessed int
+	bw.Hooks.OnUnprocessed = func(n int) { unprocessed += n }
+	err := bw.Write(chaosRecords)
+	if !errors.Is(err, ErrMaxBackoffReached) {
+		t.Fatalf("expected backoff to be exceeded, got %v", err)
+	}
+	if *requests != 2 {
+		t.Errorf("expected the batch and one retry to be sent, got %d", *requests)
+	}
+	if diff := cmp.Diff(ChaosCounts{Unprocessed: 4}, chaos.Counts()); diff != "" {
+		t.Error(diff)
+	}
+	if unprocessed != 4 {
+		t.Errorf("expected 4 unprocessed items, got %d", unprocessed)
+	}
+}
+
+func TestChaosRecovers(t *testing.T) {
+	chaos := NewChaos(1)
+	chaos.ThrottleRate = 0.5
+	chaos.NetworkErrorRate = 0.5
+	bw, _ := chaosWriter(t, chaos, 10)
+	for i := 0; i < 5; i++ {
+		if err := bw.Write(chaosRecords); err != nil {
+			t.Fatalf("expected the SDK to retry injected faults, got %v", err)
+		}
+	}
+	if counts := chaos.Counts(); counts.Throttles == 0 || counts.NetworkErrors == 0 {
+		t.Errorf("expected throttles and network errors to be injected, got %+v", counts)
+	}
+}
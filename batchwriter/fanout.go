@@ -0,0 +1,187 @@
+package batchwriter
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// InRegion creates a copy of the BatchWriter which writes to the table with the same name in the
+// session's region. The copy has its own Capacity, Hooks and Limiter.
+func (bw BatchWriter) InRegion(sess *session.Session) BatchWriter {
+	cp := bw
+	cp.Capacity = NewConsumedCapacity()
+	cp.Hooks = &Hooks{}
+	if bw.Limiter != nil {
+		cp.Limiter = NewRateLimiter(bw.Limiter.perSecond)
+	}
+	cp.client = dynamodb.New(sess)
+	return cp
+}
+
+// NewFanOut creates a FanOut which writes each batch to all of the writers, keyed by region.
+// minSuccesses is the number of regions which must write a batch for the write to succeed. Zero
+// requires every region to succeed.
+func NewFanOut(writers map[string]BatchWriter, minSuccesses int) *FanOut {
+	f := &FanOut{
+		MinSuccesses: minSuccesses,
+		writers:      make(map[string]BatchWriter, len(writers)),
+		stats:        make(map[string]*RegionStats, len(writers)),
+	}
+	for region, bw := range writers {
+		stats := &RegionStats{}
+		f.stats[region] = stats
+		bw.Hooks = f.regionHooks(stats, bw.Hooks)
+		f.writers[region] = bw
+	}
+	return f
+}
+
+// FanOut writes each batch to tables in multiple regions at the same time, for active-active
+// setups which don't use global tables. It is safe for concurrent use.
+type FanOut struct {
+	// MinSuccesses is the number of regions which must write a batch for the write to succeed.
+	// Zero requires every region to succeed.
+	MinSuccesses int
+	writers      map[string]BatchWriter
+	m            sync.Mutex
+	stats        map[string]*RegionStats
+}
+
+// RegionStats are the results of writing to a single region.
+type RegionStats struct {
+	// Batches is the number of batches written.
+	Batches int64
+	// Failures is the number of batches which could not be written.
+	Failures int64
+	// Throttles is the number of throttled requests, including retries.
+	Throttles int64
+	// Unprocessed is the number of items returned unprocessed by DynamoDB, which were retried.
+	Unprocessed int64
+	// ConsumedWCU is the write capacity consumed by the table, excluding its indexes.
+	ConsumedWCU float64
+	// LastError is the cause of the last failure.
+	LastError string `json:",omitempty"`
+}
+
+// regionHooks returns a copy of the hooks which also counts throttles and unprocessed items in
+// the stats. The hooks passed in aren't changed.
+func (f *FanOut) regionHooks(stats *RegionStats, existing *Hooks) *Hooks {
+	hooks := &Hooks{}
+	if existing != nil {
+		*hooks = *existing
+	}
+	onUnprocessed, onThrottle := hooks.OnUnprocessed, hooks.OnThrottle
+	hooks.OnUnprocessed = func(unprocessed int) {
+		f.m.Lock()
+		stats.Unprocessed += int64(unprocessed)
+		f.m.Unlock()
+		if onUnprocessed != nil {
+			onUnprocessed(unprocessed)
+		}
+	}
+	hooks.OnThrottle = func(err error) {
+		f.m.Lock()
+		stats.Throttles++
+		f.m.Unlock()
+		if onThrottle != nil {
+			onThrottle(err)
+		}
+	}
+	return hooks
+}
+
+// Write the records to every region in parallel. The requests are made once, by any one of the
+// writers, so the writers must have the same Delete and KeyNames. An ErrFanOut is returned if
+// fewer than MinSuccesses regions wrote the records.
+func (f *FanOut) Write(records []map[string]*dynamodb.AttributeValue) error {
+	for _, bw := range f.writers {
+		return f.WriteAll(bw.Requests(records))
+	}
+	return nil
+}
+
+// WriteAll writes the same put and delete requests to every region in parallel, see Write.
+// The requests are only read, so they can be shared by the regions.
+func (f *FanOut) WriteAll(writeRequests []*dynamodb.WriteRequest) error {
+	var wg sync.WaitGroup
+	var m sync.Mutex
+	errs := make(map[string]error)
+	for region, bw := range f.writers {
+		wg.Add(1)
+		go func(region string, bw BatchWriter) {
+			defer wg.Done()
+			err := bw.WriteAll(writeRequests)
+			f.m.Lock()
+			stats := f.stats[region]
+			if err != nil {
+				stats.Failures++
+				stats.LastError = err.Error()
+			} else {
+				stats.Batches++
+			}
+			f.m.Unlock()
+			if err != nil {
+				m.Lock()
+				errs[region] = err
+				m.Unlock()
+			}
+		}(region, bw)
+	}
+	wg.Wait()
+	required := f.MinSuccesses
+	if required <= 0 || required > len(f.writers) {
+		required = len(f.writers)
+	}
+	if len(f.writers)-len(errs) < required {
+		return &ErrFanOut{Errors: errs}
+	}
+	return nil
+}
+
+// Stats returns the results of writing to each region.
+func (f *FanOut) Stats() map[string]RegionStats {
+	f.m.Lock()
+	defer f.m.Unlock()
+	stats := make(map[string]RegionStats, len(f.stats))
+	for region, s := range f.stats {
+		cp := *s
+		cp.ConsumedWCU = f.writers[region].Capacity.Table()
+		stats[region] = cp
+	}
+	return stats
+}
+
+// ErrFanOut is returned when too few regions wrote a batch.
+type ErrFanOut struct {
+	// Errors of the regions which failed, keyed by region.
+	Errors map[string]error
+}
+
+func (e *ErrFanOut) Error() string {
+	regions := make([]string, 0, len(e.Errors))
+	for region := range e.Errors {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	msgs := make([]string, len(regions))
+	for i, region := range regions {
+		msgs[i] = fmt.Sprintf("%s: %v", region, e.Errors[region])
+	}
+	return "batchwriter: failed to write to regions: " + strings.Join(msgs, "; ")
+}
+
+// Is returns true if the error of any region matches the target, e.g. ErrThrottled.
+func (e *ErrFanOut) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package batchwriter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFanOut(t *testing.T) {
+	newWriters := func() map[string]BatchWriter {
+		// Every request to us-east-1 is throttled, and isn't retried.
+		chaos := NewChaos(1)
+		chaos.ThrottleRate = 1
+		throttledWriter, _ := chaosWriter(t, chaos, 0)
+		return map[string]BatchWriter{
+			"eu-west-1": newTestBatchWriter(&fakeClient{
+				responses: []*dynamodb.BatchWriteItemOutput{{}},
+				errors:    []error{nil},
+			}),
+			"us-east-1": throttledWriter,
+		}
+	}
+	records := []map[string]*dynamodb.AttributeValue{{"id": {S: aws.String("1")}}}
+
+	var throttles int
+	writers := newWriters()
+	primary := writers["us-east-1"]
+	primary.Hooks.OnThrottle = func(err error) { throttles++ }
+	writers["us-east-1"] = primary
+	f := NewFanOut(writers, 0)
+	err := f.Write(records)
+	var efo *ErrFanOut
+	if !errors.As(err, &efo) {
+		t.Fatalf("expected ErrFanOut, got %v", err)
+	}
+	if !errors.Is(err, ErrThrottled) {
+		t.Errorf("expected the error to be throttled, got %v", err)
+	}
+	if throttles != 1 {
+		t.Errorf("expected existing hooks to be called, got %d throttles", throttles)
+	}
+
+	// When one region must succeed, the failure is recorded in the stats only.
+	f = NewFanOut(newWriters(), 1)
+	if err = f.Write(records); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stats := f.Stats()
+	expected := map[string]RegionStats{
+		"eu-west-1": {Batches: 1},
+		"us-east-1": {Failures: 1, Throttles: 1, LastError: "batchwriter: throttled: ProvisionedThroughputExceededException: injected by chaos mode"},
+	}
+	if diff := cmp.Diff(expected, stats); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestFanOutMakesRequestsOnce(t *testing.T) {
+	var deleteCalls int
+	writers := make(map[string]BatchWriter)
+	clients := make(map[string]*fakeClient)
+	for _, region := range []string{"eu-west-1", "us-east-1", "us-west-2"} {
+		client := &fakeClient{
+			responses: []*dynamodb.BatchWriteItemOutput{{}},
+			errors:    []error{nil},
+		}
+		bw := newTestBatchWriter(client)
+		bw.KeyNames = []string{"id"}
+		bw.Delete = func(record map[string]*dynamodb.AttributeValue) bool {
+			deleteCalls++
+			return record["deleted"] != nil
+		}
+		writers[region], clients[region] = bw, client
+	}
+	records := []map[string]*dynamodb.AttributeValue{
+		{"id": {S: aws.String("1")}},
+		{"id": {S: aws.String("2")}, "deleted": {BOOL: aws.Bool(true)}},
+	}
+	if err := NewFanOut(writers, 0).Write(records); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleteCalls != len(records) {
+		t.Errorf("expected Delete to be called once for each record, got %d calls", deleteCalls)
+	}
+	expected := []*dynamodb.WriteRequest{
+		putRequest(records[0]),
+		deleteRequest(map[string]*dynamodb.AttributeValue{"id": {S: aws.String("2")}}),
+	}
+	for region, client := range clients {
+		if diff := cmp.Diff(expected, client.inputs[0].RequestItems["table"]); diff != "" {
+			t.Errorf("%s: %s", region, diff)
+		}
+	}
+}
+
+func TestFanOutCountsRetriedThrottles(t *testing.T) {
+	chaos := NewChaos(1)
+	chaos.ThrottleRate = 1
+	replicaSession, _ := chaosSession(t, chaos, 1)
+	primary, _ := chaosWriter(t, NewChaos(1), 0)
+	var throttles int
+	primary.Hooks.OnThrottle = func(err error) { throttles++ }
+	writers := map[string]BatchWriter{
+		"eu-west-2": primary,
+		"us-east-1": primary.InRegion(replicaSession),
+	}
+	f := NewFanOut(writers, 1)
+	if err := f.Write(chaosRecords); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The first attempt and the AWS SDK's retry are both throttled.
+	if actual := f.Stats()["us-east-1"].Throttles; actual != 2 {
+		t.Errorf("expected 2 throttles in us-east-1, got %d", actual)
+	}
+	if throttles != 0 {
+		t.Errorf("expected the replica's throttles not to call the primary's hooks, got %d", throttles)
+	}
+}
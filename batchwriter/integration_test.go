@@ -0,0 +1,64 @@
+package batchwriter
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/a-h/ddbimport/internal/testsupport"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func records(n int) (records []map[string]*dynamodb.AttributeValue) {
+	for i := 0; i < n; i++ {
+		records = append(records, map[string]*dynamodb.AttributeValue{
+			"pk":   {S: aws.String(strconv.Itoa(i))},
+			"data": {N: aws.String(strconv.Itoa(i * 2))},
+		})
+	}
+	return
+}
+
+func TestWriteIntegration(t *testing.T) {
+	d := testsupport.Start(t)
+	tableName := d.CreateTable(t, "pk")
+	bw := NewWithSession(d.Session(), tableName)
+	if err := bw.Write(records(25)); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if items := d.Items(t, tableName); len(items) != 25 {
+		t.Errorf("expected 25 items, got %d", len(items))
+	}
+
+	bw = NewForDeleteWithSession(d.Session(), tableName)
+	var keys []map[string]*dynamodb.AttributeValue
+	for _, r := range records(10) {
+		keys = append(keys, map[string]*dynamodb.AttributeValue{"pk": r["pk"]})
+	}
+	if err := bw.Write(keys); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if items := d.Items(t, tableName); len(items) != 15 {
+		t.Errorf("expected 15 items after delete, got %d", len(items))
+	}
+}
+
+func TestWriteThrottledIntegration(t *testing.T) {
+	d := testsupport.Start(t)
+	tableName := d.CreateTable(t, "pk")
+	sess := d.Session()
+	testsupport.Throttle(sess, 2)
+	bw := NewWithSession(sess, tableName)
+	var throttles int64
+	bw.Hooks.OnThrottle = func(err error) { atomic.AddInt64(&throttles, 1) }
+	if err := bw.Write(records(25)); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if throttles != 2 {
+		t.Errorf("expected 2 throttles, got %d", throttles)
+	}
+	if items := d.Items(t, tableName); len(items) != 25 {
+		t.Errorf("expected 25 items, got %d", len(items))
+	}
+}
@@ -0,0 +1,86 @@
+package batchwriter
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// MaxBatchSize is the maximum number of requests in a BatchWriteItem request.
+const MaxBatchSize = 25
+
+// Split divides put and delete requests into batches of up to MaxBatchSize requests which
+// DynamoDB accepts. DynamoDB rejects batches which contain more than one request for the same
+// key, so only the last request for each key is kept. This leaves the table in the same state
+// as applying every request in order, because a put replaces the whole item and a delete
+// removes it. Keys are made from the keyNames attributes of each request. If keyNames is
+// empty, no requests are removed.
+func Split(keyNames []string, requests []*dynamodb.WriteRequest) (batches [][]*dynamodb.WriteRequest, superseded int) {
+	if len(keyNames) > 0 {
+		requests, superseded = lastPerKey(keyNames, requests)
+	}
+	for len(requests) > MaxBatchSize {
+		batches = append(batches, requests[:MaxBatchSize])
+		requests = requests[MaxBatchSize:]
+	}
+	if len(requests) > 0 {
+		batches = append(batches, requests)
+	}
+	return
+}
+
+// lastPerKey returns the last request for each key, in their original order.
+func lastPerKey(keyNames []string, requests []*dynamodb.WriteRequest) (kept []*dynamodb.WriteRequest, superseded int) {
+	last := make(map[string]int, len(requests))
+	keys := make([]string, len(requests))
+	for i, wr := range requests {
+		keys[i] = requestKey(keyNames, wr)
+		last[keys[i]] = i
+	}
+	if len(last) == len(requests) {
+		return requests, 0
+	}
+	kept = make([]*dynamodb.WriteRequest, 0, len(last))
+	for i, wr := range requests {
+		if last[keys[i]] == i {
+			kept = append(kept, wr)
+		}
+	}
+	return kept, len(requests) - len(kept)
+}
+
+// requestKey returns a string which uniquely identifies the key of the put or delete request.
+func requestKey(keyNames []string, wr *dynamodb.WriteRequest) string {
+	var item map[string]*dynamodb.AttributeValue
+	if wr.PutRequest != nil {
+		item = wr.PutRequest.Item
+	}
+	if wr.DeleteRequest != nil {
+		item = wr.DeleteRequest.Key
+	}
+	names := append([]string(nil), keyNames...)
+	sort.Strings(names)
+	var sb strings.Builder
+	for _, k := range names {
+		sb.WriteString(k)
+		sb.WriteByte(0)
+		if v, ok := item[k]; ok {
+			// Key attributes are strings, numbers or binary.
+			switch {
+			case v.S != nil:
+				sb.WriteByte('S')
+				sb.WriteString(aws.StringValue(v.S))
+			case v.N != nil:
+				sb.WriteByte('N')
+				sb.WriteString(aws.StringValue(v.N))
+			case v.B != nil:
+				sb.WriteByte('B')
+				sb.Write(v.B)
+			}
+		}
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
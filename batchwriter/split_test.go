@@ -0,0 +1,89 @@
+package batchwriter
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplit(t *testing.T) {
+	item := func(pk, sk, data string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{"pk": {S: aws.String(pk)}, "sk": {N: aws.String(sk)}, "data": {S: aws.String(data)}}
+	}
+	key := func(pk, sk string) map[string]*dynamodb.AttributeValue {
+		return map[string]*dynamodb.AttributeValue{"pk": {S: aws.String(pk)}, "sk": {N: aws.String(sk)}}
+	}
+	requests := []*dynamodb.WriteRequest{
+		putRequest(item("a", "1", "inserted")),
+		putRequest(item("a", "2", "other sort key")),
+		putRequest(item("a", "1", "updated")),
+		deleteRequest(key("b", "1")),
+		putRequest(item("b", "1", "reinserted")),
+		putRequest(item("c", "1", "deleted")),
+		deleteRequest(key("c", "1")),
+	}
+	batches, superseded := Split([]string{"pk", "sk"}, requests)
+	expected := [][]*dynamodb.WriteRequest{{
+		putRequest(item("a", "2", "other sort key")),
+		putRequest(item("a", "1", "updated")),
+		putRequest(item("b", "1", "reinserted")),
+		deleteRequest(key("c", "1")),
+	}}
+	if diff := cmp.Diff(expected, batches); diff != "" {
+		t.Error(diff)
+	}
+	if superseded != 3 {
+		t.Errorf("expected 3 superseded requests, got %d", superseded)
+	}
+}
+
+func TestSplitWithoutKeyNames(t *testing.T) {
+	var requests []*dynamodb.WriteRequest
+	for i := 0; i < 30; i++ {
+		requests = append(requests, putRequest(map[string]*dynamodb.AttributeValue{"id": {N: aws.String(strconv.Itoa(i % 2))}}))
+	}
+	batches, superseded := Split(nil, requests)
+	if len(batches) != 2 || len(batches[0]) != MaxBatchSize || len(batches[1]) != 5 {
+		t.Errorf("expected batches of 25 and 5 requests, got %d batches", len(batches))
+	}
+	if superseded != 0 {
+		t.Errorf("expected no superseded requests, got %d", superseded)
+	}
+}
+
+func TestWriteSupersededRecords(t *testing.T) {
+	records := []map[string]*dynamodb.AttributeValue{
+		{"id": {S: aws.String("1")}, "op": {S: aws.String("I")}},
+		{"id": {S: aws.String("2")}, "op": {S: aws.String("I")}},
+		{"id": {S: aws.String("1")}, "op": {S: aws.String("D")}},
+	}
+	client := &fakeClient{
+		responses: []*dynamodb.BatchWriteItemOutput{{}},
+		errors:    []error{nil},
+	}
+	bw := newTestBatchWriter(client)
+	bw.KeyNames = []string{"id"}
+	bw.Delete = func(record map[string]*dynamodb.AttributeValue) bool {
+		return aws.StringValue(record["op"].S) == "D"
+	}
+	var written, superseded int
+	bw.Hooks.OnBatchWritten = func(records int, duration time.Duration) { written += records }
+	bw.Hooks.OnSuperseded = func(n int) { superseded += n }
+	if err := bw.Write(records); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []*dynamodb.WriteRequest{
+		putRequest(records[1]),
+		deleteRequest(map[string]*dynamodb.AttributeValue{"id": {S: aws.String("1")}}),
+	}
+	if diff := cmp.Diff(expected, client.inputs[0].RequestItems["table"]); diff != "" {
+		t.Error(diff)
+	}
+	if written != 3 || superseded != 1 {
+		t.Errorf("expected 3 records written and 1 superseded, got %d and %d", written, superseded)
+	}
+}
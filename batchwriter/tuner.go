@@ -0,0 +1,136 @@
+package batchwriter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// NewTuner creates a Tuner which tries each of the concurrency candidates for the trial
+// duration. Until a candidate is chosen, the first candidate is used.
+func NewTuner(candidates []int, trial time.Duration) *Tuner {
+	t := &Tuner{
+		Candidates: candidates,
+		Trial:      trial,
+		limit:      candidates[0],
+	}
+	t.cond = sync.NewCond(&t.m)
+	return t
+}
+
+// Tuner limits the number of concurrent writes, and experiments with the limit to find the
+// concurrency with the highest throughput that isn't throttled by the table or its global
+// secondary indexes. Writers call Acquire before each write, and Release after it. It is safe
+// for concurrent use.
+type Tuner struct {
+	// Candidates are the concurrency limits to try, in order.
+	Candidates []int
+	// Trial is how long each candidate is tried for.
+	Trial time.Duration
+
+	m              sync.Mutex
+	cond           *sync.Cond
+	limit          int
+	active         int
+	records        int64
+	throttles      int64
+	indexThrottles int64
+}
+
+// Trial is the throughput measured while a concurrency candidate was tried.
+type Trial struct {
+	Concurrency      int
+	RecordsPerSecond float64
+	// Throttles includes the IndexThrottles.
+	Throttles int64
+	// IndexThrottles are the throttles caused by global secondary indexes.
+	IndexThrottles int64
+}
+
+// Acquire waits until fewer writes than the current limit are in progress.
+func (t *Tuner) Acquire() {
+	t.m.Lock()
+	defer t.m.Unlock()
+	for t.active >= t.limit {
+		t.cond.Wait()
+	}
+	t.active++
+}
+
+// Release records that a write of n records has finished.
+func (t *Tuner) Release(n int) {
+	atomic.AddInt64(&t.records, int64(n))
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.active--
+	t.cond.Broadcast()
+}
+
+// Throttled records that a write was throttled with the error, by the table or one of its
+// global secondary indexes.
+func (t *Tuner) Throttled(err error) {
+	atomic.AddInt64(&t.throttles, 1)
+	if IsIndexThrottle(err) {
+		atomic.AddInt64(&t.indexThrottles, 1)
+	}
+}
+
+// Limit returns the current concurrency limit.
+func (t *Tuner) Limit() int {
+	t.m.Lock()
+	defer t.m.Unlock()
+	return t.limit
+}
+
+func (t *Tuner) setLimit(limit int) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.limit = limit
+	t.cond.Broadcast()
+}
+
+// Run tries each candidate in turn, then sets the limit to the best candidate and returns it.
+// If ctx is done before every candidate has been tried, the limit is left unchanged and ok is
+// false.
+func (t *Tuner) Run(ctx context.Context) (trials []Trial, best int, ok bool) {
+	for _, c := range t.Candidates {
+		t.setLimit(c)
+		records, throttles, indexThrottles := atomic.LoadInt64(&t.records), atomic.LoadInt64(&t.throttles), atomic.LoadInt64(&t.indexThrottles)
+		start := time.Now()
+		timer := time.NewTimer(t.Trial)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return trials, t.Limit(), false
+		case <-timer.C:
+		}
+		trials = append(trials, Trial{
+			Concurrency:      c,
+			RecordsPerSecond: float64(atomic.LoadInt64(&t.records)-records) / time.Since(start).Seconds(),
+			Throttles:        atomic.LoadInt64(&t.throttles) - throttles,
+			IndexThrottles:   atomic.LoadInt64(&t.indexThrottles) - indexThrottles,
+		})
+	}
+	best = chooseTrial(trials).Concurrency
+	t.setLimit(best)
+	return trials, best, true
+}
+
+// chooseTrial returns the trial with the highest throughput which wasn't throttled. If every
+// trial was throttled, the trial with the fewest throttles is returned.
+func chooseTrial(trials []Trial) (best Trial) {
+	for i, trial := range trials {
+		switch {
+		case i == 0:
+			best = trial
+		case trial.Throttles == 0 && best.Throttles == 0:
+			if trial.RecordsPerSecond > best.RecordsPerSecond {
+				best = trial
+			}
+		case trial.Throttles < best.Throttles:
+			best = trial
+		}
+	}
+	return best
+}
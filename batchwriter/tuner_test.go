@@ -0,0 +1,160 @@
+package batchwriter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestChooseTrial(t *testing.T) {
+	tests := []struct {
+		name     string
+		trials   []Trial
+		expected int
+	}{
+		{
+			name: "the fastest trial wins",
+			trials: []Trial{
+				{Concurrency: 4, RecordsPerSecond: 1000},
+				{Concurrency: 8, RecordsPerSecond: 1900},
+				{Concurrency: 16, RecordsPerSecond: 1800},
+			},
+			expected: 8,
+		},
+		{
+			name: "throttled trials lose, even if they're faster",
+			trials: []Trial{
+				{Concurrency: 4, RecordsPerSecond: 1000},
+				{Concurrency: 8, RecordsPerSecond: 1900, Throttles: 1},
+				{Concurrency: 16, RecordsPerSecond: 2500, Throttles: 10},
+			},
+			expected: 4,
+		},
+		{
+			name: "if every trial was throttled, the trial with the fewest throttles wins",
+			trials: []Trial{
+				{Concurrency: 4, RecordsPerSecond: 1000, Throttles: 3},
+				{Concurrency: 8, RecordsPerSecond: 1900, Throttles: 2},
+				{Concurrency: 16, RecordsPerSecond: 2500, Throttles: 10},
+			},
+			expected: 8,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := chooseTrial(test.trials).Concurrency; actual != test.expected {
+				t.Errorf("expected %d, got %d", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestTunerLimitsConcurrency(t *testing.T) {
+	tuner := NewTuner([]int{2}, time.Millisecond)
+	var active, maxActive int64
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				tuner.Acquire()
+				n := atomic.AddInt64(&active, 1)
+				for {
+					m := atomic.LoadInt64(&maxActive)
+					if n <= m || atomic.CompareAndSwapInt64(&maxActive, m, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt64(&active, -1)
+				tuner.Release(25)
+			}
+		}()
+	}
+	wg.Wait()
+	if maxActive > 2 {
+		t.Errorf("expected at most 2 concurrent writes, got %d", maxActive)
+	}
+}
+
+func TestTunerRun(t *testing.T) {
+	tuner := NewTuner([]int{1, 2, 4}, 20*time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Writes are throttled when more than 2 are in progress.
+	var active int64
+	for i := 0; i < 4; i++ {
+		go func() {
+			for ctx.Err() == nil {
+				tuner.Acquire()
+				if atomic.AddInt64(&active, 1) > 2 {
+					tuner.Throttled(indexThrottle)
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt64(&active, -1)
+				tuner.Release(25)
+			}
+		}()
+	}
+
+	trials, best, ok := tuner.Run(ctx)
+	if !ok {
+		t.Fatal("expected the tuner to complete")
+	}
+	var concurrencies []int
+	for _, trial := range trials {
+		concurrencies = append(concurrencies, trial.Concurrency)
+	}
+	if diff := cmp.Diff([]int{1, 2, 4}, concurrencies); diff != "" {
+		t.Error(diff)
+	}
+	if best != 2 {
+		t.Errorf("expected a concurrency of 2, got %d: %+v", best, trials)
+	}
+	if tuner.Limit() != 2 {
+		t.Errorf("expected the limit to be set to 2, got %d", tuner.Limit())
+	}
+	if last := trials[len(trials)-1]; last.IndexThrottles == 0 || last.IndexThrottles != last.Throttles {
+		t.Errorf("expected the throttles of the last trial to be index throttles, got %+v", last)
+	}
+}
+
+var indexThrottle = awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "The level of configured provisioned throughput for one or more global secondary indexes of the table was exceeded.", nil)
+
+func TestIsIndexThrottle(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "index throttle", err: indexThrottle, expected: true},
+		{name: "on-demand index throttle", err: awserr.New("ThrottlingException", "Throughput exceeds the current capacity for one or more global secondary indexes.", nil), expected: true},
+		{name: "table throttle", err: awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "The level of configured provisioned throughput for the table was exceeded.", nil)},
+		{name: "other errors", err: awserr.New("ValidationException", "global secondary index key is invalid", nil)},
+		{name: "nil", err: nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := IsIndexThrottle(test.err); actual != test.expected {
+				t.Errorf("expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestTunerRunCancelled(t *testing.T) {
+	tuner := NewTuner([]int{1, 2}, time.Hour)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, best, ok := tuner.Run(ctx); ok || best != 1 {
+		t.Errorf("expected the tuner to stop at the first candidate, got %d, %v", best, ok)
+	}
+}
@@ -0,0 +1,99 @@
+package batchwriter
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// updateAll writes the item of each put request with an UpdateItem request which only succeeds
+// if the item already exists, see UpdateOnly. The delete requests are written in a batch.
+func (bw BatchWriter) updateAll(writeRequests []*dynamodb.WriteRequest) (err error) {
+	start := time.Now()
+	var deletes []*dynamodb.WriteRequest
+	for _, wr := range writeRequests {
+		if wr.DeleteRequest != nil {
+			deletes = append(deletes, wr)
+			continue
+		}
+		if err = bw.update(wr.PutRequest.Item); err != nil {
+			return
+		}
+	}
+	if len(deletes) > 0 {
+		if err = bw.write(map[string][]*dynamodb.WriteRequest{bw.tableName: deletes}, 0); err != nil {
+			return
+		}
+	}
+	if bw.Hooks != nil && bw.Hooks.OnBatchWritten != nil {
+		bw.Hooks.OnBatchWritten(len(writeRequests), time.Since(start))
+	}
+	return
+}
+
+// update sets the attributes of the record on the existing item with the same key. If the item
+// doesn't exist, the OnConditionFailed hook is called, and no error is returned.
+func (bw BatchWriter) update(record map[string]*dynamodb.AttributeValue) error {
+	input := updateInput(bw.tableName, bw.KeyNames, record)
+	if bw.Limiter != nil {
+		bw.Limiter.Wait(1)
+	}
+	uio, err := bw.client.UpdateItemWithContext(aws.BackgroundContext(), input, bw.withRetryHandler)
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		if bw.Hooks != nil && bw.Hooks.OnConditionFailed != nil {
+			bw.Hooks.OnConditionFailed(input.Key)
+		}
+		return nil
+	}
+	if err != nil {
+		return bw.newErrBatchWrite(map[string][]*dynamodb.WriteRequest{bw.tableName: {putRequest(record)}}, err, isThrottle(err))
+	}
+	bw.Capacity.add([]*dynamodb.ConsumedCapacity{uio.ConsumedCapacity})
+	return nil
+}
+
+// updateInput creates an UpdateItem request which sets every attribute of the record that isn't
+// part of the key, on the condition that the item exists.
+func updateInput(tableName string, keyNames []string, record map[string]*dynamodb.AttributeValue) *dynamodb.UpdateItemInput {
+	input := &dynamodb.UpdateItemInput{
+		TableName:                aws.String(tableName),
+		Key:                      make(map[string]*dynamodb.AttributeValue, len(keyNames)),
+		ConditionExpression:      aws.String("attribute_exists(#k)"),
+		ExpressionAttributeNames: map[string]*string{"#k": aws.String(keyNames[0])},
+		ReturnConsumedCapacity:   aws.String(dynamodb.ReturnConsumedCapacityIndexes),
+	}
+	var names []string
+	for name := range record {
+		isKey := false
+		for _, k := range keyNames {
+			if k == name {
+				input.Key[name] = record[name]
+				isKey = true
+			}
+		}
+		if !isKey {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return input
+	}
+	// Sort the names, so that requests are the same each time.
+	sort.Strings(names)
+	set := make([]string, len(names))
+	input.ExpressionAttributeValues = make(map[string]*dynamodb.AttributeValue, len(names))
+	for i, name := range names {
+		input.ExpressionAttributeNames[fmt.Sprintf("#a%d", i)] = aws.String(name)
+		input.ExpressionAttributeValues[fmt.Sprintf(":v%d", i)] = record[name]
+		set[i] = fmt.Sprintf("#a%d = :v%d", i, i)
+	}
+	input.UpdateExpression = aws.String("SET " + strings.Join(set, ", "))
+	return input
+}
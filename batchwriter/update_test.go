@@ -0,0 +1,90 @@
+package batchwriter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakeUpdateClient struct {
+	dynamodbiface.DynamoDBAPI
+	existing map[string]bool
+	inputs   []*dynamodb.UpdateItemInput
+}
+
+func (fc *fakeUpdateClient) UpdateItemWithContext(_ aws.Context, input *dynamodb.UpdateItemInput, _ ...request.Option) (*dynamodb.UpdateItemOutput, error) {
+	fc.inputs = append(fc.inputs, input)
+	if !fc.existing[*input.Key["id"].S] {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	}
+	return &dynamodb.UpdateItemOutput{
+		ConsumedCapacity: &dynamodb.ConsumedCapacity{Table: &dynamodb.Capacity{CapacityUnits: aws.Float64(1)}},
+	}, nil
+}
+
+func TestUpdateOnly(t *testing.T) {
+	client := &fakeUpdateClient{existing: map[string]bool{"1": true}}
+	bw := newTestBatchWriter(client)
+	bw.KeyNames = []string{"id"}
+	bw.UpdateOnly = true
+	var notFound []map[string]*dynamodb.AttributeValue
+	bw.Hooks.OnConditionFailed = func(key map[string]*dynamodb.AttributeValue) {
+		notFound = append(notFound, key)
+	}
+	var written int
+	bw.Hooks.OnBatchWritten = func(records int, _ time.Duration) { written += records }
+	err := bw.Write([]map[string]*dynamodb.AttributeValue{
+		{"id": {S: aws.String("1")}, "score": {N: aws.String("10")}, "tier": {S: aws.String("gold")}},
+		{"id": {S: aws.String("2")}, "score": {N: aws.String("20")}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := &dynamodb.UpdateItemInput{
+		TableName:           aws.String("table"),
+		Key:                 map[string]*dynamodb.AttributeValue{"id": {S: aws.String("1")}},
+		ConditionExpression: aws.String("attribute_exists(#k)"),
+		UpdateExpression:    aws.String("SET #a0 = :v0, #a1 = :v1"),
+		ExpressionAttributeNames: map[string]*string{
+			"#k":  aws.String("id"),
+			"#a0": aws.String("score"),
+			"#a1": aws.String("tier"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":v0": {N: aws.String("10")},
+			":v1": {S: aws.String("gold")},
+		},
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityIndexes),
+	}
+	if diff := cmp.Diff(expected, client.inputs[0]); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([]map[string]*dynamodb.AttributeValue{{"id": {S: aws.String("2")}}}, notFound); diff != "" {
+		t.Error(diff)
+	}
+	if written != 2 {
+		t.Errorf("expected a batch of 2 records to be written, got %d", written)
+	}
+	if consumed := bw.Capacity.Table(); consumed != 1 {
+		t.Errorf("expected 1 WCU to be consumed, got %v", consumed)
+	}
+}
+
+func TestUpdateOnlyKeysOnly(t *testing.T) {
+	input := updateInput("table", []string{"pk", "sk"}, map[string]*dynamodb.AttributeValue{
+		"pk": {S: aws.String("a")},
+		"sk": {S: aws.String("b")},
+	})
+	if input.UpdateExpression != nil || input.ExpressionAttributeValues != nil {
+		t.Errorf("expected no update expression for records with only keys, got %v", input)
+	}
+	if len(input.Key) != 2 {
+		t.Errorf("expected a key with 2 attributes, got %v", input.Key)
+	}
+}
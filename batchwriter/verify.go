@@ -0,0 +1,208 @@
+package batchwriter
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"sort"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// NewVerifier creates a Verifier which reads back a sample of the records written by the
+// BatchWriter. rate is the probability of each record being read back, e.g. 0.001. The
+// BatchWriter's KeyNames must be set.
+func (bw BatchWriter) NewVerifier(rate float64, seed int64) *Verifier {
+	return &Verifier{
+		Rate:   rate,
+		bw:     bw,
+		random: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Verifier reads back a sample of written records with strongly consistent GetItem requests, and
+// compares them with the records that were sent, so that transformation and key construction
+// bugs are found while an import is running. It is safe for concurrent use.
+type Verifier struct {
+	// Rate is the probability of each record being read back.
+	Rate   float64
+	bw     BatchWriter
+	m      sync.Mutex
+	random *rand.Rand
+	// checked is the number of records read back.
+	checked int64
+}
+
+// Mismatch is a record which was read back with different values to those written.
+type Mismatch struct {
+	Key map[string]*dynamodb.AttributeValue
+	// Attributes are the names of the attributes which are different, missing or unexpected,
+	// sorted by name. It's nil if the item wasn't found.
+	Attributes []string
+}
+
+func (m Mismatch) String() string {
+	if m.Attributes == nil {
+		return fmt.Sprintf("item %v not found", m.Key)
+	}
+	return fmt.Sprintf("item %v has different values for %v", m.Key, m.Attributes)
+}
+
+// Verify reads back a sample of the items of the put requests, which must have been written,
+// e.g. by WriteAll, and returns the ones which don't match. Delete requests aren't read back, so
+// the BatchWriter's Delete function isn't called again. An item which is written again by
+// another batch before it's read back, e.g. because the input has duplicate keys, is reported
+// as a mismatch.
+func (v *Verifier) Verify(writeRequests []*dynamodb.WriteRequest) (mismatches []Mismatch, err error) {
+	for _, wr := range writeRequests {
+		if wr.PutRequest == nil || !v.sampled() {
+			continue
+		}
+		record := wr.PutRequest.Item
+		key := v.bw.key(record)
+		gio, err := v.bw.client.GetItemWithContext(aws.BackgroundContext(), &dynamodb.GetItemInput{
+			TableName:      aws.String(v.bw.tableName),
+			Key:            key,
+			ConsistentRead: aws.Bool(true),
+		}, v.bw.withRetryHandler)
+		if err != nil {
+			return mismatches, err
+		}
+		v.m.Lock()
+		v.checked++
+		v.m.Unlock()
+		if gio.Item == nil {
+			// Records with keys that aren't in the table aren't written in UpdateOnly mode.
+			if v.bw.UpdateOnly {
+				continue
+			}
+			mismatches = append(mismatches, Mismatch{Key: key})
+			continue
+		}
+		actual := gio.Item
+		if v.bw.UpdateOnly {
+			// The item keeps the attributes that weren't updated.
+			actual = make(map[string]*dynamodb.AttributeValue, len(record))
+			for name := range record {
+				if av, ok := gio.Item[name]; ok {
+					actual[name] = av
+				}
+			}
+		}
+		if different := differentAttributes(record, actual); len(different) > 0 {
+			mismatches = append(mismatches, Mismatch{Key: key, Attributes: different})
+		}
+	}
+	return mismatches, nil
+}
+
+// Checked returns the number of records which have been read back.
+func (v *Verifier) Checked() int64 {
+	v.m.Lock()
+	defer v.m.Unlock()
+	return v.checked
+}
+
+func (v *Verifier) sampled() bool {
+	v.m.Lock()
+	defer v.m.Unlock()
+	return v.random.Float64() < v.Rate
+}
+
+// differentAttributes returns the names of the attributes which aren't equal in the two items.
+func differentAttributes(expected, actual map[string]*dynamodb.AttributeValue) (names []string) {
+	for name, e := range expected {
+		if !equal(e, actual[name]) {
+			names = append(names, name)
+		}
+	}
+	for name := range actual {
+		if _, ok := expected[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// equal returns true if the attribute values are the same in the way that DynamoDB stores them:
+// numbers are compared by value, e.g. 1.0 is 1, and the elements of sets are unordered.
+func equal(a, b *dynamodb.AttributeValue) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	switch {
+	case a.S != nil:
+		return b.S != nil && *a.S == *b.S
+	case a.N != nil:
+		return b.N != nil && equalNumbers(*a.N, *b.N)
+	case a.B != nil:
+		return b.B != nil && bytes.Equal(a.B, b.B)
+	case a.BOOL != nil:
+		return b.BOOL != nil && *a.BOOL == *b.BOOL
+	case a.NULL != nil:
+		return b.NULL != nil && *a.NULL == *b.NULL
+	case a.SS != nil:
+		return b.SS != nil && equalSets(aws.StringValueSlice(a.SS), aws.StringValueSlice(b.SS), func(x, y string) bool { return x == y })
+	case a.NS != nil:
+		return b.NS != nil && equalSets(aws.StringValueSlice(a.NS), aws.StringValueSlice(b.NS), equalNumbers)
+	case a.BS != nil:
+		if b.BS == nil {
+			return false
+		}
+		as, bs := make([]string, len(a.BS)), make([]string, len(b.BS))
+		for i := range a.BS {
+			as[i] = string(a.BS[i])
+		}
+		for i := range b.BS {
+			bs[i] = string(b.BS[i])
+		}
+		return equalSets(as, bs, func(x, y string) bool { return x == y })
+	case a.L != nil:
+		if b.L == nil || len(a.L) != len(b.L) {
+			return false
+		}
+		for i := range a.L {
+			if !equal(a.L[i], b.L[i]) {
+				return false
+			}
+		}
+		return true
+	case a.M != nil:
+		return b.M != nil && len(differentAttributes(a.M, b.M)) == 0
+	}
+	return false
+}
+
+// equalNumbers compares DynamoDB numbers by value. DynamoDB numbers have up to 38 digits of
+// precision.
+func equalNumbers(a, b string) bool {
+	if a == b {
+		return true
+	}
+	x, okA := new(big.Float).SetPrec(256).SetString(a)
+	y, okB := new(big.Float).SetPrec(256).SetString(b)
+	return okA && okB && x.Cmp(y) == 0
+}
+
+// equalSets returns true if the sets contain the same elements, in any order.
+func equalSets(a, b []string, eq func(x, y string) bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	matched := make([]bool, len(b))
+next:
+	for _, x := range a {
+		for j, y := range b {
+			if !matched[j] && eq(x, y) {
+				matched[j] = true
+				continue next
+			}
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package batchwriter
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakeTable struct {
+	dynamodbiface.DynamoDBAPI
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (ft *fakeTable) GetItemWithContext(_ aws.Context, input *dynamodb.GetItemInput, _ ...request.Option) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: ft.items[*input.Key["id"].S]}, nil
+}
+
+func TestVerifier(t *testing.T) {
+	table := &fakeTable{
+		items: map[string]map[string]*dynamodb.AttributeValue{
+			"1": {
+				"id":   {S: aws.String("1")},
+				"n":    {N: aws.String("1")},
+				"tags": {SS: aws.StringSlice([]string{"b", "a"})},
+				"m":    {M: map[string]*dynamodb.AttributeValue{"x": {N: aws.String("100")}}},
+			},
+			"2": {
+				"id":    {S: aws.String("2")},
+				"n":     {N: aws.String("2")},
+				"extra": {S: aws.String("x")},
+			},
+		},
+	}
+	bw := newTestBatchWriter(table)
+	bw.KeyNames = []string{"id"}
+	var deleteCalls int
+	bw.Delete = func(record map[string]*dynamodb.AttributeValue) bool {
+		deleteCalls++
+		return record["deleted"] != nil
+	}
+	v := bw.NewVerifier(1, 0)
+	records := []map[string]*dynamodb.AttributeValue{
+		{
+			"id":   {S: aws.String("1")},
+			"n":    {N: aws.String("1.0")},
+			"tags": {SS: aws.StringSlice([]string{"a", "b"})},
+			"m":    {M: map[string]*dynamodb.AttributeValue{"x": {N: aws.String("1e2")}}},
+		},
+		{
+			"id": {S: aws.String("2")},
+			"n":  {N: aws.String("3")},
+		},
+		{
+			"id": {S: aws.String("3")},
+		},
+		{
+			"id":      {S: aws.String("4")},
+			"deleted": {BOOL: aws.Bool(true)},
+		},
+	}
+	mismatches, err := v.Verify(bw.Requests(records))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deleteCalls != len(records) {
+		t.Errorf("expected Delete to be called once for each record, got %d calls", deleteCalls)
+	}
+	expected := []Mismatch{
+		{Key: map[string]*dynamodb.AttributeValue{"id": {S: aws.String("2")}}, Attributes: []string{"extra", "n"}},
+		{Key: map[string]*dynamodb.AttributeValue{"id": {S: aws.String("3")}}},
+	}
+	if diff := cmp.Diff(expected, mismatches); diff != "" {
+		t.Error(diff)
+	}
+	if checked := v.Checked(); checked != 3 {
+		t.Errorf("expected 3 records to be checked, got %d", checked)
+	}
+}
+
+func TestVerifierRate(t *testing.T) {
+	table := &fakeTable{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	bw := newTestBatchWriter(table)
+	bw.KeyNames = []string{"id"}
+	v := bw.NewVerifier(0.1, 0)
+	records := make([]map[string]*dynamodb.AttributeValue, 1000)
+	for i := range records {
+		records[i] = map[string]*dynamodb.AttributeValue{"id": {S: aws.String("missing")}}
+	}
+	if _, err := v.Verify(bw.Requests(records)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if checked := v.Checked(); checked < 50 || checked > 150 {
+		t.Errorf("expected around 100 records to be checked, got %d", checked)
+	}
+}
@@ -4,11 +4,16 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 )
 
 // New creates a new BatchWriter to write to a DynamoDB table in batches.
@@ -19,8 +24,16 @@ func New(region, tableName string) (bw BatchWriter, err error) {
 	if err != nil {
 		return
 	}
+	return NewWithSession(sess, tableName), nil
+}
+
+// NewWithSession creates a new BatchWriter which uses the session's region and credentials,
+// e.g. to write to a table in another account using an assumed role.
+func NewWithSession(sess *session.Session, tableName string) (bw BatchWriter) {
 	bw = BatchWriter{
 		Backoff:      NewBackoff(7),
+		Capacity:     NewConsumedCapacity(),
+		Hooks:        &Hooks{},
 		client:       dynamodb.New(sess),
 		tableName:    tableName,
 		newOperation: putRequest,
@@ -36,8 +49,16 @@ func NewForDelete(region, tableName string) (bw BatchWriter, err error) {
 	if err != nil {
 		return
 	}
+	return NewForDeleteWithSession(sess, tableName), nil
+}
+
+// NewForDeleteWithSession creates a new BatchWriter to delete in a DynamoDB table in batches,
+// using the session's region, credentials and endpoint.
+func NewForDeleteWithSession(sess *session.Session, tableName string) (bw BatchWriter) {
 	bw = BatchWriter{
 		Backoff:      NewBackoff(7),
+		Capacity:     NewConsumedCapacity(),
+		Hooks:        &Hooks{},
 		client:       dynamodb.New(sess),
 		tableName:    tableName,
 		newOperation: deleteRequest,
@@ -47,41 +68,377 @@ func NewForDelete(region, tableName string) (bw BatchWriter, err error) {
 
 // BatchWriter writes to DynamoDB tables using BatchWriteItem.
 type BatchWriter struct {
-	Backoff      Backoff
-	client       *dynamodb.DynamoDB
+	Backoff Backoff
+	// Capacity is the total write capacity consumed by the BatchWriter, including the
+	// capacity consumed by the table's global secondary indexes.
+	Capacity *ConsumedCapacity
+	// Hooks are called as batches are written. The Hooks can be changed or replaced at any time
+	// before Write is called.
+	Hooks *Hooks
+	// KeyNames are the names of the table's key attributes. If set, they are used to populate
+	// the Keys of an ErrBatchWrite.
+	KeyNames []string
+	// Limiter limits the rate of writes, including retries of unprocessed items. If nil, writes
+	// are not limited.
+	Limiter *RateLimiter
+	// Delete returns true if the record should be deleted instead of written with the
+	// BatchWriter's operation, e.g. because it is flagged as deleted in the source. The key of
+	// the record to delete is made from the KeyNames. If nil, no records are deleted.
+	Delete func(record map[string]*dynamodb.AttributeValue) bool
+	// OmitAttributes are the names of attributes which aren't written, e.g. a column which Delete
+	// uses to choose the operation. Items are copied without them, so the records passed to Write
+	// aren't changed.
+	OmitAttributes []string
+	// UpdateOnly writes each record with an UpdateItem request which only succeeds if an item
+	// with the same key already exists, instead of BatchWriteItem, so that records with keys
+	// that aren't in the table don't create items. The attributes of the record are set on the
+	// existing item, and its other attributes are kept. The KeyNames must be set.
+	UpdateOnly   bool
+	client       dynamodbiface.DynamoDBAPI
 	tableName    string
 	newOperation func(map[string]*dynamodb.AttributeValue) *dynamodb.WriteRequest
 }
 
-// Write to DynamoDB using BatchWriteItem.
+// Hooks allow programs embedding the BatchWriter to record their own metrics. Any hook may be
+// nil. Hooks are called from the goroutine calling Write, so must be safe for concurrent use
+// if Write is called concurrently.
+type Hooks struct {
+	// OnBatchWritten is called when all of the records in a batch have been written.
+	OnBatchWritten func(records int, duration time.Duration)
+	// OnUnprocessed is called when DynamoDB returns unprocessed items, usually because the
+	// table or one of its indexes has exceeded its capacity.
+	OnUnprocessed func(unprocessed int)
+	// OnRetry is called before unprocessed items are retried. retry starts at 1.
+	OnRetry func(retry int, unprocessed int)
+	// OnThrottle is called each time a request is throttled, from the AWS SDK's retry handler,
+	// which sees every attempt, whether or not it is retried.
+	OnThrottle func(err error)
+	// OnConditionFailed is called with the key of each record that isn't written by an
+	// UpdateOnly BatchWriter, because the item doesn't exist.
+	OnConditionFailed func(key map[string]*dynamodb.AttributeValue)
+	// OnSuperseded is called with the number of records which aren't written because a later
+	// record in the same call to Write has the same key, see Split.
+	OnSuperseded func(superseded int)
+}
+
+// Write to DynamoDB using BatchWriteItem, or UpdateItem if UpdateOnly is set. Records are put,
+// or deleted if Delete returns true. If the KeyNames are set, only the last record for each key
+// is written, and any number of records can be written, see Split.
 func (bw BatchWriter) Write(records []map[string]*dynamodb.AttributeValue) (err error) {
+	return bw.WriteAll(bw.Requests(records))
+}
+
+// Requests returns the request to write each record with: a delete request of its key if Delete
+// returns true, or the BatchWriter's operation.
+func (bw BatchWriter) Requests(records []map[string]*dynamodb.AttributeValue) []*dynamodb.WriteRequest {
 	writeRequests := make([]*dynamodb.WriteRequest, len(records))
 	for i := 0; i < len(records); i++ {
-		writeRequests[i] = bw.newOperation(records[i])
+		if bw.Delete != nil && bw.Delete(records[i]) {
+			writeRequests[i] = deleteRequest(bw.key(records[i]))
+			continue
+		}
+		writeRequests[i] = bw.newOperation(bw.omit(records[i]))
+	}
+	return writeRequests
+}
+
+// omit returns a copy of the record without the OmitAttributes, or the record if it doesn't
+// have any of them.
+func (bw BatchWriter) omit(record map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	var found bool
+	for _, name := range bw.OmitAttributes {
+		if _, found = record[name]; found {
+			break
+		}
 	}
+	if !found {
+		return record
+	}
+	cp := make(map[string]*dynamodb.AttributeValue, len(record))
+	for name, av := range record {
+		cp[name] = av
+	}
+	for _, name := range bw.OmitAttributes {
+		delete(cp, name)
+	}
+	return cp
+}
+
+// WriteAll writes any number of put and delete requests, e.g. those returned by Requests, in
+// the same way as Write.
+func (bw BatchWriter) WriteAll(writeRequests []*dynamodb.WriteRequest) (err error) {
+	if bw.UpdateOnly {
+		return bw.updateAll(writeRequests)
+	}
+	start := time.Now()
+	batches, superseded := Split(bw.KeyNames, writeRequests)
+	if superseded > 0 && bw.Hooks != nil && bw.Hooks.OnSuperseded != nil {
+		bw.Hooks.OnSuperseded(superseded)
+	}
+	for _, batch := range batches {
+		if err = bw.write(map[string][]*dynamodb.WriteRequest{bw.tableName: batch}, 0); err != nil {
+			return
+		}
+	}
+	if bw.Hooks != nil && bw.Hooks.OnBatchWritten != nil {
+		bw.Hooks.OnBatchWritten(len(writeRequests), time.Since(start))
+	}
+	return
+}
+
+// WriteRequests writes a batch of up to 25 put and delete requests to DynamoDB using
+// BatchWriteItem. DynamoDB rejects batches that contain more than one request for the same key.
+func (bw BatchWriter) WriteRequests(writeRequests []*dynamodb.WriteRequest) (err error) {
+	start := time.Now()
 	requestItems := map[string][]*dynamodb.WriteRequest{
 		bw.tableName: writeRequests,
 	}
-	return bw.write(requestItems, 0)
+	if err = bw.write(requestItems, 0); err != nil {
+		return
+	}
+	if bw.Hooks != nil && bw.Hooks.OnBatchWritten != nil {
+		bw.Hooks.OnBatchWritten(len(writeRequests), time.Since(start))
+	}
+	return
+}
+
+// withRetryHandler is a request option which adds onRequestRetry to the request's handlers. It's
+// added to each request, rather than to the client, so that the Hooks of the BatchWriter making
+// the request are called, even if they were replaced after the client was created.
+func (bw BatchWriter) withRetryHandler(r *request.Request) {
+	r.Handlers.Retry.PushBack(bw.onRequestRetry)
+}
+
+// onRequestRetry is called by the AWS SDK before it retries a failed request.
+func (bw BatchWriter) onRequestRetry(r *request.Request) {
+	if bw.Hooks != nil && bw.Hooks.OnThrottle != nil && isThrottle(r.Error) {
+		bw.Hooks.OnThrottle(r.Error)
+	}
+}
+
+func isThrottle(err error) bool {
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return false
+	}
+	switch awsErr.Code() {
+	case dynamodb.ErrCodeProvisionedThroughputExceededException, dynamodb.ErrCodeRequestLimitExceeded, "ThrottlingException":
+		return true
+	}
+	return false
+}
+
+// IsIndexThrottle returns true if the error is a throttle caused by the write capacity of a
+// global secondary index, rather than the table. DynamoDB uses the same error codes for both,
+// and only the message says which it was.
+func IsIndexThrottle(err error) bool {
+	var awsErr awserr.Error
+	return isThrottle(err) && errors.As(err, &awsErr) && strings.Contains(strings.ToLower(awsErr.Message()), "global secondary index")
+}
+
+func count(ri map[string][]*dynamodb.WriteRequest) (n int) {
+	for _, wr := range ri {
+		n += len(wr)
+	}
+	return
 }
 
 func (bw BatchWriter) write(ri map[string][]*dynamodb.WriteRequest, retry int) (err error) {
-	bwo, err := bw.client.BatchWriteItem(&dynamodb.BatchWriteItemInput{
-		RequestItems: ri,
-	})
+	if bw.Limiter != nil {
+		bw.Limiter.Wait(count(ri))
+	}
+	bwo, err := bw.client.BatchWriteItemWithContext(aws.BackgroundContext(), &dynamodb.BatchWriteItemInput{
+		RequestItems:           ri,
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityIndexes),
+	}, bw.withRetryHandler)
 	if err != nil {
-		err = fmt.Errorf("batchwriter: %w", err)
-		return
+		return bw.newErrBatchWrite(ri, err, isThrottle(err))
 	}
+	bw.Capacity.add(bwo.ConsumedCapacity)
 	if len(bwo.UnprocessedItems) > 0 {
+		unprocessed := count(bwo.UnprocessedItems)
+		if bw.Hooks != nil && bw.Hooks.OnUnprocessed != nil {
+			bw.Hooks.OnUnprocessed(unprocessed)
+		}
 		if err = bw.Backoff(retry); err != nil {
-			return err
+			return bw.newErrBatchWrite(bwo.UnprocessedItems, err, true)
+		}
+		if bw.Hooks != nil && bw.Hooks.OnRetry != nil {
+			bw.Hooks.OnRetry(retry+1, unprocessed)
 		}
 		return bw.write(bwo.UnprocessedItems, retry+1)
 	}
 	return
 }
 
+// NewRateLimiter creates a RateLimiter which allows perSecond records to be written each second.
+func NewRateLimiter(perSecond float64) *RateLimiter {
+	return &RateLimiter{
+		perSecond: perSecond,
+		burst:     25,
+		tokens:    25,
+		last:      time.Now(),
+		now:       time.Now,
+		sleep:     time.Sleep,
+	}
+}
+
+// RateLimiter is a token bucket which spreads writes evenly over time. The bucket holds a
+// single batch, so writes can't burst above the rate after a quiet period. It is safe for
+// concurrent use.
+type RateLimiter struct {
+	m         sync.Mutex
+	perSecond float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+	now       func() time.Time
+	sleep     func(time.Duration)
+}
+
+// Wait until n records can be written.
+func (rl *RateLimiter) Wait(n int) {
+	if d := rl.reserve(n); d > 0 {
+		rl.sleep(d)
+	}
+}
+
+// reserve takes n tokens from the bucket, and returns how long to wait until the bucket would
+// have contained them. Tokens are taken even if the bucket is empty, so that concurrent
+// callers queue up behind each other.
+func (rl *RateLimiter) reserve(n int) time.Duration {
+	rl.m.Lock()
+	defer rl.m.Unlock()
+	now := rl.now()
+	rl.tokens = math.Min(rl.burst, rl.tokens+now.Sub(rl.last).Seconds()*rl.perSecond)
+	rl.last = now
+	rl.tokens -= float64(n)
+	if rl.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-rl.tokens / rl.perSecond * float64(time.Second))
+}
+
+// NewConsumedCapacity creates an empty ConsumedCapacity.
+func NewConsumedCapacity() *ConsumedCapacity {
+	return &ConsumedCapacity{
+		indexes: make(map[string]float64),
+	}
+}
+
+// ConsumedCapacity is the total capacity units consumed by writes. It is safe for
+// concurrent use.
+type ConsumedCapacity struct {
+	m       sync.Mutex
+	table   float64
+	indexes map[string]float64
+}
+
+func (cc *ConsumedCapacity) add(capacity []*dynamodb.ConsumedCapacity) {
+	if cc == nil {
+		return
+	}
+	cc.m.Lock()
+	defer cc.m.Unlock()
+	for _, c := range capacity {
+		if c.Table != nil {
+			cc.table += aws.Float64Value(c.Table.CapacityUnits)
+		}
+		for name, index := range c.GlobalSecondaryIndexes {
+			cc.indexes[name] += aws.Float64Value(index.CapacityUnits)
+		}
+	}
+}
+
+// Table returns the capacity units consumed by the table, excluding its indexes.
+func (cc *ConsumedCapacity) Table() float64 {
+	if cc == nil {
+		return 0
+	}
+	cc.m.Lock()
+	defer cc.m.Unlock()
+	return cc.table
+}
+
+// Indexes returns the capacity units consumed by each global secondary index.
+func (cc *ConsumedCapacity) Indexes() map[string]float64 {
+	if cc == nil {
+		return nil
+	}
+	cc.m.Lock()
+	defer cc.m.Unlock()
+	indexes := make(map[string]float64, len(cc.indexes))
+	for k, v := range cc.indexes {
+		indexes[k] = v
+	}
+	return indexes
+}
+
+func (bw BatchWriter) newErrBatchWrite(ri map[string][]*dynamodb.WriteRequest, cause error, throttled bool) *ErrBatchWrite {
+	err := &ErrBatchWrite{
+		Cause:     cause,
+		Throttled: throttled,
+	}
+	if len(bw.KeyNames) == 0 {
+		return err
+	}
+	for _, wrs := range ri {
+		for _, wr := range wrs {
+			var item map[string]*dynamodb.AttributeValue
+			if wr.PutRequest != nil {
+				item = wr.PutRequest.Item
+			}
+			if wr.DeleteRequest != nil {
+				item = wr.DeleteRequest.Key
+			}
+			err.Keys = append(err.Keys, bw.key(item))
+		}
+	}
+	return err
+}
+
+// key returns the KeyNames attributes of the item.
+func (bw BatchWriter) key(item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	key := make(map[string]*dynamodb.AttributeValue, len(bw.KeyNames))
+	for _, k := range bw.KeyNames {
+		if v, ok := item[k]; ok {
+			key[k] = v
+		}
+	}
+	return key
+}
+
+// ErrThrottled matches any ErrBatchWrite that failed because the table, or one of its indexes,
+// did not have enough capacity. Use errors.Is(err, ErrThrottled).
+var ErrThrottled = errors.New("batchwriter: throttled")
+
+// ErrBatchWrite is returned when records could not be written.
+type ErrBatchWrite struct {
+	// Keys of the records that were not written, if the KeyNames of the BatchWriter are set.
+	Keys []map[string]*dynamodb.AttributeValue
+	// Throttled is true if the write failed because of insufficient capacity.
+	Throttled bool
+	// Cause of the failure.
+	Cause error
+}
+
+func (e *ErrBatchWrite) Error() string {
+	if e.Throttled {
+		return fmt.Sprintf("batchwriter: throttled: %v", e.Cause)
+	}
+	return fmt.Sprintf("batchwriter: %v", e.Cause)
+}
+
+// Unwrap returns the Cause.
+func (e *ErrBatchWrite) Unwrap() error {
+	return e.Cause
+}
+
+// Is returns true if the target is ErrThrottled and the write was throttled.
+func (e *ErrBatchWrite) Is(target error) bool {
+	return target == ErrThrottled && e.Throttled
+}
+
 // Backoff function to retry during batch writes.
 type Backoff func(retry int) error
 
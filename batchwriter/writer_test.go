@@ -1,9 +1,16 @@
 package batchwriter
 
 import (
+	"errors"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/google/go-cmp/cmp"
 )
 
 func TestBackoffValues(t *testing.T) {
@@ -45,3 +52,299 @@ func within(actual, expected, tolerance time.Duration) bool {
 	max := expected + tolerance
 	return actual >= min && actual <= max
 }
+
+func TestConsumedCapacity(t *testing.T) {
+	cc := NewConsumedCapacity()
+	cc.add([]*dynamodb.ConsumedCapacity{
+		{
+			Table: &dynamodb.Capacity{CapacityUnits: aws.Float64(25)},
+			GlobalSecondaryIndexes: map[string]*dynamodb.Capacity{
+				"gsi1": {CapacityUnits: aws.Float64(25)},
+			},
+		},
+		{
+			Table: &dynamodb.Capacity{CapacityUnits: aws.Float64(10)},
+			GlobalSecondaryIndexes: map[string]*dynamodb.Capacity{
+				"gsi1": {CapacityUnits: aws.Float64(5)},
+				"gsi2": {CapacityUnits: aws.Float64(10)},
+			},
+		},
+	})
+	if cc.Table() != 35 {
+		t.Errorf("expected 35 table capacity units, got %v", cc.Table())
+	}
+	if diff := cmp.Diff(map[string]float64{"gsi1": 30, "gsi2": 10}, cc.Indexes()); diff != "" {
+		t.Error(diff)
+	}
+
+	// A nil ConsumedCapacity, e.g. of a BatchWriter literal, is empty.
+	var none *ConsumedCapacity
+	none.add([]*dynamodb.ConsumedCapacity{{Table: &dynamodb.Capacity{CapacityUnits: aws.Float64(1)}}})
+	if none.Table() != 0 || none.Indexes() != nil {
+		t.Errorf("expected no capacity, got %v, %v", none.Table(), none.Indexes())
+	}
+}
+
+type fakeClient struct {
+	dynamodbiface.DynamoDBAPI
+	responses []*dynamodb.BatchWriteItemOutput
+	errors    []error
+	calls     int
+	inputs    []*dynamodb.BatchWriteItemInput
+}
+
+func (fc *fakeClient) BatchWriteItemWithContext(_ aws.Context, input *dynamodb.BatchWriteItemInput, _ ...request.Option) (*dynamodb.BatchWriteItemOutput, error) {
+	i := fc.calls
+	fc.calls++
+	fc.inputs = append(fc.inputs, input)
+	return fc.responses[i], fc.errors[i]
+}
+
+func newTestBatchWriter(client dynamodbiface.DynamoDBAPI) BatchWriter {
+	return BatchWriter{
+		Backoff:      func(retry int) error { return nil },
+		Capacity:     NewConsumedCapacity(),
+		Hooks:        &Hooks{},
+		client:       client,
+		tableName:    "table",
+		newOperation: putRequest,
+	}
+}
+
+func TestHooks(t *testing.T) {
+	records := []map[string]*dynamodb.AttributeValue{
+		{"id": {S: aws.String("1")}},
+		{"id": {S: aws.String("2")}},
+	}
+	unprocessed := map[string][]*dynamodb.WriteRequest{
+		"table": {putRequest(records[1])},
+	}
+	client := &fakeClient{
+		responses: []*dynamodb.BatchWriteItemOutput{
+			{UnprocessedItems: unprocessed},
+			{},
+		},
+		errors: []error{nil, nil},
+	}
+	bw := newTestBatchWriter(client)
+	var written, unprocessedCount int
+	var retries []int
+	bw.Hooks.OnBatchWritten = func(records int, duration time.Duration) { written += records }
+	bw.Hooks.OnUnprocessed = func(n int) { unprocessedCount += n }
+	bw.Hooks.OnRetry = func(retry int, n int) { retries = append(retries, retry) }
+	if err := bw.Write(records); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if written != 2 {
+		t.Errorf("expected 2 records written, got %d", written)
+	}
+	if unprocessedCount != 1 {
+		t.Errorf("expected 1 unprocessed record, got %d", unprocessedCount)
+	}
+	if diff := cmp.Diff([]int{1}, retries); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestThrottleHook(t *testing.T) {
+	chaos := NewChaos(1)
+	chaos.ThrottleRate = 1
+	bw, _ := chaosWriter(t, chaos, 1)
+	var throttles int
+	var written bool
+	bw.Hooks.OnThrottle = func(err error) { throttles++ }
+	bw.Hooks.OnBatchWritten = func(records int, duration time.Duration) { written = true }
+	if err := bw.Write([]map[string]*dynamodb.AttributeValue{{"id": {S: aws.String("1")}}}); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("expected a throttled error, got %v", err)
+	}
+	// The hook is called once for the first attempt, and once for the SDK's retry, not again
+	// for the error returned.
+	if throttles != 2 {
+		t.Errorf("expected 2 throttles, got %d", throttles)
+	}
+	if written {
+		t.Error("expected OnBatchWritten not to be called")
+	}
+
+	// Hooks set after the writer is copied to another region, and after the FanOut adds its
+	// own hooks, are still called by the SDK's retry handler.
+	sess, _ := chaosSession(t, chaos, 1)
+	replica := bw.InRegion(sess)
+	var replicaThrottles int
+	replica.Hooks.OnThrottle = func(err error) { replicaThrottles++ }
+	f := NewFanOut(map[string]BatchWriter{"us-east-1": replica}, 0)
+	if err := f.Write([]map[string]*dynamodb.AttributeValue{{"id": {S: aws.String("1")}}}); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("expected a throttled error, got %v", err)
+	}
+	if replicaThrottles != 2 {
+		t.Errorf("expected 2 replica throttles, got %d", replicaThrottles)
+	}
+	if stats := f.Stats()["us-east-1"]; stats.Throttles != 2 {
+		t.Errorf("expected 2 throttles in the region stats, got %d", stats.Throttles)
+	}
+	if throttles != 2 {
+		t.Errorf("expected the replica not to call the original writer's hooks, got %d throttles", throttles)
+	}
+}
+
+func TestReplacedThrottleHook(t *testing.T) {
+	chaos := NewChaos(1)
+	chaos.ThrottleRate = 1
+	bw, _ := chaosWriter(t, chaos, 1)
+	// The Hooks are replaced after the writer's client is created.
+	var throttles int
+	bw.Hooks = &Hooks{OnThrottle: func(err error) { throttles++ }}
+	if err := bw.Write([]map[string]*dynamodb.AttributeValue{{"id": {S: aws.String("1")}}}); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("expected a throttled error, got %v", err)
+	}
+	if throttles != 2 {
+		t.Errorf("expected 2 throttles, got %d", throttles)
+	}
+
+	// Copies of the writer call their own Hooks.
+	cp := bw
+	var copyThrottles int
+	cp.Hooks = &Hooks{OnThrottle: func(err error) { copyThrottles++ }}
+	if err := cp.Write([]map[string]*dynamodb.AttributeValue{{"id": {S: aws.String("1")}}}); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("expected a throttled error, got %v", err)
+	}
+	if copyThrottles != 2 || throttles != 2 {
+		t.Errorf("expected 2 throttles for the copy only, got %d and %d", copyThrottles, throttles)
+	}
+}
+
+func TestErrBatchWrite(t *testing.T) {
+	records := []map[string]*dynamodb.AttributeValue{
+		{"id": {S: aws.String("1")}, "data": {S: aws.String("a")}},
+		{"id": {S: aws.String("2")}, "data": {S: aws.String("b")}},
+	}
+	client := &fakeClient{
+		responses: []*dynamodb.BatchWriteItemOutput{
+			{UnprocessedItems: map[string][]*dynamodb.WriteRequest{"table": {putRequest(records[1])}}},
+		},
+		errors: []error{nil},
+	}
+	bw := newTestBatchWriter(client)
+	bw.KeyNames = []string{"id"}
+	bw.Backoff = func(retry int) error { return ErrMaxBackoffReached }
+	err := bw.Write(records)
+	if !errors.Is(err, ErrThrottled) {
+		t.Errorf("expected throttled error, got %v", err)
+	}
+	if !errors.Is(err, ErrMaxBackoffReached) {
+		t.Errorf("expected max backoff error, got %v", err)
+	}
+	var bwe *ErrBatchWrite
+	if !errors.As(err, &bwe) {
+		t.Fatalf("expected ErrBatchWrite, got %v", err)
+	}
+	expectedKeys := []map[string]*dynamodb.AttributeValue{{"id": {S: aws.String("2")}}}
+	if diff := cmp.Diff(expectedKeys, bwe.Keys); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	records := []map[string]*dynamodb.AttributeValue{
+		{"id": {S: aws.String("1")}, "deleted": {BOOL: aws.Bool(false)}},
+		{"id": {S: aws.String("2")}, "deleted": {BOOL: aws.Bool(true)}},
+	}
+	client := &fakeClient{
+		responses: []*dynamodb.BatchWriteItemOutput{{}},
+		errors:    []error{nil},
+	}
+	bw := newTestBatchWriter(client)
+	bw.KeyNames = []string{"id"}
+	bw.Delete = func(record map[string]*dynamodb.AttributeValue) bool {
+		return aws.BoolValue(record["deleted"].BOOL)
+	}
+	if err := bw.Write(records); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []*dynamodb.WriteRequest{
+		putRequest(records[0]),
+		deleteRequest(map[string]*dynamodb.AttributeValue{"id": {S: aws.String("2")}}),
+	}
+	if diff := cmp.Diff(expected, client.inputs[0].RequestItems["table"]); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	rl := NewRateLimiter(50)
+	rl.last = now
+	rl.now = func() time.Time { return now }
+
+	// The first batch is allowed immediately.
+	if d := rl.reserve(25); d != 0 {
+		t.Errorf("expected no wait, got %v", d)
+	}
+	// Each following batch of 25 waits another half second at 50 records per second.
+	if d := rl.reserve(25); d != time.Millisecond*500 {
+		t.Errorf("expected 500ms, got %v", d)
+	}
+	if d := rl.reserve(25); d != time.Second {
+		t.Errorf("expected 1s, got %v", d)
+	}
+	// After a long pause, the bucket only refills to a single batch.
+	now = now.Add(time.Minute)
+	if d := rl.reserve(25); d != 0 {
+		t.Errorf("expected no wait, got %v", d)
+	}
+	if d := rl.reserve(25); d != time.Millisecond*500 {
+		t.Errorf("expected 500ms, got %v", d)
+	}
+}
+
+func TestLimiterIsUsedForRetries(t *testing.T) {
+	records := []map[string]*dynamodb.AttributeValue{
+		{"id": {S: aws.String("1")}},
+		{"id": {S: aws.String("2")}},
+	}
+	client := &fakeClient{
+		responses: []*dynamodb.BatchWriteItemOutput{
+			{UnprocessedItems: map[string][]*dynamodb.WriteRequest{"table": {putRequest(records[1])}}},
+			{},
+		},
+		errors: []error{nil, nil},
+	}
+	bw := newTestBatchWriter(client)
+	bw.Limiter = NewRateLimiter(1000)
+	var waited []time.Duration
+	bw.Limiter.sleep = func(d time.Duration) { waited = append(waited, d) }
+	bw.Limiter.now = func() time.Time { return bw.Limiter.last }
+	bw.Limiter.tokens = 0
+	if err := bw.Write(records); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []time.Duration{time.Millisecond * 2, time.Millisecond * 3}
+	if diff := cmp.Diff(expected, waited); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestOmitAttributes(t *testing.T) {
+	records := []map[string]*dynamodb.AttributeValue{
+		{"id": {S: aws.String("1")}, "Op": {S: aws.String("I")}},
+		{"id": {S: aws.String("2")}, "Op": {S: aws.String("D")}},
+		{"id": {S: aws.String("3")}},
+	}
+	bw := newTestBatchWriter(nil)
+	bw.KeyNames = []string{"id"}
+	bw.OmitAttributes = []string{"Op"}
+	bw.Delete = func(record map[string]*dynamodb.AttributeValue) bool {
+		return record["Op"] != nil && *record["Op"].S == "D"
+	}
+	expected := []*dynamodb.WriteRequest{
+		putRequest(map[string]*dynamodb.AttributeValue{"id": {S: aws.String("1")}}),
+		deleteRequest(map[string]*dynamodb.AttributeValue{"id": {S: aws.String("2")}}),
+		putRequest(map[string]*dynamodb.AttributeValue{"id": {S: aws.String("3")}}),
+	}
+	if diff := cmp.Diff(expected, bw.Requests(records)); diff != "" {
+		t.Error(diff)
+	}
+	if _, ok := records[0]["Op"]; !ok {
+		t.Error("expected the record not to be changed")
+	}
+}
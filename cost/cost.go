@@ -0,0 +1,36 @@
+// Package cost estimates the AWS cost of an import from the write capacity and Lambda compute
+// that it consumes, so that a mis-sized import can be stopped before it exceeds a budget.
+package cost
+
+// Prices in USD.
+type Prices struct {
+	// WriteCapacityUnit is the price of a DynamoDB on-demand write request unit.
+	WriteCapacityUnit float64
+	// LambdaGBSecond is the price of running a Lambda with 1GB of memory for a second.
+	LambdaGBSecond float64
+}
+
+// DefaultPrices are the us-east-1 prices, which are the same as, or lower than, most other
+// regions.
+var DefaultPrices = Prices{
+	WriteCapacityUnit: 1.25 / 1000000,
+	LambdaGBSecond:    0.0000166667,
+}
+
+// Estimate the cost of the write capacity units and Lambda GB-seconds consumed.
+func (p Prices) Estimate(wcu, gbSeconds float64) float64 {
+	return wcu*p.WriteCapacityUnit + gbSeconds*p.LambdaGBSecond
+}
+
+// Budget is the most that an import may cost. A zero MaxUSD is unlimited.
+type Budget struct {
+	MaxUSD float64
+	Prices Prices
+}
+
+// Check returns the estimated cost of the write capacity units and Lambda GB-seconds consumed,
+// and whether it exceeds the budget.
+func (b Budget) Check(wcu, gbSeconds float64) (usd float64, exceeded bool) {
+	usd = b.Prices.Estimate(wcu, gbSeconds)
+	return usd, b.MaxUSD > 0 && usd > b.MaxUSD
+}
@@ -0,0 +1,33 @@
+package cost
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBudget(t *testing.T) {
+	tests := []struct {
+		name             string
+		maxUSD           float64
+		wcu, gbSeconds   float64
+		expectedUSD      float64
+		expectedExceeded bool
+	}{
+		{name: "nothing consumed", maxUSD: 1},
+		{name: "write capacity", maxUSD: 1, wcu: 400000, expectedUSD: 0.5},
+		{name: "Lambda compute", maxUSD: 1, gbSeconds: 60000, expectedUSD: 1.000002, expectedExceeded: true},
+		{name: "write capacity and Lambda compute", maxUSD: 1, wcu: 1000000, gbSeconds: 6000, expectedUSD: 1.35, expectedExceeded: true},
+		{name: "unlimited", wcu: 1000000000, expectedUSD: 1250},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			usd, exceeded := Budget{MaxUSD: test.maxUSD, Prices: DefaultPrices}.Check(test.wcu, test.gbSeconds)
+			if math.Abs(usd-test.expectedUSD) > 0.00001 {
+				t.Errorf("expected $%v, got $%v", test.expectedUSD, usd)
+			}
+			if exceeded != test.expectedExceeded {
+				t.Errorf("expected exceeded %v, got %v", test.expectedExceeded, exceeded)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package csvtodynamo
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Faker replaces a value with a fake one. The hash is derived from the seed and the original
+// value, so that the same input always produces the same output, keeping joins between
+// anonymized columns and tables intact.
+type Faker func(hash []byte) string
+
+// Fakers available to anonymize columns, by name.
+var Fakers = map[string]Faker{
+	"name":  fakeName,
+	"email": fakeEmail,
+	"phone": fakePhone,
+	"hash":  fakeHash,
+}
+
+// FakerNames returns the names of the available Fakers.
+func FakerNames() (names []string) {
+	for k := range Fakers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AddAnonymizedKeys replaces the values of the keys with deterministic fake values generated by
+// the named faker, keyed by the seed. Empty values are left empty.
+func (conf *Configuration) AddAnonymizedKeys(seed, faker string, s ...string) (*Configuration, error) {
+	f, ok := Fakers[faker]
+	if !ok {
+		return conf, fmt.Errorf("csvtodynamo: unknown faker %q, expected one of %s", faker, strings.Join(FakerNames(), ", "))
+	}
+	if conf.Anonymizers == nil {
+		conf.Anonymizers = make(map[string]func(string) string)
+	}
+	key := []byte(seed)
+	for _, k := range s {
+		conf.Anonymizers[k] = func(value string) string {
+			mac := hmac.New(sha256.New, key)
+			mac.Write([]byte(value))
+			return f(mac.Sum(nil))
+		}
+	}
+	return conf, nil
+}
+
+var firstNames = []string{"Alex", "Charlie", "Drew", "Frankie", "Jamie", "Jordan", "Morgan", "Riley", "Robin", "Sam", "Taylor", "Toni"}
+var lastNames = []string{"Archer", "Baker", "Carter", "Fisher", "Fletcher", "Hunter", "Mason", "Miller", "Porter", "Smith", "Turner", "Walker"}
+
+func pick(values []string, b byte) string {
+	return values[int(b)%len(values)]
+}
+
+func fakeName(hash []byte) string {
+	return pick(firstNames, hash[0]) + " " + pick(lastNames, hash[1])
+}
+
+func fakeEmail(hash []byte) string {
+	return fmt.Sprintf("%s.%s.%d@example.com", strings.ToLower(pick(firstNames, hash[0])), strings.ToLower(pick(lastNames, hash[1])), binary.BigEndian.Uint32(hash[2:6]))
+}
+
+func fakePhone(hash []byte) string {
+	return fmt.Sprintf("+1555%07d", binary.BigEndian.Uint32(hash[0:4])%10000000)
+}
+
+func fakeHash(hash []byte) string {
+	return hex.EncodeToString(hash)
+}
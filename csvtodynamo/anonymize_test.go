@@ -0,0 +1,110 @@
+package csvtodynamo
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+func TestAnonymize(t *testing.T) {
+	input := strings.Join([]string{
+		"id,name,email,phone",
+		"1,Jane Doe,jane@example.org,01234567890",
+		"2,Jane Doe,,01234567890",
+	}, "\n")
+	read := func(seed string) (items []map[string]string) {
+		conf := NewConfiguration()
+		for _, k := range []string{"name", "email", "phone"} {
+			if _, err := conf.AddAnonymizedKeys(seed, k, k); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		c, err := NewConverter(csv.NewReader(strings.NewReader(input)), conf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		batch, _, _ := c.ReadBatch()
+		for _, item := range batch {
+			values := map[string]string{}
+			for k, v := range item {
+				values[k] = *v.S
+			}
+			items = append(items, values)
+		}
+		return
+	}
+	a, b, other := read("seed"), read("seed"), read("other")
+	if len(a) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(a))
+	}
+	if a[0]["id"] != "1" {
+		t.Errorf("expected id to be unchanged, got %q", a[0]["id"])
+	}
+	if a[0]["name"] == "Jane Doe" || !strings.HasSuffix(a[0]["email"], "@example.com") || !strings.HasPrefix(a[0]["phone"], "+1555") {
+		t.Errorf("expected values to be anonymized, got %v", a[0])
+	}
+	if a[0]["name"] != a[1]["name"] || a[0]["phone"] != a[1]["phone"] {
+		t.Errorf("expected the same input to produce the same output, got %v and %v", a[0], a[1])
+	}
+	if _, ok := a[1]["email"]; ok {
+		t.Errorf("expected empty values to remain empty, got %q", a[1]["email"])
+	}
+	if a[0]["email"] != b[0]["email"] {
+		t.Errorf("expected the same seed to produce the same output, got %q and %q", a[0]["email"], b[0]["email"])
+	}
+	if a[0]["email"] == other[0]["email"] {
+		t.Errorf("expected a different seed to produce different output, got %q", a[0]["email"])
+	}
+}
+
+func TestAnonymizeWithoutAnonymizers(t *testing.T) {
+	conf := &Configuration{}
+	if _, err := conf.AddAnonymizedKeys("seed", "hash", "a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := conf.Anonymizers["a"]; !ok {
+		t.Error("expected the anonymizer to be added")
+	}
+}
+
+func TestAnonymizeUnknownFaker(t *testing.T) {
+	if _, err := NewConfiguration().AddAnonymizedKeys("seed", "unknown", "a"); err == nil {
+		t.Error("expected an error for an unknown faker")
+	}
+}
+
+func TestAnonymizeRawAttributes(t *testing.T) {
+	conf := NewConfiguration()
+	if _, err := conf.AddAnonymizedKeys("seed", "hash", "name"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conf.RawAttribute = "raw"
+	conf.RowHashAttribute = "hash"
+	read := func(input string) map[string]*dynamodb.AttributeValue {
+		c, err := NewConverter(csv.NewReader(strings.NewReader("id,name\n"+input)), conf)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		batch, _, _ := c.ReadBatch()
+		if len(batch) != 1 {
+			t.Fatalf("expected 1 item, got %d", len(batch))
+		}
+		return batch[0]
+	}
+	item := read("1,Jane Doe")
+	if expected := "1," + *item["name"].S; *item["raw"].S != expected {
+		t.Errorf("expected the raw attribute to contain the anonymized value %q, got %q", expected, *item["raw"].S)
+	}
+	// The hash of the original row would reveal the value, e.g. by hashing candidate names.
+	original := sha256.Sum256([]byte("1,Jane Doe"))
+	if *item["hash"].S == hex.EncodeToString(original[:]) {
+		t.Error("expected the row hash not to be the hash of the original row")
+	}
+	if other := read("1,John Doe"); *other["hash"].S == *item["hash"].S {
+		t.Error("expected rows with different values to have different hashes")
+	}
+}
@@ -1,10 +1,21 @@
 package csvtodynamo
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
@@ -14,14 +25,26 @@ type Converter struct {
 	conf                 *Configuration
 	columnNames          []string
 	columnNamesToInclude map[string]bool
+	rows                 int64
+	records              int64
+	position             Positioner
+	// line and offset are the position of the current row, reported in errors.
+	line, offset int64
+	random       *rand.Rand
+	seenKeys     map[string]int64
+	emptyValues  map[string]int64
+	leadingZeros map[string]int64
+	// cleanedColumns are the names in the header which were changed by CleanColumnName.
+	cleanedColumns map[string]string
 }
 
-type keyConverter func(s string) *dynamodb.AttributeValue
+type keyConverter func(s string) (*dynamodb.AttributeValue, error)
 
 // NewConfiguration creates the Configuration for the Converter.
 func NewConfiguration() *Configuration {
 	return &Configuration{
 		KeyToConverter: map[string]keyConverter{},
+		Anonymizers:    map[string]func(string) string{},
 	}
 }
 
@@ -30,12 +53,127 @@ type Configuration struct {
 	KeyToConverter map[string]keyConverter
 	Columns        []string
 	KeyColumns     []string
+	Lookups        []*Lookup
+	Anonymizers    map[string]func(string) string
+	// SampleRate is the probability of each row being read, e.g. 0.01 reads approximately 1%
+	// of rows. Zero reads every row.
+	SampleRate float64
+	// SampleEvery reads only every nth row. Zero reads every row.
+	SampleEvery int64
+	// SampleSeed seeds the random number generator used by SampleRate.
+	SampleSeed int64
+	// RawAttribute is the name of an attribute to store the source row in, re-encoded as CSV.
+	// The values of ExcludedColumns are left empty, and the values of columns with Anonymizers
+	// are anonymized.
+	RawAttribute string
+	// SkipRepeatedHeaders treats any row containing the same set of column names as the header
+	// as a new header, rather than data. This supports inputs made by concatenating files
+	// which each have their own header row. The column order of the new header is used for
+	// the rows which follow it.
+	SkipRepeatedHeaders bool
+	// TableKeys are the names of the partition key and sort key of the table.
+	TableKeys []string
+	// RequireTableKeys checks that every row has a value for all of the TableKeys.
+	RequireTableKeys bool
+	// CheckUniqueTableKeys checks that every row has a different combination of values
+	// for the TableKeys. The keys of every row are held in memory.
+	CheckUniqueTableKeys bool
+	// RowHashAttribute is the name of an attribute to store the hex encoded SHA-256 hash of
+	// the source row in, re-encoded as CSV in the same way as the RawAttribute.
+	RowHashAttribute string
+	// NullTokens are values which are treated as null in every column, e.g. "NULL", `\N` or "-".
+	// Null values are omitted from the item, in the same way as empty values, unless the column
+	// is one of the NullKeys.
+	NullTokens []string
+	// ColumnNullTokens are values which are treated as null in a single column, in addition to
+	// the NullTokens.
+	ColumnNullTokens map[string][]string
+	// NullKeys are the columns whose null values are stored as the DynamoDB NULL type, instead of
+	// being omitted. Empty values are still omitted.
+	NullKeys map[string]bool
+	// OptionalFirstColumn is the name of a column which is only present in some rows, before
+	// the other columns, e.g. the operation column of AWS DMS files, which is omitted from full
+	// load files. Rows with one more value than there are columns start with this column.
+	OptionalFirstColumn string
+	// TimestampLayout is the time.Parse layout of timestamp values, which are converted to
+	// RFC 3339 in UTC. Values which don't match the layout are unchanged.
+	TimestampLayout string
+	// SkipRows is the number of rows to skip before the header, e.g. a preamble describing the
+	// file. Skipped rows can have any number of values. Blank lines aren't counted.
+	SkipRows int
+	// Comment is the character which starts comment lines, e.g. '#'. Comment lines are skipped
+	// wherever they are in the file, including before the header. Zero doesn't skip any lines.
+	Comment rune
+	// LazyQuotes allows a quote to appear in an unquoted value, and a quote which isn't doubled
+	// to appear in a quoted value, as written by some spreadsheet exports.
+	LazyQuotes bool
+	// TrimLeadingSpace ignores spaces at the start of values, e.g. after "a, b, c" delimiters.
+	TrimLeadingSpace bool
+	// RaggedRows allows rows to have a different number of values to the header. Missing values
+	// at the end of short rows are treated as empty, and extra values at the end of long rows are
+	// ignored.
+	RaggedRows bool
+	// Strict makes values which can't be converted to the type of their column an
+	// ErrInvalidValue, instead of storing false for unknown booleans, ignoring invalid base64
+	// and map JSON, and storing numbers that DynamoDB rejects when the batch is written.
+	Strict bool
+	// ListDelimiter separates the elements of list values which aren't JSON arrays. Defaults
+	// to a comma.
+	ListDelimiter string
+	// TimestampLocation is the time zone of the values of timestamp keys whose layout doesn't
+	// include one. Defaults to UTC.
+	TimestampLocation *time.Location
+	// TTLAttribute is the name of a number attribute to store an expiry time in, as seconds
+	// since the Unix epoch, for DynamoDB's time to live. The expiry time is the TTLOffset after
+	// the value of the TTLColumn, or after TTLFrom if there's no TTLColumn.
+	TTLAttribute string
+	// TTLColumn is a timestamp column to calculate the expiry time from. Values are parsed with
+	// the TTLLayout in the TimestampLocation. Rows without a value have no expiry time.
+	TTLColumn string
+	// TTLLayout is the time.Parse layout of the values of the TTLColumn.
+	TTLLayout string
+	// TTLFrom is the time to calculate the expiry time from when there's no TTLColumn, usually
+	// the start of the import.
+	TTLFrom time.Time
+	// TTLOffset is added to the time the expiry time is calculated from.
+	TTLOffset time.Duration
+	// Templates make attributes from the values of other columns, keyed by attribute name. See
+	// AddTemplateKey.
+	Templates map[string]*template.Template
+	// Constants are attributes added to every item, keyed by attribute name. They replace
+	// columns with the same name.
+	Constants map[string]*dynamodb.AttributeValue
+	// Rules validate the values of columns, keyed by column name. See AddRule.
+	Rules map[string]Rule
+	// Defaults are the values of columns, keyed by column name, used when a cell is empty, or is
+	// null and the column isn't one of the NullKeys. They're converted in the same way as the
+	// values of the column, so a default for a number column must be a number.
+	Defaults map[string]string
+	// Started is the time the import started, which the now function of templates returns.
+	Started time.Time
+	// ExcludedColumns are left out of the item.
+	ExcludedColumns map[string]bool
+	// AttributeNames maps column names to the names of the attributes they're stored in, for
+	// columns which are stored in an attribute with a different name.
+	AttributeNames map[string]string
+	// SetDelimiter separates the elements of string, number and binary set values. Defaults to
+	// a comma.
+	SetDelimiter string
+	// Normalization is applied to the values of every column which doesn't have its own, see
+	// AddNormalization, before they're converted, checked against Rules, or used by templates.
+	Normalization Normalization
+	// Normalizations of values, keyed by column name.
+	Normalizations map[string]Normalization
+	// NestSeparator, if set, folds the attributes of columns whose names contain it into nested
+	// maps, e.g. with a separator of ".", the address.city and address.zip columns are stored
+	// in an address map with city and zip attributes.
+	NestSeparator string
 }
 
 // AddStringKeys add string keys to the configuration.
 func (conf *Configuration) AddStringKeys(s ...string) *Configuration {
 	for _, k := range s {
-		conf.KeyToConverter[k] = stringValue
+		conf.KeyToConverter[k] = lenient(stringValue)
 	}
 	return conf
 }
@@ -43,7 +181,7 @@ func (conf *Configuration) AddStringKeys(s ...string) *Configuration {
 // AddNumberKeys adds numeric keys to the configuration.
 func (conf *Configuration) AddNumberKeys(s ...string) *Configuration {
 	for _, k := range s {
-		conf.KeyToConverter[k] = numberValue
+		conf.KeyToConverter[k] = conf.numberValue
 	}
 	return conf
 }
@@ -51,25 +189,256 @@ func (conf *Configuration) AddNumberKeys(s ...string) *Configuration {
 // AddBoolKeys adds boolean keys to the configuration.
 func (conf *Configuration) AddBoolKeys(s ...string) *Configuration {
 	for _, k := range s {
-		conf.KeyToConverter[k] = boolValue
+		conf.KeyToConverter[k] = conf.boolValue
 	}
 	return conf
 }
 
 func (conf *Configuration) AddMapKeys(s ...string) *Configuration {
 	for _, k := range s {
-		conf.KeyToConverter[k] = mapValue
+		conf.KeyToConverter[k] = conf.mapValue
 	}
 	return conf
 }
 
+// AddListKeys adds list keys to the configuration. Values which start with '[' are parsed as a
+// JSON array, e.g. '["a", 1, true]', where strings are stored as S, numbers as N, booleans as
+// BOOL, null as NULL, objects as M and arrays as L. Other values are split on the ListDelimiter
+// into a list of strings, e.g. 'a,b,c'.
+func (conf *Configuration) AddListKeys(s ...string) *Configuration {
+	for _, k := range s {
+		conf.KeyToConverter[k] = func(value string) (*dynamodb.AttributeValue, error) {
+			return listValue(value, conf.ListDelimiter)
+		}
+	}
+	return conf
+}
+
+// AddStringSetKeys adds string set keys to the configuration. Values are split on the
+// SetDelimiter, e.g. 'red,green' is stored as an SS of "red" and "green". Spaces around each
+// element, empty elements and duplicates are removed, because DynamoDB doesn't allow empty or
+// duplicate elements in sets.
+func (conf *Configuration) AddStringSetKeys(s ...string) *Configuration {
+	for _, k := range s {
+		conf.KeyToConverter[k] = lenient(func(value string) *dynamodb.AttributeValue {
+			return setValue(value, conf.SetDelimiter, (*dynamodb.AttributeValue).SetSS)
+		})
+	}
+	return conf
+}
+
+// AddNumberSetKeys adds number set keys to the configuration, which are split in the same way
+// as string sets.
+func (conf *Configuration) AddNumberSetKeys(s ...string) *Configuration {
+	for _, k := range s {
+		conf.KeyToConverter[k] = func(value string) (*dynamodb.AttributeValue, error) {
+			av := setValue(value, conf.SetDelimiter, (*dynamodb.AttributeValue).SetNS)
+			if conf.Strict && av != nil {
+				for _, n := range av.NS {
+					if !dynamoNumber.MatchString(*n) {
+						return nil, &ErrInvalidValue{Type: TypeNumberSet, Value: value, Cause: fmt.Errorf("%q is not a number", *n)}
+					}
+				}
+			}
+			return av, nil
+		}
+	}
+	return conf
+}
+
+// AddBinarySetKeys adds binary set keys to the configuration, where each element of the set is
+// base64 encoded. Values are split in the same way as string sets.
+func (conf *Configuration) AddBinarySetKeys(s ...string) *Configuration {
+	for _, k := range s {
+		conf.KeyToConverter[k] = func(value string) (*dynamodb.AttributeValue, error) {
+			elements := setElements(value, conf.SetDelimiter)
+			if len(elements) == 0 {
+				return nil, nil
+			}
+			bs := make([][]byte, len(elements))
+			for i, e := range elements {
+				var err error
+				if bs[i], err = base64.StdEncoding.DecodeString(e); err != nil && conf.Strict {
+					return nil, &ErrInvalidValue{Type: TypeBinarySet, Value: value, Cause: err}
+				}
+			}
+			return (&dynamodb.AttributeValue{}).SetBS(bs), nil
+		}
+	}
+	return conf
+}
+
+// AddNullKeys stores null values in the columns as the DynamoDB NULL type, instead of omitting
+// them. See NullTokens.
+func (conf *Configuration) AddNullKeys(s ...string) *Configuration {
+	if conf.NullKeys == nil {
+		conf.NullKeys = make(map[string]bool, len(s))
+	}
+	for _, k := range s {
+		conf.NullKeys[k] = true
+	}
+	return conf
+}
+
+// AddExcludedColumns leaves the columns out of the item, e.g. personal data or internal IDs.
+// Their values can still be used by templates, but aren't stored in the RawAttribute.
+func (conf *Configuration) AddExcludedColumns(s ...string) *Configuration {
+	if conf.ExcludedColumns == nil {
+		conf.ExcludedColumns = make(map[string]bool, len(s))
+	}
+	for _, k := range s {
+		conf.ExcludedColumns[k] = true
+	}
+	return conf
+}
+
+// AddAttributeName stores the column in the named attribute, instead of an attribute with the
+// same name as the column. The types of columns are still configured using the column name.
+func (conf *Configuration) AddAttributeName(column, attribute string) *Configuration {
+	if conf.AttributeNames == nil {
+		conf.AttributeNames = make(map[string]string)
+	}
+	conf.AttributeNames[column] = attribute
+	return conf
+}
+
 func (conf *Configuration) AddBinKeys(s ...string) *Configuration {
 	for _, k := range s {
-		conf.KeyToConverter[k] = binValue
+		conf.KeyToConverter[k] = conf.binValue
+	}
+	return conf
+}
+
+// AddHexBinKeys adds binary keys whose values are hex encoded, as written by many database
+// dumps, e.g. '0a1b', '0x0A1B' or '\x0a1b'. Values which aren't valid hex are always an
+// ErrInvalidValue, even when not Strict, because a partly decoded value can't be recovered.
+func (conf *Configuration) AddHexBinKeys(s ...string) *Configuration {
+	for _, k := range s {
+		conf.KeyToConverter[k] = hexBinValue
+	}
+	return conf
+}
+
+// AddEnumKeys adds string keys which must have one of the values. Empty values are omitted
+// from the item, in the same way as other keys.
+func (conf *Configuration) AddEnumKeys(values []string, s ...string) *Configuration {
+	allowed := make(map[string]bool, len(values))
+	for _, v := range values {
+		allowed[v] = true
+	}
+	for _, k := range s {
+		conf.KeyToConverter[k] = func(value string) (*dynamodb.AttributeValue, error) {
+			if !allowed[value] {
+				return nil, fmt.Errorf("%w %q, expected one of %s", ErrUnexpectedValue, value, strings.Join(values, ", "))
+			}
+			return stringValue(value), nil
+		}
+	}
+	return conf
+}
+
+// Formats that AddTimestampKeys stores timestamps in.
+const (
+	// TimestampISO8601 stores timestamps as RFC 3339 strings in UTC, which sort in time order,
+	// e.g. "2021-03-04T10:00:00Z".
+	TimestampISO8601 = "iso8601"
+	// TimestampEpoch stores timestamps as the number of seconds since the Unix epoch, as
+	// required by DynamoDB's time to live.
+	TimestampEpoch = "epoch"
+	// TimestampEpochMillis stores timestamps as the number of milliseconds since the Unix epoch.
+	TimestampEpochMillis = "epochMillis"
+)
+
+// TimestampFormats are the formats that AddTimestampKeys accepts.
+var TimestampFormats = []string{TimestampISO8601, TimestampEpoch, TimestampEpochMillis}
+
+// AddTimestampKeys adds timestamp keys to the configuration. Values are parsed with the
+// time.Parse layout, e.g. "2006-01-02 15:04:05", in the TimestampLocation, and stored in the
+// format, one of the TimestampFormats. Values which don't match the layout are an
+// ErrInvalidTimestamp.
+func (conf *Configuration) AddTimestampKeys(layout, format string, s ...string) (*Configuration, error) {
+	var store func(t time.Time) *dynamodb.AttributeValue
+	switch format {
+	case TimestampISO8601:
+		store = func(t time.Time) *dynamodb.AttributeValue { return stringValue(t.UTC().Format(time.RFC3339Nano)) }
+	case TimestampEpoch:
+		store = func(t time.Time) *dynamodb.AttributeValue { return numberValue(strconv.FormatInt(t.Unix(), 10)) }
+	case TimestampEpochMillis:
+		store = func(t time.Time) *dynamodb.AttributeValue {
+			return numberValue(strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10))
+		}
+	default:
+		return conf, fmt.Errorf("csvtodynamo: unknown timestamp format %q, expected one of %s", format, strings.Join(TimestampFormats, ", "))
+	}
+	for _, k := range s {
+		conf.KeyToConverter[k] = func(value string) (*dynamodb.AttributeValue, error) {
+			loc := conf.TimestampLocation
+			if loc == nil {
+				loc = time.UTC
+			}
+			t, err := time.ParseInLocation(layout, value, loc)
+			if err != nil {
+				return nil, fmt.Errorf("%w %q, expected layout %q", ErrInvalidTimestamp, value, layout)
+			}
+			return store(t), nil
+		}
 	}
+	return conf, nil
+}
+
+// AddConstant adds an attribute with the same value to every item, e.g. an entity type.
+func (conf *Configuration) AddConstant(attribute string, value *dynamodb.AttributeValue) *Configuration {
+	if conf.Constants == nil {
+		conf.Constants = make(map[string]*dynamodb.AttributeValue)
+	}
+	conf.Constants[attribute] = value
+	return conf
+}
+
+// AddDefault sets the value used when a cell of the column is empty, instead of leaving the
+// attribute out of the item, e.g. so that a sparse index includes every item.
+func (conf *Configuration) AddDefault(column, value string) *Configuration {
+	if conf.Defaults == nil {
+		conf.Defaults = make(map[string]string)
+	}
+	conf.Defaults[column] = value
 	return conf
 }
 
+// AddTemplateKey adds a string attribute made from the values of other columns of the row using
+// a text/template, e.g. "USER#{{.user_id}}", for composite keys. Columns whose names aren't
+// valid template identifiers can be used with index, e.g. `{{index . "user id"}}`. Using a
+// column which is empty or null is an ErrTemplate, so that keys aren't made from missing
+// values. Excluded columns can still be used.
+//
+// As well as the built in functions of text/template, templates can use now, which returns the
+// Started time in RFC 3339 format, upper, lower, trim, which removes leading and trailing
+// spaces, and replace, e.g. `{{.name | replace " " "_" | lower}}`.
+func (conf *Configuration) AddTemplateKey(attribute, text string) (*Configuration, error) {
+	t, err := template.New(attribute).Option("missingkey=error").Funcs(conf.templateFuncs()).Parse(text)
+	if err != nil {
+		return conf, fmt.Errorf("csvtodynamo: invalid template for attribute %q: %w", attribute, err)
+	}
+	if conf.Templates == nil {
+		conf.Templates = make(map[string]*template.Template)
+	}
+	conf.Templates[attribute] = t
+	return conf, nil
+}
+
+// templateFuncs returns the functions available to templates.
+func (conf *Configuration) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"now":   func() string { return conf.Started.UTC().Format(time.RFC3339) },
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"trim":  strings.TrimSpace,
+		"replace": func(old, new, s string) string {
+			return strings.Replace(s, old, new, -1)
+		},
+	}
+}
+
 func (conf *Configuration) AddKeyColumns(s ...string) *Configuration {
 	for _, k := range s {
 		conf.KeyColumns = append(conf.KeyColumns, k)
@@ -77,6 +446,12 @@ func (conf *Configuration) AddKeyColumns(s ...string) *Configuration {
 	return conf
 }
 
+// AddLookups adds lookup tables used to enrich each item.
+func (conf *Configuration) AddLookups(l ...*Lookup) *Configuration {
+	conf.Lookups = append(conf.Lookups, l...)
+	return conf
+}
+
 func (c *Converter) init() error {
 	if len(c.conf.KeyColumns) > 0 {
 		c.columnNamesToInclude = make(map[string]bool)
@@ -84,6 +459,10 @@ func (c *Converter) init() error {
 			c.columnNamesToInclude[k] = true
 		}
 	}
+	if err := SkipRows(c.r, c.conf.SkipRows); err != nil {
+		return err
+	}
+	c.records += int64(c.conf.SkipRows)
 	if len(c.conf.Columns) > 0 {
 		c.columnNames = c.conf.Columns
 		return nil
@@ -92,12 +471,51 @@ func (c *Converter) init() error {
 	if err != nil {
 		return err
 	}
+	c.records++
 	if c.columnNames == nil {
-		c.columnNames = record
+		c.columnNames, c.cleanedColumns = cleanHeader(record)
 	}
 	return nil
 }
 
+// SkipRows reads and discards n rows from r. The rows can have any number of values, and
+// unescaped quotes, since they're usually not part of the CSV data.
+func SkipRows(r *csv.Reader, n int) error {
+	if n == 0 {
+		return nil
+	}
+	fieldsPerRecord, lazyQuotes := r.FieldsPerRecord, r.LazyQuotes
+	r.FieldsPerRecord, r.LazyQuotes = -1, true
+	defer func() {
+		r.FieldsPerRecord, r.LazyQuotes = fieldsPerRecord, lazyQuotes
+	}()
+	for i := 0; i < n; i++ {
+		if _, err := r.Read(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EmptyValues returns the number of empty and null values of each column which were omitted
+// from items, so that problems such as a column which is always empty because of the wrong
+// delimiter can be reported. Columns without any omitted values aren't included.
+func (c *Converter) EmptyValues() map[string]int64 {
+	return c.emptyValues
+}
+
+// LeadingZeros returns the number of values of each column which were stored as numbers, but
+// had leading zeros that DynamoDB removes, e.g. IDs and zip codes, which should be strings.
+// Columns without any such values aren't included.
+func (c *Converter) LeadingZeros() map[string]int64 {
+	return c.leadingZeros
+}
+
+// Columns returns the column names of the CSV.
+func (c *Converter) Columns() []string {
+	return c.columnNames
+}
+
 // ReadBatch reads 25 items from the CSV.
 // Only strings, numbers and boolean values are supported in CSV.
 func (c *Converter) ReadBatch() (items []map[string]*dynamodb.AttributeValue, read int, err error) {
@@ -112,22 +530,338 @@ func (c *Converter) ReadBatch() (items []map[string]*dynamodb.AttributeValue, re
 	return items[:read], read, err
 }
 
+// Positioner returns the number of lines and bytes read from an input, e.g. a
+// linereader.LineReader.
+type Positioner interface {
+	Position() (lines, offset int64)
+}
+
+// TrackPosition reports the line number and byte offset of rows in errors using the position of
+// p, which the csv.Reader of the Converter reads from. p must not read past the end of the line
+// it's reading, so that its position is the end of the last row. Starting p from the position
+// of a part of a file, e.g. a partition, reports positions within the whole file.
+func (c *Converter) TrackPosition(p Positioner) {
+	c.position = p
+}
+
 // Read a single item from the CSV.
 func (c *Converter) Read() (items map[string]*dynamodb.AttributeValue, err error) {
-	record, err := c.r.Read()
-	if err != nil {
+	var record []string
+	for {
+		if c.position != nil {
+			lines, offset := c.position.Position()
+			c.line, c.offset = lines+1, offset
+		}
+		record, err = c.r.Read()
+		if err != nil {
+			var pe *csv.ParseError
+			if errors.As(err, &pe) {
+				rce := &ErrRowConversion{Line: int64(pe.Line), Cause: err}
+				if c.position != nil {
+					// The line numbers of the ParseError are relative to the csv.Reader.
+					rce.Line, rce.Offset = c.line+int64(pe.Line-pe.StartLine), c.offset
+					rce.Cause = pe.Err
+					if pe.Err != csv.ErrFieldCount {
+						rce.Cause = fmt.Errorf("character %d: %w", pe.Column, pe.Err)
+					}
+				}
+				err = rce
+			}
+			return
+		}
+		c.records++
+		if c.position == nil {
+			c.line = c.records
+		}
+		if c.conf.SkipRepeatedHeaders {
+			// Repeated headers of concatenated files can start with a byte order mark.
+			if header, cleaned := cleanHeader(record); c.isHeader(header) {
+				c.columnNames = header
+				for original, name := range cleaned {
+					if c.cleanedColumns == nil {
+						c.cleanedColumns = make(map[string]string)
+					}
+					c.cleanedColumns[original] = name
+				}
+				continue
+			}
+		}
+		c.rows++
+		if c.sampled() {
+			break
+		}
+	}
+	if items, err = c.convert(record); err != nil {
 		return
 	}
-	items = make(map[string]*dynamodb.AttributeValue, len(record))
-	for i, column := range c.columnNames {
-		if len(c.columnNamesToInclude) > 0 && !c.columnNamesToInclude[column] {
+	err = c.checkKeys(items)
+	return
+}
+
+// checkKeys validates the item against the TableKeys.
+func (c *Converter) checkKeys(item map[string]*dynamodb.AttributeValue) error {
+	if !c.conf.RequireTableKeys && !c.conf.CheckUniqueTableKeys {
+		return nil
+	}
+	var sb strings.Builder
+	for _, k := range c.conf.TableKeys {
+		v, ok := item[k]
+		if !ok {
+			if c.conf.RequireTableKeys {
+				return c.rowError(k, ErrMissingKey)
+			}
 			continue
 		}
-		if len(record[i]) != 0 {
-			items[column] = c.dynamoValue(column, record[i])
+		sb.WriteString(aws.StringValue(v.S))
+		sb.WriteString(aws.StringValue(v.N))
+		sb.Write(v.B)
+		sb.WriteByte(0)
+	}
+	if !c.conf.CheckUniqueTableKeys {
+		return nil
+	}
+	if c.seenKeys == nil {
+		c.seenKeys = make(map[string]int64)
+	}
+	key := sb.String()
+	if previous, ok := c.seenKeys[key]; ok {
+		return c.rowError(strings.Join(c.conf.TableKeys, ","), fmt.Errorf("%w: same as line %d", ErrDuplicateKey, previous))
+	}
+	c.seenKeys[key] = c.line
+	return nil
+}
+
+// rowError returns an ErrRowConversion at the position of the current row.
+func (c *Converter) rowError(column string, cause error) *ErrRowConversion {
+	return &ErrRowConversion{Line: c.line, Offset: c.offset, Column: column, Cause: cause}
+}
+
+// isHeader returns true if the record contains the same set of values as the column names.
+func (c *Converter) isHeader(record []string) bool {
+	if len(record) != len(c.columnNames) {
+		return false
+	}
+	names := make(map[string]bool, len(c.columnNames))
+	for _, name := range c.columnNames {
+		names[name] = true
+	}
+	for _, value := range record {
+		if !names[value] {
+			return false
+		}
+	}
+	return true
+}
+
+// sampled returns true if the current row should be read.
+func (c *Converter) sampled() bool {
+	if c.conf.SampleEvery > 1 && c.rows%c.conf.SampleEvery != 0 {
+		return false
+	}
+	if c.conf.SampleRate > 0 && c.conf.SampleRate < 1 && c.random.Float64() >= c.conf.SampleRate {
+		return false
+	}
+	return true
+}
+
+func (c *Converter) convert(record []string) (item map[string]*dynamodb.AttributeValue, err error) {
+	item = make(map[string]*dynamodb.AttributeValue, len(record))
+	columnNames := c.columnNames
+	if c.conf.OptionalFirstColumn != "" {
+		if len(record) == len(columnNames)+1 {
+			columnNames = append([]string{c.conf.OptionalFirstColumn}, columnNames...)
+		}
+		if len(record) != len(columnNames) && !c.conf.RaggedRows {
+			return nil, c.rowError("", csv.ErrFieldCount)
+		}
+	}
+	values := record
+	if c.conf.RaggedRows && len(values) != len(columnNames) {
+		values = fit(values, len(columnNames))
+	}
+	values = c.normalize(columnNames, values)
+	for i, column := range columnNames {
+		if c.conf.ExcludedColumns[column] {
+			continue
+		}
+		attribute := column
+		if name, ok := c.conf.AttributeNames[column]; ok {
+			attribute = name
+		}
+		if len(c.columnNamesToInclude) > 0 && !c.columnNamesToInclude[attribute] {
+			continue
+		}
+		value := values[i]
+		if r, ok := c.conf.Rules[column]; ok && len(value) > 0 && !c.isNull(column, value) {
+			if v := r.check(value); v != nil {
+				return nil, c.rowError(column, v)
+			}
+		}
+		if len(value) == 0 || c.isNull(column, value) {
+			if len(value) > 0 && c.conf.NullKeys[column] {
+				item[attribute] = nullValue
+				continue
+			}
+			d, ok := c.conf.Defaults[column]
+			if !ok {
+				c.emptyValues[column]++
+				continue
+			}
+			value = d
+		}
+		av, err := c.dynamoValue(column, c.anonymize(column, c.timestamp(value)))
+		if err != nil {
+			return nil, c.rowError(column, err)
+		}
+		if av != nil && av.N != nil && leadingZeros.MatchString(value) {
+			c.leadingZeros[column]++
+		}
+		// Sets without any elements are omitted, in the same way as empty values.
+		if av != nil {
+			item[attribute] = av
+		}
+	}
+	if c.conf.NestSeparator != "" {
+		if attribute, err := c.nest(item); err != nil {
+			return nil, c.rowError(attribute, err)
+		}
+	}
+	if c.conf.TTLAttribute != "" {
+		av, err := c.ttl(columnNames, values)
+		if err != nil {
+			return nil, c.rowError(c.conf.TTLColumn, err)
+		}
+		if av != nil {
+			item[c.conf.TTLAttribute] = av
+		}
+	}
+	for attribute, av := range c.conf.Constants {
+		item[attribute] = av
+	}
+	if len(c.conf.Templates) > 0 {
+		if err = c.applyTemplates(columnNames, values, item); err != nil {
+			return nil, err
+		}
+	}
+	for _, l := range c.conf.Lookups {
+		l.enrich(columnNames, values, item)
+	}
+	if c.conf.RawAttribute != "" || c.conf.RowHashAttribute != "" {
+		raw := c.raw(c.rawValues(columnNames, record))
+		if c.conf.RawAttribute != "" {
+			item[c.conf.RawAttribute] = stringValue(raw)
+		}
+		if c.conf.RowHashAttribute != "" {
+			hash := sha256.Sum256([]byte(raw))
+			item[c.conf.RowHashAttribute] = stringValue(hex.EncodeToString(hash[:]))
+		}
+	}
+	return item, nil
+}
+
+// applyTemplates adds the attributes of the Templates to the item. Only the columns with values
+// are passed to the templates, so that using an empty column is an error.
+func (c *Converter) applyTemplates(columnNames, values []string, item map[string]*dynamodb.AttributeValue) error {
+	row := make(map[string]string, len(columnNames))
+	for i, column := range columnNames {
+		if values[i] != "" && !c.isNull(column, values[i]) {
+			row[column] = values[i]
+		}
+	}
+	var sb strings.Builder
+	for attribute, t := range c.conf.Templates {
+		sb.Reset()
+		if err := t.Execute(&sb, row); err != nil {
+			return c.rowError(attribute, fmt.Errorf("%w: %v", ErrTemplate, err))
+		}
+		if sb.Len() > 0 {
+			item[attribute] = stringValue(sb.String())
 		}
 	}
-	return items, err
+	return nil
+}
+
+// ttl returns the expiry time of the row, or nil if the row has no value for the TTLColumn.
+func (c *Converter) ttl(columnNames, values []string) (*dynamodb.AttributeValue, error) {
+	from := c.conf.TTLFrom
+	if c.conf.TTLColumn != "" {
+		var value string
+		for i, column := range columnNames {
+			if column == c.conf.TTLColumn {
+				value = values[i]
+				break
+			}
+		}
+		if value == "" || c.isNull(c.conf.TTLColumn, value) {
+			return nil, nil
+		}
+		loc := c.conf.TimestampLocation
+		if loc == nil {
+			loc = time.UTC
+		}
+		var err error
+		if from, err = time.ParseInLocation(c.conf.TTLLayout, value, loc); err != nil {
+			return nil, fmt.Errorf("%w %q, expected layout %q", ErrInvalidTimestamp, value, c.conf.TTLLayout)
+		}
+	}
+	return numberValue(strconv.FormatInt(from.Add(c.conf.TTLOffset).Unix(), 10)), nil
+}
+
+// isNull returns true if the value is one of the NullTokens, or one of the ColumnNullTokens of
+// the column.
+func (c *Converter) isNull(column, value string) bool {
+	for _, t := range c.conf.NullTokens {
+		if value == t {
+			return true
+		}
+	}
+	for _, t := range c.conf.ColumnNullTokens[column] {
+		if value == t {
+			return true
+		}
+	}
+	return false
+}
+
+// fit pads the record with empty values, or truncates it, so that it has n values.
+func fit(record []string, n int) []string {
+	fitted := make([]string, n)
+	copy(fitted, record)
+	return fitted
+}
+
+// rawValues returns a copy of the record with the values of the ExcludedColumns left empty,
+// and the values of the anonymized columns anonymized, so that the original values aren't
+// stored in the RawAttribute, or revealed by the RowHashAttribute.
+func (c *Converter) rawValues(columnNames, record []string) []string {
+	if len(c.conf.ExcludedColumns) == 0 && len(c.conf.Anonymizers) == 0 {
+		return record
+	}
+	redacted := make([]string, len(record))
+	copy(redacted, record)
+	for i, column := range columnNames {
+		if i >= len(redacted) {
+			break
+		}
+		if c.conf.ExcludedColumns[column] {
+			redacted[i] = ""
+			continue
+		}
+		if redacted[i] != "" && !c.isNull(column, redacted[i]) {
+			redacted[i] = c.anonymize(column, redacted[i])
+		}
+	}
+	return redacted
+}
+
+// raw re-encodes the record as a CSV line, using the delimiter of the input.
+func (c *Converter) raw(record []string) string {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Comma = c.r.Comma
+	w.Write(record)
+	w.Flush()
+	return strings.TrimSuffix(sb.String(), "\n")
 }
 
 // NewConverter creates a new CSV to DynamoDB converter.
@@ -136,18 +870,56 @@ func NewConverter(r *csv.Reader, conf *Configuration) (*Converter, error) {
 		conf = NewConfiguration()
 	}
 	c := &Converter{
-		r:    r,
-		conf: conf,
+		r:            r,
+		conf:         conf,
+		random:       rand.New(rand.NewSource(conf.SampleSeed)),
+		emptyValues:  make(map[string]int64),
+		leadingZeros: make(map[string]int64),
+	}
+	if conf.OptionalFirstColumn != "" || conf.RaggedRows {
+		// The number of values is checked against the columns during conversion.
+		r.FieldsPerRecord = -1
+	}
+	r.LazyQuotes = r.LazyQuotes || conf.LazyQuotes
+	r.TrimLeadingSpace = r.TrimLeadingSpace || conf.TrimLeadingSpace
+	if conf.Comment != 0 {
+		r.Comment = conf.Comment
 	}
 	err := c.init()
 	return c, err
 }
 
-func (c *Converter) dynamoValue(key, value string) *dynamodb.AttributeValue {
+// timestamp converts values which match the TimestampLayout to RFC 3339.
+func (c *Converter) timestamp(value string) string {
+	if c.conf.TimestampLayout == "" {
+		return value
+	}
+	t, err := time.Parse(c.conf.TimestampLayout, value)
+	if err != nil {
+		return value
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func (c *Converter) anonymize(key, value string) string {
+	if f, ok := c.conf.Anonymizers[key]; ok {
+		return f(value)
+	}
+	return value
+}
+
+func (c *Converter) dynamoValue(key, value string) (*dynamodb.AttributeValue, error) {
 	if f, ok := c.conf.KeyToConverter[key]; ok {
 		return f(value)
 	}
-	return stringValue(value)
+	return stringValue(value), nil
+}
+
+// lenient converts a function that cannot fail into a keyConverter.
+func lenient(f func(s string) *dynamodb.AttributeValue) keyConverter {
+	return func(s string) (*dynamodb.AttributeValue, error) {
+		return f(s), nil
+	}
 }
 
 func stringValue(s string) *dynamodb.AttributeValue {
@@ -158,24 +930,156 @@ func numberValue(s string) *dynamodb.AttributeValue {
 	return (&dynamodb.AttributeValue{}).SetN(s)
 }
 
-func boolValue(s string) *dynamodb.AttributeValue {
+// mapValue converts DynamoDB JSON to a map. Values which are nested too deeply to be written
+// are rejected, so that the row can be reported, instead of failing the whole batch.
+func (conf *Configuration) mapValue(s string) (*dynamodb.AttributeValue, error) {
+	var m map[string]*dynamodb.AttributeValue
+	if err := json.Unmarshal([]byte(s), &m); err != nil && conf.Strict {
+		return nil, &ErrInvalidValue{Type: TypeMap, Value: s, Cause: err}
+	}
+	av := (&dynamodb.AttributeValue{}).SetM(m)
+	return av, CheckDepth(av)
+}
+
+// numberValue converts a number. In Strict mode, values which DynamoDB doesn't accept as
+// numbers are an ErrInvalidValue.
+func (conf *Configuration) numberValue(s string) (*dynamodb.AttributeValue, error) {
+	if conf.Strict && !dynamoNumber.MatchString(s) {
+		return nil, &ErrInvalidValue{Type: TypeNumber, Value: s}
+	}
+	return numberValue(s), nil
+}
+
+// boolValue converts a boolean. Unknown values are false, or an ErrInvalidValue in Strict mode.
+func (conf *Configuration) boolValue(s string) (*dynamodb.AttributeValue, error) {
 	if v, ok := boolValues[s]; ok {
-		return v
+		return v, nil
 	}
-	return falseValue
+	if conf.Strict {
+		return nil, &ErrInvalidValue{Type: TypeBool, Value: s}
+	}
+	return falseValue, nil
 }
 
-func mapValue(s string) *dynamodb.AttributeValue {
-	var av map[string]*dynamodb.AttributeValue
-	json.Unmarshal([]byte(s), &av)
-	return (&dynamodb.AttributeValue{}).SetM(av)
+// binValue converts base64 to binary. Invalid base64 is ignored, or an ErrInvalidValue in
+// Strict mode.
+func (conf *Configuration) binValue(s string) (*dynamodb.AttributeValue, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil && conf.Strict {
+		return nil, &ErrInvalidValue{Type: TypeBinary, Value: s, Cause: err}
+	}
+	return (&dynamodb.AttributeValue{}).SetB(b), nil
 }
 
-func binValue(s string) *dynamodb.AttributeValue {
-	b, _ := base64.StdEncoding.DecodeString(s)
-	return (&dynamodb.AttributeValue{}).SetB(b)
+// leadingZeros matches numbers with leading zeros, which DynamoDB removes, e.g. 007 is stored as 7.
+var leadingZeros = regexp.MustCompile(`^[+-]?0[0-9]`)
+
+// hexBinValue converts hex to binary, with or without a 0x or \x prefix.
+func hexBinValue(s string) (*dynamodb.AttributeValue, error) {
+	h := s
+	for _, prefix := range []string{"0x", "0X", `\x`} {
+		if strings.HasPrefix(h, prefix) {
+			h = h[len(prefix):]
+			break
+		}
+	}
+	b, err := hex.DecodeString(h)
+	if err != nil {
+		return nil, &ErrInvalidValue{Type: TypeBinary, Value: s, Cause: err}
+	}
+	return (&dynamodb.AttributeValue{}).SetB(b), nil
+}
+
+// dynamoNumber matches the numbers that DynamoDB accepts.
+var dynamoNumber = regexp.MustCompile(`^[+-]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][+-]?[0-9]+)?$`)
+
+func listValue(s, delimiter string) (*dynamodb.AttributeValue, error) {
+	if strings.HasPrefix(strings.TrimSpace(s), "[") {
+		d := json.NewDecoder(strings.NewReader(s))
+		d.UseNumber()
+		var v []interface{}
+		if err := d.Decode(&v); err != nil {
+			return nil, fmt.Errorf("invalid JSON array: %w", err)
+		}
+		av, err := jsonValue(v)
+		if err != nil {
+			return nil, err
+		}
+		return av, CheckDepth(av)
+	}
+	if delimiter == "" {
+		delimiter = ","
+	}
+	values := strings.Split(s, delimiter)
+	l := make([]*dynamodb.AttributeValue, len(values))
+	for i, v := range values {
+		l[i] = stringValue(strings.TrimSpace(v))
+	}
+	return (&dynamodb.AttributeValue{}).SetL(l), nil
+}
+
+// setValue returns nil if the set has no elements, because DynamoDB doesn't allow empty sets.
+func setValue(s, delimiter string, set func(av *dynamodb.AttributeValue, v []*string) *dynamodb.AttributeValue) *dynamodb.AttributeValue {
+	elements := setElements(s, delimiter)
+	if len(elements) == 0 {
+		return nil
+	}
+	return set(&dynamodb.AttributeValue{}, aws.StringSlice(elements))
 }
 
+// setElements splits s on the delimiter, removing spaces around each element, empty elements and
+// duplicates.
+func setElements(s, delimiter string) (elements []string) {
+	if delimiter == "" {
+		delimiter = ","
+	}
+	seen := map[string]bool{}
+	for _, e := range strings.Split(s, delimiter) {
+		e = strings.TrimSpace(e)
+		if e == "" || seen[e] {
+			continue
+		}
+		seen[e] = true
+		elements = append(elements, e)
+	}
+	return elements
+}
+
+// jsonValue converts a decoded JSON value to a DynamoDB attribute value.
+func jsonValue(v interface{}) (*dynamodb.AttributeValue, error) {
+	switch v := v.(type) {
+	case nil:
+		return (&dynamodb.AttributeValue{}).SetNULL(true), nil
+	case bool:
+		return (&dynamodb.AttributeValue{}).SetBOOL(v), nil
+	case json.Number:
+		return (&dynamodb.AttributeValue{}).SetN(v.String()), nil
+	case string:
+		return stringValue(v), nil
+	case []interface{}:
+		l := make([]*dynamodb.AttributeValue, len(v))
+		for i, e := range v {
+			var err error
+			if l[i], err = jsonValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return (&dynamodb.AttributeValue{}).SetL(l), nil
+	case map[string]interface{}:
+		m := make(map[string]*dynamodb.AttributeValue, len(v))
+		for k, e := range v {
+			var err error
+			if m[k], err = jsonValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return (&dynamodb.AttributeValue{}).SetM(m), nil
+	}
+	return nil, fmt.Errorf("csvtodynamo: unexpected type %T", v)
+}
+
+var nullValue = (&dynamodb.AttributeValue{}).SetNULL(true)
+
 var trueValue = (&dynamodb.AttributeValue{}).SetBOOL(true)
 var falseValue = (&dynamodb.AttributeValue{}).SetBOOL(false)
 
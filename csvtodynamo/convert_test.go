@@ -7,7 +7,9 @@ import (
 	"io"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/a-h/ddbimport/sls/linereader"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/google/go-cmp/cmp"
@@ -113,6 +115,21 @@ func TestConverter(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "hex binary can be identified",
+			input: strings.Join([]string{
+				"one,two,three",
+				`17dbc16b,0x17DBC16B,\x17dbc16b`,
+			}, "\n"),
+			config: NewConfiguration().AddHexBinKeys("one", "two", "three"),
+			expected: []map[string]*dynamodb.AttributeValue{
+				{
+					"one":   &dynamodb.AttributeValue{B: bin[:4]},
+					"two":   &dynamodb.AttributeValue{B: bin[:4]},
+					"three": &dynamodb.AttributeValue{B: bin[:4]},
+				},
+			},
+		},
 		{
 			name: "strings are handled",
 			input: strings.Join([]string{
@@ -169,6 +186,242 @@ func TestConverter(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "list fields can be delimited values or JSON arrays",
+			input: strings.Join([]string{
+				"id,tags",
+				`1,"red, green"`,
+				`2,"[""red"", 3, true, null, {""a"": ""b""}]"`,
+			}, "\n"),
+			config: NewConfiguration().AddListKeys("tags"),
+			expected: []map[string]*dynamodb.AttributeValue{
+				{
+					"id": &dynamodb.AttributeValue{S: aws.String("1")},
+					"tags": &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{
+						{S: aws.String("red")},
+						{S: aws.String("green")},
+					}},
+				},
+				{
+					"id": &dynamodb.AttributeValue{S: aws.String("2")},
+					"tags": &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{
+						{S: aws.String("red")},
+						{N: aws.String("3")},
+						{BOOL: aws.Bool(true)},
+						{NULL: aws.Bool(true)},
+						{M: map[string]*dynamodb.AttributeValue{"a": {S: aws.String("b")}}},
+					}},
+				},
+			},
+		},
+		{
+			name: "list fields can use another delimiter",
+			input: strings.Join([]string{
+				"id,tags",
+				"1,red|green",
+			}, "\n"),
+			config: func() *Configuration {
+				conf := NewConfiguration().AddListKeys("tags")
+				conf.ListDelimiter = "|"
+				return conf
+			}(),
+			expected: []map[string]*dynamodb.AttributeValue{
+				{
+					"id": &dynamodb.AttributeValue{S: aws.String("1")},
+					"tags": &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{
+						{S: aws.String("red")},
+						{S: aws.String("green")},
+					}},
+				},
+			},
+		},
+		{
+			name: "set fields are split, and empty and duplicate elements are removed",
+			input: strings.Join([]string{
+				"id,tags,sizes,hashes",
+				`1,"red, green,,red","8|10","YQ==|Yg=="`,
+				`2,",",,`,
+			}, "\n"),
+			config: func() *Configuration {
+				conf := NewConfiguration().AddStringSetKeys("tags").AddNumberSetKeys("sizes").AddBinarySetKeys("hashes")
+				conf.SetDelimiter = "|"
+				return conf
+			}(),
+			expected: []map[string]*dynamodb.AttributeValue{
+				{
+					"id":     &dynamodb.AttributeValue{S: aws.String("1")},
+					"tags":   &dynamodb.AttributeValue{SS: aws.StringSlice([]string{"red, green,,red"})},
+					"sizes":  &dynamodb.AttributeValue{NS: aws.StringSlice([]string{"8", "10"})},
+					"hashes": &dynamodb.AttributeValue{BS: [][]byte{[]byte("a"), []byte("b")}},
+				},
+				{
+					"id":   &dynamodb.AttributeValue{S: aws.String("2")},
+					"tags": &dynamodb.AttributeValue{SS: aws.StringSlice([]string{","})},
+				},
+			},
+		},
+		{
+			name: "string sets use a comma by default",
+			input: strings.Join([]string{
+				"id,tags",
+				`1,"red, green,,red"`,
+				`2,","`,
+			}, "\n"),
+			config: NewConfiguration().AddStringSetKeys("tags"),
+			expected: []map[string]*dynamodb.AttributeValue{
+				{
+					"id":   &dynamodb.AttributeValue{S: aws.String("1")},
+					"tags": &dynamodb.AttributeValue{SS: aws.StringSlice([]string{"red", "green"})},
+				},
+				{
+					"id": &dynamodb.AttributeValue{S: aws.String("2")},
+				},
+			},
+		},
+		{
+			name: "null tokens are omitted, or stored as NULL",
+			input: strings.Join([]string{
+				"a,b,c,d",
+				`NULL,\N,-,-`,
+				"1,2,3,4",
+			}, "\n"),
+			config: func() *Configuration {
+				conf := NewConfiguration().AddNullKeys("b")
+				conf.NullTokens = []string{"NULL", `\N`}
+				conf.ColumnNullTokens = map[string][]string{"c": {"-"}}
+				return conf
+			}(),
+			expected: []map[string]*dynamodb.AttributeValue{
+				{
+					"b": &dynamodb.AttributeValue{NULL: aws.Bool(true)},
+					"d": &dynamodb.AttributeValue{S: aws.String("-")},
+				},
+				{
+					"a": &dynamodb.AttributeValue{S: aws.String("1")},
+					"b": &dynamodb.AttributeValue{S: aws.String("2")},
+					"c": &dynamodb.AttributeValue{S: aws.String("3")},
+					"d": &dynamodb.AttributeValue{S: aws.String("4")},
+				},
+			},
+		},
+		{
+			name: "every nth row can be sampled",
+			input: strings.Join([]string{
+				"a",
+				"1", "2", "3", "4", "5", "6", "7",
+			}, "\n"),
+			config: &Configuration{KeyToConverter: map[string]keyConverter{}, SampleEvery: 3},
+			expected: []map[string]*dynamodb.AttributeValue{
+				{"a": &dynamodb.AttributeValue{S: aws.String("3")}},
+				{"a": &dynamodb.AttributeValue{S: aws.String("6")}},
+			},
+		},
+		{
+			name: "the raw row and its hash can be stored",
+			input: strings.Join([]string{
+				"a,b",
+				`1,"red, wine"`,
+			}, "\n"),
+			config: &Configuration{KeyToConverter: map[string]keyConverter{}, RawAttribute: "_raw", RowHashAttribute: "_rowHash"},
+			expected: []map[string]*dynamodb.AttributeValue{
+				{
+					"a":        &dynamodb.AttributeValue{S: aws.String("1")},
+					"b":        &dynamodb.AttributeValue{S: aws.String("red, wine")},
+					"_raw":     &dynamodb.AttributeValue{S: aws.String(`1,"red, wine"`)},
+					"_rowHash": &dynamodb.AttributeValue{S: aws.String("2484725eaadc9f80f1cfae0ac99c1feaa6848ac7fe4693edc81c804d454e3f9f")},
+				},
+			},
+		},
+		{
+			name: "columns can be excluded, including from the raw row",
+			input: strings.Join([]string{
+				"a,email,c",
+				"1,alice@example.com,3",
+			}, "\n"),
+			config: &Configuration{KeyToConverter: map[string]keyConverter{}, RawAttribute: "_raw", ExcludedColumns: map[string]bool{"email": true}},
+			expected: []map[string]*dynamodb.AttributeValue{
+				{
+					"a":    &dynamodb.AttributeValue{S: aws.String("1")},
+					"c":    &dynamodb.AttributeValue{S: aws.String("3")},
+					"_raw": &dynamodb.AttributeValue{S: aws.String("1,,3")},
+				},
+			},
+		},
+		{
+			name: "repeated headers can be skipped, and change the column order",
+			input: strings.Join([]string{
+				"a,b",
+				"1,2",
+				"a,b",
+				"3,4",
+				"b,a",
+				"5,6",
+			}, "\n"),
+			config: &Configuration{KeyToConverter: map[string]keyConverter{}, SkipRepeatedHeaders: true},
+			expected: []map[string]*dynamodb.AttributeValue{
+				{"a": &dynamodb.AttributeValue{S: aws.String("1")}, "b": &dynamodb.AttributeValue{S: aws.String("2")}},
+				{"a": &dynamodb.AttributeValue{S: aws.String("3")}, "b": &dynamodb.AttributeValue{S: aws.String("4")}},
+				{"a": &dynamodb.AttributeValue{S: aws.String("6")}, "b": &dynamodb.AttributeValue{S: aws.String("5")}},
+			},
+		},
+		{
+			name: "preamble rows can be skipped before the header",
+			input: strings.Join([]string{
+				"Sales export",
+				`Generated by "legacy" system, 2020-01-02`,
+				"a,b",
+				"1,2",
+			}, "\n"),
+			config: &Configuration{KeyToConverter: map[string]keyConverter{}, SkipRows: 2},
+			expected: []map[string]*dynamodb.AttributeValue{
+				{"a": &dynamodb.AttributeValue{S: aws.String("1")}, "b": &dynamodb.AttributeValue{S: aws.String("2")}},
+			},
+		},
+		{
+			name: "comment lines are skipped",
+			input: strings.Join([]string{
+				"# exported 2020-01-02",
+				"a,b",
+				"1,2",
+				"# end of day 1",
+				"3,4",
+			}, "\n"),
+			config: &Configuration{KeyToConverter: map[string]keyConverter{}, Comment: '#'},
+			expected: []map[string]*dynamodb.AttributeValue{
+				{"a": &dynamodb.AttributeValue{S: aws.String("1")}, "b": &dynamodb.AttributeValue{S: aws.String("2")}},
+				{"a": &dynamodb.AttributeValue{S: aws.String("3")}, "b": &dynamodb.AttributeValue{S: aws.String("4")}},
+			},
+		},
+		{
+			name:          "ragged rows are rejected by default",
+			input:         "a,b,c\n1,2\n",
+			config:        &Configuration{KeyToConverter: map[string]keyConverter{}},
+			expectedError: csv.ErrFieldCount,
+		},
+		{
+			name: "ragged rows can be padded and truncated",
+			input: strings.Join([]string{
+				"a,b,c",
+				"1,2",
+				"3,4,5,6",
+			}, "\n"),
+			config: &Configuration{KeyToConverter: map[string]keyConverter{}, RaggedRows: true},
+			expected: []map[string]*dynamodb.AttributeValue{
+				{"a": &dynamodb.AttributeValue{S: aws.String("1")}, "b": &dynamodb.AttributeValue{S: aws.String("2")}},
+				{"a": &dynamodb.AttributeValue{S: aws.String("3")}, "b": &dynamodb.AttributeValue{S: aws.String("4")}, "c": &dynamodb.AttributeValue{S: aws.String("5")}},
+			},
+		},
+		{
+			name: "lazy quotes and leading spaces are allowed",
+			input: strings.Join([]string{
+				"a, b",
+				`12" screen, "said "hi""`,
+			}, "\n"),
+			config: &Configuration{KeyToConverter: map[string]keyConverter{}, LazyQuotes: true, TrimLeadingSpace: true},
+			expected: []map[string]*dynamodb.AttributeValue{
+				{"a": &dynamodb.AttributeValue{S: aws.String(`12" screen`)}, "b": &dynamodb.AttributeValue{S: aws.String(`said "hi"`)}},
+			},
+		},
 	}
 	for _, tt := range tests {
 		tt := tt
@@ -203,3 +456,686 @@ func TestConverter(t *testing.T) {
 	}
 
 }
+
+func TestConverterSampleRate(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("a\n")
+	for i := 0; i < 10000; i++ {
+		sb.WriteString("x\n")
+	}
+	conf := NewConfiguration()
+	conf.SampleRate = 0.1
+	c, err := NewConverter(csv.NewReader(strings.NewReader(sb.String())), conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var read int
+	for {
+		_, err := c.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		read++
+	}
+	if read < 800 || read > 1200 {
+		t.Errorf("expected approximately 1000 rows to be sampled, got %d", read)
+	}
+}
+
+func TestConverterTableKeys(t *testing.T) {
+	var tests = []struct {
+		name          string
+		input         string
+		require       bool
+		unique        bool
+		expectedRead  int
+		expectedError error
+	}{
+		{
+			name:         "keys are not checked by default",
+			input:        "pk,sk\n1,a\n1,a\n,b",
+			expectedRead: 3,
+		},
+		{
+			name:          "missing keys can be rejected",
+			input:         "pk,sk\n1,a\n2,",
+			require:       true,
+			expectedRead:  1,
+			expectedError: ErrMissingKey,
+		},
+		{
+			name:          "duplicate keys can be rejected",
+			input:         "pk,sk\n1,a\n1,b\n2,a\n1,a",
+			unique:        true,
+			expectedRead:  3,
+			expectedError: ErrDuplicateKey,
+		},
+		{
+			name:         "unique keys are accepted",
+			input:        "pk,sk\n1,a\n1,b\n2,a",
+			require:      true,
+			unique:       true,
+			expectedRead: 3,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			conf := NewConfiguration()
+			conf.TableKeys = []string{"pk", "sk"}
+			conf.RequireTableKeys = tt.require
+			conf.CheckUniqueTableKeys = tt.unique
+			c, err := NewConverter(csv.NewReader(strings.NewReader(tt.input)), conf)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_, read, err := c.ReadBatch()
+			if tt.expectedError == nil && err != io.EOF {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.expectedError != nil && !errors.Is(err, tt.expectedError) {
+				t.Errorf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if read != tt.expectedRead {
+				t.Errorf("expected %d reads, read %d", tt.expectedRead, read)
+			}
+		})
+	}
+}
+
+func TestConverterEnumKeys(t *testing.T) {
+	conf := NewConfiguration().AddEnumKeys([]string{"I", "U", "D"}, "op")
+	c, err := NewConverter(csv.NewReader(strings.NewReader("op,pk\nI,1\n,2\nD,3\nX,4")), conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	items, read, err := c.ReadBatch()
+	if read != 3 {
+		t.Errorf("expected 3 reads, read %d", read)
+	}
+	expected := []map[string]*dynamodb.AttributeValue{
+		{"op": {S: aws.String("I")}, "pk": {S: aws.String("1")}},
+		{"pk": {S: aws.String("2")}},
+		{"op": {S: aws.String("D")}, "pk": {S: aws.String("3")}},
+	}
+	if diff := cmp.Diff(expected, items); diff != "" {
+		t.Error(diff)
+	}
+	var rce *ErrRowConversion
+	if !errors.As(err, &rce) || !errors.Is(err, ErrUnexpectedValue) {
+		t.Fatalf("expected unexpected value error, got %v", err)
+	}
+	if rce.Line != 5 || rce.Column != "op" {
+		t.Errorf("expected line 5, column op, got line %d, column %q", rce.Line, rce.Column)
+	}
+}
+
+func TestConverterTimestampKeys(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	tests := []struct {
+		name     string
+		format   string
+		location *time.Location
+		expected *dynamodb.AttributeValue
+	}{
+		{
+			name:     "iso8601",
+			format:   TimestampISO8601,
+			expected: &dynamodb.AttributeValue{S: aws.String("2021-03-04T10:00:00Z")},
+		},
+		{
+			name:     "epoch",
+			format:   TimestampEpoch,
+			expected: &dynamodb.AttributeValue{N: aws.String("1614852000")},
+		},
+		{
+			name:     "epochMillis",
+			format:   TimestampEpochMillis,
+			expected: &dynamodb.AttributeValue{N: aws.String("1614852000000")},
+		},
+		{
+			name:     "iso8601 in another time zone",
+			format:   TimestampISO8601,
+			location: newYork,
+			expected: &dynamodb.AttributeValue{S: aws.String("2021-03-04T15:00:00Z")},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf, err := NewConfiguration().AddTimestampKeys("2006-01-02 15:04:05", test.format, "ts")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			conf.TimestampLocation = test.location
+			c, err := NewConverter(csv.NewReader(strings.NewReader("id,ts\n1,2021-03-04 10:00:00\n2,\n3,04/03/2021")), conf)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			items, _, err := c.ReadBatch()
+			expected := []map[string]*dynamodb.AttributeValue{
+				{"id": {S: aws.String("1")}, "ts": test.expected},
+				{"id": {S: aws.String("2")}},
+			}
+			if diff := cmp.Diff(expected, items); diff != "" {
+				t.Error(diff)
+			}
+			var rce *ErrRowConversion
+			if !errors.As(err, &rce) || !errors.Is(err, ErrInvalidTimestamp) {
+				t.Fatalf("expected invalid timestamp error, got %v", err)
+			}
+			if rce.Line != 4 || rce.Column != "ts" {
+				t.Errorf("expected line 4, column ts, got line %d, column %q", rce.Line, rce.Column)
+			}
+		})
+	}
+}
+
+func TestConverterTTL(t *testing.T) {
+	imported := time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		column   string
+		offset   time.Duration
+		expected []map[string]*dynamodb.AttributeValue
+	}{
+		{
+			name:   "the import time plus an offset",
+			offset: time.Hour * 24,
+			expected: []map[string]*dynamodb.AttributeValue{
+				{"id": {S: aws.String("1")}, "expires": {S: aws.String("2021-03-04 10:00:00")}, "ttl": {N: aws.String("1614643200")}},
+				{"id": {S: aws.String("2")}, "ttl": {N: aws.String("1614643200")}},
+			},
+		},
+		{
+			name:   "a column",
+			column: "expires",
+			expected: []map[string]*dynamodb.AttributeValue{
+				{"id": {S: aws.String("1")}, "expires": {S: aws.String("2021-03-04 10:00:00")}, "ttl": {N: aws.String("1614852000")}},
+				{"id": {S: aws.String("2")}},
+			},
+		},
+		{
+			name:   "a column plus an offset",
+			column: "expires",
+			offset: time.Hour,
+			expected: []map[string]*dynamodb.AttributeValue{
+				{"id": {S: aws.String("1")}, "expires": {S: aws.String("2021-03-04 10:00:00")}, "ttl": {N: aws.String("1614855600")}},
+				{"id": {S: aws.String("2")}},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := NewConfiguration()
+			conf.TTLAttribute = "ttl"
+			conf.TTLColumn = test.column
+			conf.TTLLayout = "2006-01-02 15:04:05"
+			conf.TTLFrom = imported
+			conf.TTLOffset = test.offset
+			c, err := NewConverter(csv.NewReader(strings.NewReader("id,expires\n1,2021-03-04 10:00:00\n2,")), conf)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			items, _, err := c.ReadBatch()
+			if err != io.EOF {
+				t.Fatalf("expected EOF, got %v", err)
+			}
+			if diff := cmp.Diff(test.expected, items); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestConverterTTLInvalidTimestamp(t *testing.T) {
+	conf := NewConfiguration()
+	conf.TTLAttribute = "ttl"
+	conf.TTLColumn = "expires"
+	conf.TTLLayout = "2006-01-02"
+	c, err := NewConverter(csv.NewReader(strings.NewReader("id,expires\n1,tomorrow")), conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = c.Read()
+	var rce *ErrRowConversion
+	if !errors.As(err, &rce) || !errors.Is(err, ErrInvalidTimestamp) || rce.Column != "expires" {
+		t.Errorf("expected invalid timestamp error in the expires column, got %v", err)
+	}
+}
+
+func TestConverterEmptyValues(t *testing.T) {
+	conf := NewConfiguration().AddNullKeys("deleted")
+	conf.NullTokens = []string{"NULL"}
+	input := strings.Join([]string{
+		"id,name,email,deleted",
+		"1,Alice,,NULL",
+		"2,NULL,,",
+		"3,Bob,,false",
+	}, "\n")
+	c, err := NewConverter(csv.NewReader(strings.NewReader(input)), conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err = c.ReadBatch(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	expected := map[string]int64{"name": 1, "email": 3, "deleted": 1}
+	if diff := cmp.Diff(expected, c.EmptyValues()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestConverterLeadingZeros(t *testing.T) {
+	// zip is preserved as a string, so its leading zeros are kept.
+	conf := NewConfiguration().AddNumberKeys("id", "price", "zip").AddStringKeys("zip")
+	input := strings.Join([]string{
+		"id,price,zip",
+		"007,0.5,01234",
+		"8,-0.25,02134",
+		"-09,0,10001",
+	}, "\n")
+	c, err := NewConverter(csv.NewReader(strings.NewReader(input)), conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	items, _, err := c.ReadBatch()
+	if err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if diff := cmp.Diff(&dynamodb.AttributeValue{S: aws.String("01234")}, items[0]["zip"]); diff != "" {
+		t.Error(diff)
+	}
+	expected := map[string]int64{"id": 2}
+	if diff := cmp.Diff(expected, c.LeadingZeros()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestConverterDefaults(t *testing.T) {
+	conf := NewConfiguration().AddNumberKeys("score").AddNullKeys("deleted").
+		AddDefault("status", "ACTIVE").AddDefault("score", "0").AddDefault("deleted", "false")
+	conf.NullTokens = []string{"NULL"}
+	input := strings.Join([]string{
+		"id,status,score,deleted,email",
+		"1,,,NULL,",
+		"2,NULL,10,,",
+		"3,INACTIVE,NULL,true,",
+	}, "\n")
+	c, err := NewConverter(csv.NewReader(strings.NewReader(input)), conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	items, _, err := c.ReadBatch()
+	if err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	expected := []map[string]*dynamodb.AttributeValue{
+		{
+			"id":      {S: aws.String("1")},
+			"status":  {S: aws.String("ACTIVE")},
+			"score":   {N: aws.String("0")},
+			"deleted": {NULL: aws.Bool(true)},
+		},
+		{
+			"id":      {S: aws.String("2")},
+			"status":  {S: aws.String("ACTIVE")},
+			"score":   {N: aws.String("10")},
+			"deleted": {S: aws.String("false")},
+		},
+		{
+			"id":      {S: aws.String("3")},
+			"status":  {S: aws.String("INACTIVE")},
+			"score":   {N: aws.String("0")},
+			"deleted": {S: aws.String("true")},
+		},
+	}
+	if diff := cmp.Diff(expected, items); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff(map[string]int64{"email": 3}, c.EmptyValues()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestConverterInvalidDefault(t *testing.T) {
+	conf, err := NewConfiguration().AddTimestampKeys("2006-01-02", TimestampISO8601, "created")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conf.AddDefault("created", "never")
+	c, err := NewConverter(csv.NewReader(strings.NewReader("id,created\n1,")), conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var rce *ErrRowConversion
+	if _, err = c.Read(); !errors.As(err, &rce) || !errors.Is(err, ErrInvalidTimestamp) || rce.Column != "created" {
+		t.Errorf("expected an invalid timestamp error in the created column, got %v", err)
+	}
+}
+
+func TestConverterTemplateKeys(t *testing.T) {
+	conf, err := NewConfiguration().AddTemplateKey("pk", "USER#{{.user_id}}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = conf.AddTemplateKey("sk", `ORDER#{{index . "order date"}}#{{.order_id}}`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conf.AddExcludedColumns("user_id")
+	input := strings.Join([]string{
+		"user_id,order_id,order date",
+		"u1,o1,2021-03-04",
+		"u2,,2021-03-05",
+	}, "\n")
+	c, err := NewConverter(csv.NewReader(strings.NewReader(input)), conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	items, _, err := c.ReadBatch()
+	expected := []map[string]*dynamodb.AttributeValue{
+		{
+			"pk":         {S: aws.String("USER#u1")},
+			"sk":         {S: aws.String("ORDER#2021-03-04#o1")},
+			"order_id":   {S: aws.String("o1")},
+			"order date": {S: aws.String("2021-03-04")},
+		},
+	}
+	if diff := cmp.Diff(expected, items); diff != "" {
+		t.Error(diff)
+	}
+	var rce *ErrRowConversion
+	if !errors.As(err, &rce) || !errors.Is(err, ErrTemplate) {
+		t.Fatalf("expected template error, got %v", err)
+	}
+	if rce.Line != 3 || rce.Column != "sk" {
+		t.Errorf("expected line 3, column sk, got line %d, column %q", rce.Line, rce.Column)
+	}
+}
+
+func TestConverterTemplateFunctions(t *testing.T) {
+	conf := NewConfiguration().AddConstant("entityType", &dynamodb.AttributeValue{S: aws.String("USER")})
+	conf.Started = time.Date(2021, time.March, 4, 10, 0, 0, 0, time.FixedZone("CET", 3600))
+	templates := map[string]string{
+		"slug":       `{{.name | trim | replace " " "_" | lower}}`,
+		"code":       `{{upper .country}}`,
+		"importedAt": `{{now}}`,
+	}
+	for attribute, text := range templates {
+		if _, err := conf.AddTemplateKey(attribute, text); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	c, err := NewConverter(csv.NewReader(strings.NewReader("name,country,entityType\n\" Ada Lovelace \",gb,ignored")), conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	actual, err := c.Read()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]*dynamodb.AttributeValue{
+		"name":       {S: aws.String(" Ada Lovelace ")},
+		"country":    {S: aws.String("gb")},
+		"entityType": {S: aws.String("USER")},
+		"slug":       {S: aws.String("ada_lovelace")},
+		"code":       {S: aws.String("GB")},
+		"importedAt": {S: aws.String("2021-03-04T09:00:00Z")},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestAddTemplateKeyInvalidTemplate(t *testing.T) {
+	if _, err := NewConfiguration().AddTemplateKey("pk", "USER#{{.user_id"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestAddTimestampKeysUnknownFormat(t *testing.T) {
+	if _, err := NewConfiguration().AddTimestampKeys("2006-01-02", "unix", "ts"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestConverterDMSConventions(t *testing.T) {
+	conf := NewConfiguration()
+	conf.Columns = []string{"id", "name", "updated"}
+	conf.OptionalFirstColumn = "Op"
+	conf.NullTokens = []string{"NULL"}
+	conf.TimestampLayout = "2006-01-02 15:04:05.999999999"
+	input := "1,a,2020-01-02 15:04:05\nU,2,NULL,2020-01-02 15:04:05.123456\nD,3\n"
+	c, err := NewConverter(csv.NewReader(strings.NewReader(input)), conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	items, read, err := c.ReadBatch()
+	if read != 2 {
+		t.Errorf("expected 2 reads, read %d", read)
+	}
+	expected := []map[string]*dynamodb.AttributeValue{
+		{"id": {S: aws.String("1")}, "name": {S: aws.String("a")}, "updated": {S: aws.String("2020-01-02T15:04:05Z")}},
+		{"Op": {S: aws.String("U")}, "id": {S: aws.String("2")}, "updated": {S: aws.String("2020-01-02T15:04:05.123456Z")}},
+	}
+	if diff := cmp.Diff(expected, items); diff != "" {
+		t.Error(diff)
+	}
+	var rce *ErrRowConversion
+	if !errors.As(err, &rce) || !errors.Is(err, csv.ErrFieldCount) || rce.Line != 3 {
+		t.Errorf("expected a field count error on line 3, got %v", err)
+	}
+}
+
+func TestRowConversionError(t *testing.T) {
+	var tests = []struct {
+		name           string
+		input          string
+		expectedLine   int64
+		expectedColumn string
+	}{
+		{
+			name:         "CSV errors include the line",
+			input:        "pk,sk\n1,a\n1,2,3",
+			expectedLine: 3,
+		},
+		{
+			name:           "key errors include the line and column",
+			input:          "pk,sk\n1,a\n2,b\n3,",
+			expectedLine:   4,
+			expectedColumn: "sk",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			conf := NewConfiguration()
+			conf.TableKeys = []string{"pk", "sk"}
+			conf.RequireTableKeys = true
+			c, err := NewConverter(csv.NewReader(strings.NewReader(tt.input)), conf)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_, _, err = c.ReadBatch()
+			var rce *ErrRowConversion
+			if !errors.As(err, &rce) {
+				t.Fatalf("expected ErrRowConversion, got %v", err)
+			}
+			if rce.Line != tt.expectedLine {
+				t.Errorf("expected line %d, got %d", tt.expectedLine, rce.Line)
+			}
+			if rce.Column != tt.expectedColumn {
+				t.Errorf("expected column %q, got %q", tt.expectedColumn, rce.Column)
+			}
+		})
+	}
+}
+
+func TestRowConversionErrorPosition(t *testing.T) {
+	var tests = []struct {
+		name                      string
+		input                     string
+		columns                   []string
+		startLine, startOffset    int64
+		expectedLine, expectedOff int64
+		expected                  string
+	}{
+		{
+			name:         "rows after multi-line values",
+			input:        "pk,sk\n1,a\n\"multi\nline\",b\n2,\n",
+			expectedLine: 5,
+			expectedOff:  25,
+			expected:     `csvtodynamo: line 5 (byte 25): column "sk": csvtodynamo: missing key`,
+		},
+		{
+			name:         "partitions report positions within the whole file",
+			input:        "1,a\n1,2,3\n",
+			columns:      []string{"pk", "sk"},
+			startLine:    100,
+			startOffset:  5000,
+			expectedLine: 102,
+			expectedOff:  5004,
+			expected:     "csvtodynamo: line 102 (byte 5004): wrong number of fields",
+		},
+		{
+			name:         "parse errors within multi-line values",
+			input:        "1,a\n2,\"b\nc\"d\n",
+			columns:      []string{"pk", "sk"},
+			startLine:    10,
+			startOffset:  100,
+			expectedLine: 13,
+			expectedOff:  104,
+			expected:     `csvtodynamo: line 13 (byte 104): character 2: extraneous or missing " in quoted-field`,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			conf := NewConfiguration()
+			conf.Columns = tt.columns
+			conf.TableKeys = []string{"pk", "sk"}
+			conf.RequireTableKeys = true
+			lr := linereader.New(strings.NewReader(tt.input), tt.startLine, tt.startOffset, nil)
+			csvr := csv.NewReader(lr)
+			csvr.FieldsPerRecord = 2
+			c, err := NewConverter(csvr, conf)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			c.TrackPosition(lr)
+			for err == nil {
+				_, _, err = c.ReadBatch()
+			}
+			var rce *ErrRowConversion
+			if !errors.As(err, &rce) {
+				t.Fatalf("expected ErrRowConversion, got %v", err)
+			}
+			if rce.Line != tt.expectedLine || rce.Offset != tt.expectedOff {
+				t.Errorf("expected line %d at byte %d, got line %d at byte %d", tt.expectedLine, tt.expectedOff, rce.Line, rce.Offset)
+			}
+			if rce.Error() != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, rce.Error())
+			}
+		})
+	}
+}
+
+func TestConverterInvalidHex(t *testing.T) {
+	for _, value := range []string{"0x1", "zz", `\x0g`} {
+		input := "id,b\n1," + value
+		c, err := NewConverter(csv.NewReader(strings.NewReader(input)), NewConfiguration().AddHexBinKeys("b"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// Invalid hex is an error, even without Strict.
+		_, err = c.Read()
+		var ive *ErrInvalidValue
+		if !errors.As(err, &ive) || ive.Type != TypeBinary || ive.Value != value {
+			t.Errorf("%s: expected an invalid binary value error, got %v", value, err)
+		}
+	}
+}
+
+func TestConverterStrict(t *testing.T) {
+	newConf := func() *Configuration {
+		return NewConfiguration().AddNumberKeys("n").AddBoolKeys("bool").AddBinKeys("b").
+			AddMapKeys("m").AddNumberSetKeys("ns").AddBinarySetKeys("bs")
+	}
+	var tests = []struct {
+		name         string
+		column       string
+		value        string
+		expectedType string
+	}{
+		{name: "numbers", column: "n", value: "12abc", expectedType: TypeNumber},
+		{name: "booleans", column: "bool", value: "yes", expectedType: TypeBool},
+		{name: "binary", column: "b", value: "not base64!", expectedType: TypeBinary},
+		{name: "maps", column: "m", value: "{not json", expectedType: TypeMap},
+		{name: "number sets", column: "ns", value: "1;two", expectedType: TypeNumberSet},
+		{name: "binary sets", column: "bs", value: "AQ==;!", expectedType: TypeBinarySet},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			input := "id," + tt.column + "\n1,\"" + tt.value + "\""
+			conf := newConf()
+			conf.SetDelimiter = ";"
+			c, err := NewConverter(csv.NewReader(strings.NewReader(input)), conf)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err = c.Read(); err != nil {
+				t.Fatalf("expected values to be converted leniently, got %v", err)
+			}
+
+			conf = newConf()
+			conf.SetDelimiter = ";"
+			conf.Strict = true
+			c, err = NewConverter(csv.NewReader(strings.NewReader(input)), conf)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_, err = c.Read()
+			var rce *ErrRowConversion
+			var ive *ErrInvalidValue
+			if !errors.As(err, &rce) || !errors.As(err, &ive) {
+				t.Fatalf("expected an invalid value error, got %v", err)
+			}
+			if rce.Line != 2 || rce.Column != tt.column {
+				t.Errorf("expected line 2, column %q, got line %d, column %q", tt.column, rce.Line, rce.Column)
+			}
+			if ive.Type != tt.expectedType || ive.Value != tt.value {
+				t.Errorf("expected invalid %s value %q, got invalid %s value %q", tt.expectedType, tt.value, ive.Type, ive.Value)
+			}
+		})
+	}
+}
+
+func TestConverterStrictValidValues(t *testing.T) {
+	conf := NewConfiguration().AddNumberKeys("n1", "n2", "n3").AddBoolKeys("bool").AddBinKeys("b")
+	conf.Strict = true
+	input := "n1,n2,n3,bool,b\n-1.5e3,+7,.25,TRUE,AQI="
+	c, err := NewConverter(csv.NewReader(strings.NewReader(input)), conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	actual, err := c.Read()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]*dynamodb.AttributeValue{
+		"n1":   {N: aws.String("-1.5e3")},
+		"n2":   {N: aws.String("+7")},
+		"n3":   {N: aws.String(".25")},
+		"bool": {BOOL: aws.Bool(true)},
+		"b":    {B: []byte{1, 2}},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,44 @@
+package csvtodynamo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// MaxDepth is the maximum number of levels that DynamoDB allows maps and lists to be nested.
+const MaxDepth = 32
+
+// CheckDepth returns an error which wraps ErrTooDeep if the attribute value contains maps or
+// lists nested more than MaxDepth levels deep. The error contains the path to the first value
+// that exceeds the limit, so that it can be found in the input.
+func CheckDepth(av *dynamodb.AttributeValue) error {
+	if path, ok := tooDeep(av, "", 1); ok {
+		path = strings.TrimPrefix(path, ".")
+		return fmt.Errorf("%w: %s is nested more than %d levels deep", ErrTooDeep, path, MaxDepth)
+	}
+	return nil
+}
+
+// tooDeep returns the path of the first map or list at a level beyond MaxDepth.
+func tooDeep(av *dynamodb.AttributeValue, path string, level int) (string, bool) {
+	if av == nil || (av.M == nil && av.L == nil) {
+		return "", false
+	}
+	if level > MaxDepth {
+		return path, true
+	}
+	for k, v := range av.M {
+		if p, ok := tooDeep(v, path+"."+k, level+1); ok {
+			return p, ok
+		}
+	}
+	for i, v := range av.L {
+		if p, ok := tooDeep(v, path+"["+strconv.Itoa(i)+"]", level+1); ok {
+			return p, ok
+		}
+	}
+	return "", false
+}
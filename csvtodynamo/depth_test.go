@@ -0,0 +1,63 @@
+package csvtodynamo
+
+import (
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// nested returns a map attribute with the given number of levels of maps.
+func nested(levels int) *dynamodb.AttributeValue {
+	av := (&dynamodb.AttributeValue{}).SetS("x")
+	for i := 0; i < levels; i++ {
+		av = (&dynamodb.AttributeValue{}).SetM(map[string]*dynamodb.AttributeValue{"a": av})
+	}
+	return av
+}
+
+func TestCheckDepth(t *testing.T) {
+	if err := CheckDepth(nested(MaxDepth)); err != nil {
+		t.Errorf("expected %d levels to be allowed, got %v", MaxDepth, err)
+	}
+	list := (&dynamodb.AttributeValue{}).SetL([]*dynamodb.AttributeValue{{S: aws.String("x")}, nested(MaxDepth)})
+	err := CheckDepth(list)
+	if !errors.Is(err, ErrTooDeep) {
+		t.Fatalf("expected ErrTooDeep, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "[1]"+strings.Repeat(".a", MaxDepth-1)+" is nested") {
+		t.Errorf("expected the path of the value in the error, got %v", err)
+	}
+}
+
+func TestConverterMapKeysTooDeep(t *testing.T) {
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+	w.Write([]string{"pk", "data"})
+	w.Write([]string{"1", `{"a":{"S":"x"}}`})
+	deep := `{"S":"x"}`
+	for i := 0; i <= MaxDepth; i++ {
+		deep = `{"M":{"a":` + deep + `}}`
+	}
+	w.Write([]string{"2", strings.TrimSuffix(strings.TrimPrefix(deep, `{"M":`), `}`)})
+	w.Flush()
+
+	c, err := NewConverter(csv.NewReader(strings.NewReader(sb.String())), NewConfiguration().AddMapKeys("data"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, read, err := c.ReadBatch()
+	if read != 1 {
+		t.Errorf("expected 1 read, read %d", read)
+	}
+	var rce *ErrRowConversion
+	if !errors.As(err, &rce) || !errors.Is(err, ErrTooDeep) {
+		t.Fatalf("expected ErrTooDeep, got %v", err)
+	}
+	if rce.Line != 3 || rce.Column != "data" {
+		t.Errorf("expected line 3, column data, got line %d, column %q", rce.Line, rce.Column)
+	}
+}
@@ -0,0 +1,103 @@
+package csvtodynamo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMissingKey is the cause of an ErrRowConversion when a row does not have a value for one of
+// the TableKeys.
+var ErrMissingKey = errors.New("csvtodynamo: missing key")
+
+// ErrDuplicateKey is the cause of an ErrRowConversion when two rows have the same values for
+// the TableKeys.
+var ErrDuplicateKey = errors.New("csvtodynamo: duplicate key")
+
+// ErrUnexpectedValue is the cause of an ErrRowConversion when a column added with AddEnumKeys
+// has a value that is not one of the allowed values.
+var ErrUnexpectedValue = errors.New("csvtodynamo: unexpected value")
+
+// ErrInvalidTimestamp is the cause of an ErrRowConversion when a column added with
+// AddTimestampKeys has a value that doesn't match the layout.
+var ErrInvalidTimestamp = errors.New("csvtodynamo: invalid timestamp")
+
+// ErrTemplate is the cause of an ErrRowConversion when the template of an attribute added with
+// AddTemplateKey can't be executed, e.g. because one of the columns it uses is empty.
+var ErrTemplate = errors.New("csvtodynamo: template failed")
+
+// ErrTooDeep is the cause of an ErrRowConversion when a map or list value is nested more than
+// MaxDepth levels deep.
+var ErrTooDeep = errors.New("csvtodynamo: attribute nested too deeply")
+
+// ErrNestingConflict is the cause of an ErrRowConversion when an attribute can't be folded into
+// a nested map using the NestSeparator, because the map, or one of its attributes, is already
+// an attribute of the item, e.g. a row has values for both address and address.city.
+var ErrNestingConflict = errors.New("csvtodynamo: nested attribute conflicts with another attribute")
+
+// ErrInvalidValue is the cause of an ErrRowConversion in Strict mode, when a value can't be
+// converted to the type of its column.
+type ErrInvalidValue struct {
+	// Type is the DynamoDB type of the column, e.g. N or BOOL.
+	Type string
+	// Value which couldn't be converted.
+	Value string
+	// Cause of the failure, if there's more detail than the type, e.g. a base64 error.
+	Cause error
+}
+
+func (e *ErrInvalidValue) Error() string {
+	if e.Cause == nil {
+		return fmt.Sprintf("csvtodynamo: invalid %s value %q", e.Type, e.Value)
+	}
+	return fmt.Sprintf("csvtodynamo: invalid %s value %q: %v", e.Type, e.Value, e.Cause)
+}
+
+// Unwrap returns the Cause.
+func (e *ErrInvalidValue) Unwrap() error {
+	return e.Cause
+}
+
+// ErrRuleViolation is the cause of an ErrRowConversion when a value breaks the Rule of its
+// column.
+type ErrRuleViolation struct {
+	// Rule which was broken, e.g. `maxLength 10`.
+	Rule string
+	// Value which broke the rule.
+	Value string
+}
+
+func (e *ErrRuleViolation) Error() string {
+	return fmt.Sprintf("csvtodynamo: value %q breaks rule %s", e.Value, e.Rule)
+}
+
+// ErrRowConversion is returned when a row of the CSV cannot be read or converted.
+type ErrRowConversion struct {
+	// Line is the line number of the row within the input, including the header row. If the
+	// Converter tracks the position of the input, it's the line the row starts on, counting
+	// from the start of the whole input, rather than the number of rows.
+	Line int64
+	// Offset is the byte offset of the start of the row within the input, if the Converter
+	// tracks the position of the input. Otherwise it's zero.
+	Offset int64
+	// Column is the name of the column that failed conversion. It is empty if the whole row
+	// could not be read.
+	Column string
+	// Cause of the failure.
+	Cause error
+}
+
+func (e *ErrRowConversion) Error() string {
+	position := fmt.Sprintf("line %d", e.Line)
+	if e.Offset > 0 {
+		position += fmt.Sprintf(" (byte %d)", e.Offset)
+	}
+	if e.Column == "" {
+		return fmt.Sprintf("csvtodynamo: %s: %v", position, e.Cause)
+	}
+	return fmt.Sprintf("csvtodynamo: %s: column %q: %v", position, e.Column, e.Cause)
+}
+
+// Unwrap returns the Cause.
+func (e *ErrRowConversion) Unwrap() error {
+	return e.Cause
+}
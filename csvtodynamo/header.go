@@ -0,0 +1,50 @@
+package csvtodynamo
+
+import "strings"
+
+// nonBreakingSpaces are spaces which look like ordinary spaces, but don't match them.
+const nonBreakingSpaces = "\u00a0\u2007\u202f"
+
+// CleanColumnName removes the characters that spreadsheet programs such as Excel add to header
+// names, which can't be seen, but stop columns from matching the names passed in flags, e.g.
+// "\ufeffid" doesn't match "id". Byte order marks and zero-width characters are removed,
+// non-breaking spaces are removed from the start and end, and replaced with ordinary spaces
+// elsewhere.
+func CleanColumnName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '\ufeff', '\u200b', '\u200c', '\u200d', '\u2060':
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, nonBreakingSpaces)
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(nonBreakingSpaces, r) {
+			return ' '
+		}
+		return r
+	}, name)
+}
+
+// cleanHeader returns the header with each name cleaned by CleanColumnName, and the names which
+// were changed, keyed by their original value.
+func cleanHeader(header []string) (names []string, cleaned map[string]string) {
+	names = make([]string, len(header))
+	for i, name := range header {
+		names[i] = CleanColumnName(name)
+		if names[i] != name {
+			if cleaned == nil {
+				cleaned = make(map[string]string)
+			}
+			cleaned[name] = names[i]
+		}
+	}
+	return
+}
+
+// CleanedColumns returns the column names of the header which were changed by CleanColumnName,
+// keyed by their original value, so that they can be reported.
+func (c *Converter) CleanedColumns() map[string]string {
+	return c.cleanedColumns
+}
@@ -0,0 +1,71 @@
+package csvtodynamo
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCleanColumnName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{name: "id", expected: "id"},
+		{name: "\ufeffid", expected: "id"},
+		{name: "\u200bemail\u200d", expected: "email"},
+		{name: "\u00a0Email Address\u00a0", expected: "Email Address"},
+		{name: "Email\u00a0Address", expected: "Email Address"},
+		{name: " padded ", expected: " padded "},
+	}
+	for _, tt := range tests {
+		if actual := CleanColumnName(tt.name); actual != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.name, tt.expected, actual)
+		}
+	}
+}
+
+func TestConverterCleansHeader(t *testing.T) {
+	input := strings.Join([]string{
+		"\ufeffid,name\u00a0",
+		"1,Alice",
+		"\ufeffid,name\u00a0",
+		"2,Bob",
+	}, "\n")
+	conf := NewConfiguration().AddNumberKeys("id")
+	conf.SkipRepeatedHeaders = true
+	c, err := NewConverter(csv.NewReader(strings.NewReader(input)), conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	items, read, err := c.ReadBatch()
+	if err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	expected := []map[string]*dynamodb.AttributeValue{
+		{"id": {N: aws.String("1")}, "name": {S: aws.String("Alice")}},
+		{"id": {N: aws.String("2")}, "name": {S: aws.String("Bob")}},
+	}
+	if diff := cmp.Diff(expected, items[:read]); diff != "" {
+		t.Error(diff)
+	}
+	expectedCleaned := map[string]string{"\ufeffid": "id", "name\u00a0": "name"}
+	if diff := cmp.Diff(expectedCleaned, c.CleanedColumns()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestInferTypesCleansHeader(t *testing.T) {
+	it, err := InferTypes(csv.NewReader(strings.NewReader("\ufeffid,name\n1,Alice\n")), nil, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff([]string{"id"}, it.Keys(TypeNumber)); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,114 @@
+package csvtodynamo
+
+import (
+	"encoding/csv"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Types inferred by InferTypes.
+const (
+	TypeString = "S"
+	TypeNumber = "N"
+	TypeBool   = "BOOL"
+)
+
+// maxNumberDigits is the precision of DynamoDB numbers.
+const maxNumberDigits = 38
+
+// number matches numbers which DynamoDB stores without changing them. Values with leading zeros,
+// e.g. zip codes and phone numbers, would lose them, so they're strings.
+var number = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// InferredTypes are the types of the columns of a sample of rows.
+type InferredTypes struct {
+	// Columns in the order of the header.
+	Columns []string
+	// Types of each column, TypeString, TypeNumber or TypeBool.
+	Types map[string]string
+	// Rows is the number of rows sampled.
+	Rows int
+}
+
+// Keys returns the columns of the type, in the order of the header.
+func (it InferredTypes) Keys(t string) (keys []string) {
+	for _, c := range it.Columns {
+		if it.Types[c] == t {
+			keys = append(keys, c)
+		}
+	}
+	return keys
+}
+
+// InferTypes reads the header, unless the columns are passed, and up to maxRows rows from r.
+// Columns where every value is a number are TypeNumber, where every value is true or false
+// they're TypeBool, and otherwise they're TypeString. Empty values, and the nullTokens, are
+// ignored, and columns without any other values are TypeString.
+func InferTypes(r *csv.Reader, columns []string, maxRows int, nullTokens ...string) (it InferredTypes, err error) {
+	if columns == nil {
+		if columns, err = r.Read(); err != nil {
+			return
+		}
+		columns, _ = cleanHeader(columns)
+	}
+	it.Columns = columns
+	isNull := make(map[string]bool, len(nullTokens))
+	for _, t := range nullTokens {
+		isNull[t] = true
+	}
+	// Columns start as unknown, and become less specific as values are seen.
+	types := make([]string, len(columns))
+	for it.Rows < maxRows {
+		var record []string
+		if record, err = r.Read(); err != nil {
+			break
+		}
+		it.Rows++
+		for i, v := range record {
+			if i >= len(columns) || v == "" || isNull[v] {
+				continue
+			}
+			types[i] = widen(types[i], valueType(v))
+		}
+	}
+	if err != io.EOF && err != nil {
+		return
+	}
+	err = nil
+	it.Types = make(map[string]string, len(columns))
+	for i, c := range columns {
+		if types[i] == "" {
+			types[i] = TypeString
+		}
+		it.Types[c] = types[i]
+	}
+	return
+}
+
+func valueType(v string) string {
+	if _, ok := boolValues[v]; ok {
+		return TypeBool
+	}
+	if number.MatchString(v) && digits(v) <= maxNumberDigits {
+		return TypeNumber
+	}
+	return TypeString
+}
+
+// digits returns the number of significant digits of the number.
+func digits(v string) (n int) {
+	if i := strings.IndexAny(v, "eE"); i >= 0 {
+		v = v[:i]
+	}
+	v = strings.TrimLeft(strings.Replace(strings.TrimPrefix(v, "-"), ".", "", 1), "0")
+	return len(v)
+}
+
+// widen returns the type which can hold values of both types.
+func widen(a, b string) string {
+	if a == "" || a == b {
+		return b
+	}
+	return TypeString
+}
@@ -0,0 +1,77 @@
+package csvtodynamo
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestInferTypes(t *testing.T) {
+	input := strings.Join([]string{
+		"id,price,active,zip,name,empty,mixed",
+		"1,9.99,true,01234,Alice,,1",
+		"2,-10,FALSE,12345,Bob,NULL,true",
+		"3,1e3,,90210,7,,",
+		"4,NULL,false,,Carol,,",
+	}, "\n")
+	actual, err := InferTypes(csv.NewReader(strings.NewReader(input)), nil, 100, "NULL")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := InferredTypes{
+		Columns: []string{"id", "price", "active", "zip", "name", "empty", "mixed"},
+		Types: map[string]string{
+			"id":     TypeNumber,
+			"price":  TypeNumber,
+			"active": TypeBool,
+			"zip":    TypeString,
+			"name":   TypeString,
+			"empty":  TypeString,
+			"mixed":  TypeString,
+		},
+		Rows: 4,
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff([]string{"id", "price"}, actual.Keys(TypeNumber)); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestInferTypesMaxRows(t *testing.T) {
+	input := "a\n1\n2\nthree\n"
+	actual, err := InferTypes(csv.NewReader(strings.NewReader(input)), nil, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if actual.Rows != 2 || actual.Types["a"] != TypeNumber {
+		t.Errorf("expected only the first 2 rows to be sampled, got %d rows and type %s", actual.Rows, actual.Types["a"])
+	}
+}
+
+func TestValueType(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"0", TypeNumber},
+		{"-0.5", TypeNumber},
+		{"1E-7", TypeNumber},
+		{"007", TypeString},
+		{".5", TypeString},
+		{"NaN", TypeString},
+		{"0x1F", TypeString},
+		{"12345678901234567890123456789012345678", TypeNumber},
+		{"123456789012345678901234567890123456789", TypeString},
+		{"TRUE", TypeBool},
+		{"yes", TypeString},
+	}
+	for _, test := range tests {
+		if actual := valueType(test.value); actual != test.expected {
+			t.Errorf("%q: expected %s, got %s", test.value, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package csvtodynamo
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Lookup is a small table of reference data held in memory. Each item read by the Converter
+// is joined to the Lookup on Column, and the attributes of the matching row are added to
+// the item.
+type Lookup struct {
+	Column string
+	rows   map[string]map[string]*dynamodb.AttributeValue
+}
+
+// NewLookup reads the whole of r into memory, keyed by the value of column. The CSV must have
+// a header row. Values are converted using conf, so the types configured for the main input
+// also apply to the lookup columns.
+func NewLookup(r *csv.Reader, column string, conf *Configuration) (l *Lookup, err error) {
+	c, err := NewConverter(r, conf)
+	if err != nil {
+		return
+	}
+	index := -1
+	for i, name := range c.columnNames {
+		if name == column {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		err = fmt.Errorf("csvtodynamo: lookup column %q not found in header", column)
+		return
+	}
+	l = &Lookup{
+		Column: column,
+		rows:   make(map[string]map[string]*dynamodb.AttributeValue),
+	}
+	for {
+		var record []string
+		record, err = r.Read()
+		c.records++
+		if err == io.EOF {
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+		var item map[string]*dynamodb.AttributeValue
+		if item, err = c.convert(record); err != nil {
+			return
+		}
+		delete(item, column)
+		l.rows[record[index]] = item
+	}
+}
+
+// Len returns the number of rows in the Lookup.
+func (l *Lookup) Len() int {
+	return len(l.rows)
+}
+
+// enrich the item with the attributes of the matching lookup row. Attributes already present
+// in the item are not overwritten.
+func (l *Lookup) enrich(columnNames, record []string, item map[string]*dynamodb.AttributeValue) {
+	for i, name := range columnNames {
+		if name != l.Column {
+			continue
+		}
+		for k, v := range l.rows[record[i]] {
+			if _, exists := item[k]; !exists {
+				item[k] = v
+			}
+		}
+		return
+	}
+}
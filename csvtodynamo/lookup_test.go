@@ -0,0 +1,72 @@
+package csvtodynamo
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLookup(t *testing.T) {
+	lookupInput := strings.Join([]string{
+		"country,name,population",
+		"GB,United Kingdom,67",
+		"FR,France,65",
+	}, "\n")
+	conf := NewConfiguration().AddNumberKeys("population")
+	lookup, err := NewLookup(csv.NewReader(strings.NewReader(lookupInput)), "country", conf)
+	if err != nil {
+		t.Fatalf("failed to create lookup: %v", err)
+	}
+	if lookup.Len() != 2 {
+		t.Errorf("expected 2 lookup rows, got %d", lookup.Len())
+	}
+
+	input := strings.Join([]string{
+		"id,country,name",
+		"1,GB,",
+		"2,FR,Republique",
+		"3,DE,",
+	}, "\n")
+	c, err := NewConverter(csv.NewReader(strings.NewReader(input)), conf.AddLookups(lookup))
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	actual, read, err := c.ReadBatch()
+	if err != io.EOF {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []map[string]*dynamodb.AttributeValue{
+		{
+			"id":         &dynamodb.AttributeValue{S: aws.String("1")},
+			"country":    &dynamodb.AttributeValue{S: aws.String("GB")},
+			"name":       &dynamodb.AttributeValue{S: aws.String("United Kingdom")},
+			"population": &dynamodb.AttributeValue{N: aws.String("67")},
+		},
+		{
+			"id":         &dynamodb.AttributeValue{S: aws.String("2")},
+			"country":    &dynamodb.AttributeValue{S: aws.String("FR")},
+			"name":       &dynamodb.AttributeValue{S: aws.String("Republique")},
+			"population": &dynamodb.AttributeValue{N: aws.String("65")},
+		},
+		{
+			"id":      &dynamodb.AttributeValue{S: aws.String("3")},
+			"country": &dynamodb.AttributeValue{S: aws.String("DE")},
+		},
+	}
+	if diff := cmp.Diff(expected, actual[:read]); diff != "" {
+		t.Error("unexpected result")
+		t.Error(diff)
+	}
+}
+
+func TestLookupMissingColumn(t *testing.T) {
+	_, err := NewLookup(csv.NewReader(strings.NewReader("a,b\n1,2")), "c", nil)
+	if err == nil {
+		t.Error("expected an error when the lookup column is not in the header")
+	}
+}
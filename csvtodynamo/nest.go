@@ -0,0 +1,68 @@
+package csvtodynamo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// nest folds the attributes of the item whose names contain the NestSeparator into nested
+// maps, e.g. address.city and address.zip into an address map with city and zip attributes.
+// Table keys aren't nested, because DynamoDB keys must be top level attributes, and neither
+// are names with an empty part, e.g. .city. If the attributes can't be nested, the name of the
+// attribute which couldn't be is returned with the error.
+func (c *Converter) nest(item map[string]*dynamodb.AttributeValue) (attribute string, err error) {
+	sep := c.conf.NestSeparator
+	var names []string
+	for name := range item {
+		if strings.Contains(name, sep) && !c.isTableKey(name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "", nil
+	}
+	// Sort the names, so that conflicts are reported consistently.
+	sort.Strings(names)
+	created := make(map[*dynamodb.AttributeValue]bool)
+	for _, name := range names {
+		path := strings.Split(name, sep)
+		if contains(path, "") {
+			continue
+		}
+		av := item[name]
+		delete(item, name)
+		m := item
+		for i, key := range path[:len(path)-1] {
+			parent, ok := m[key]
+			if !ok {
+				parent = &dynamodb.AttributeValue{M: make(map[string]*dynamodb.AttributeValue)}
+				created[parent] = true
+				m[key] = parent
+			}
+			if !created[parent] {
+				return name, fmt.Errorf("%w: %s is also an attribute", ErrNestingConflict, strings.Join(path[:i+1], sep))
+			}
+			m = parent.M
+		}
+		if _, ok := m[path[len(path)-1]]; ok {
+			return name, fmt.Errorf("%w: %s is also a map", ErrNestingConflict, name)
+		}
+		m[path[len(path)-1]] = av
+	}
+	for name, av := range item {
+		if created[av] {
+			if err = CheckDepth(av); err != nil {
+				return name, err
+			}
+		}
+	}
+	return "", nil
+}
+
+// isTableKey returns true if the attribute is one of the TableKeys.
+func (c *Converter) isTableKey(attribute string) bool {
+	return contains(c.conf.TableKeys, attribute)
+}
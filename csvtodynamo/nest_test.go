@@ -0,0 +1,106 @@
+package csvtodynamo
+
+import (
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConverterNest(t *testing.T) {
+	input := strings.Join([]string{
+		"id,address.city,address.zip,address.geo.lat,contact.email,a..b",
+		"1,Leeds,LS1,53.8,,x",
+	}, "\n")
+	conf := NewConfiguration().AddNumberKeys("address.geo.lat")
+	conf.NestSeparator = "."
+	conf.TableKeys = []string{"id"}
+	c, err := NewConverter(csv.NewReader(strings.NewReader(input)), conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	actual, err := c.Read()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]*dynamodb.AttributeValue{
+		"id": {S: aws.String("1")},
+		"address": {M: map[string]*dynamodb.AttributeValue{
+			"city": {S: aws.String("Leeds")},
+			"zip":  {S: aws.String("LS1")},
+			"geo": {M: map[string]*dynamodb.AttributeValue{
+				"lat": {N: aws.String("53.8")},
+			}},
+		}},
+		"a..b": {S: aws.String("x")},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestConverterNestTableKeys(t *testing.T) {
+	input := "order.id,order.total\n1,10"
+	conf := NewConfiguration()
+	conf.NestSeparator = "."
+	conf.TableKeys = []string{"order.id"}
+	c, err := NewConverter(csv.NewReader(strings.NewReader(input)), conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	actual, err := c.Read()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]*dynamodb.AttributeValue{
+		"order.id": {S: aws.String("1")},
+		"order": {M: map[string]*dynamodb.AttributeValue{
+			"total": {S: aws.String("10")},
+		}},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestConverterNestConflicts(t *testing.T) {
+	tests := []struct {
+		name              string
+		input             string
+		expectedAttribute string
+	}{
+		{
+			name:              "maps which are also attributes",
+			input:             "address,address.city\nx,Leeds",
+			expectedAttribute: "address.city",
+		},
+		{
+			name:              "attributes which are also maps",
+			input:             "a.b,a.b.c\nx,y",
+			expectedAttribute: "a.b.c",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			conf := NewConfiguration()
+			conf.NestSeparator = "."
+			c, err := NewConverter(csv.NewReader(strings.NewReader(tt.input)), conf)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_, err = c.Read()
+			var rce *ErrRowConversion
+			if !errors.As(err, &rce) || !errors.Is(err, ErrNestingConflict) {
+				t.Fatalf("expected a nesting conflict, got %v", err)
+			}
+			if rce.Column != tt.expectedAttribute {
+				t.Errorf("expected the conflict to be reported for %q, got %q", tt.expectedAttribute, rce.Column)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package csvtodynamo
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Normalization cleans up values before they're converted, e.g. so that partition keys with
+// trailing spaces in a dirty CSV file can still be looked up.
+type Normalization struct {
+	// Trim removes leading and trailing whitespace.
+	Trim bool
+	// Collapse replaces each run of whitespace with a single space.
+	Collapse bool
+	// Lower and Upper change the case of the value.
+	Lower, Upper bool
+}
+
+// ParseNormalization parses a list of normalizations separated by '+', e.g. "trim+lower". The
+// normalizations are trim, collapse, lower and upper.
+func ParseNormalization(s string) (n Normalization, err error) {
+	for _, op := range strings.Split(s, "+") {
+		switch strings.TrimSpace(op) {
+		case "trim":
+			n.Trim = true
+		case "collapse":
+			n.Collapse = true
+		case "lower":
+			n.Lower = true
+		case "upper":
+			n.Upper = true
+		default:
+			return n, fmt.Errorf("unknown normalization %q, use trim, collapse, lower or upper", op)
+		}
+	}
+	if n.Lower && n.Upper {
+		return n, fmt.Errorf("lower and upper can't be used together")
+	}
+	return n, nil
+}
+
+// apply the normalization to the value.
+func (n Normalization) apply(s string) string {
+	if n.Trim {
+		s = strings.TrimSpace(s)
+	}
+	if n.Collapse {
+		s = collapse(s)
+	}
+	if n.Lower {
+		s = strings.ToLower(s)
+	}
+	if n.Upper {
+		s = strings.ToUpper(s)
+	}
+	return s
+}
+
+// collapse replaces each run of whitespace with a single space.
+func collapse(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if !space {
+				sb.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// AddNormalization normalizes the values of the column before they're converted, instead of the
+// global Normalization.
+func (conf *Configuration) AddNormalization(column string, n Normalization) *Configuration {
+	if conf.Normalizations == nil {
+		conf.Normalizations = make(map[string]Normalization)
+	}
+	conf.Normalizations[column] = n
+	return conf
+}
+
+// normalize returns a copy of the values of the row with the Normalization of each column
+// applied, or the values if there are no normalizations.
+func (c *Converter) normalize(columnNames, values []string) []string {
+	if c.conf.Normalization == (Normalization{}) && len(c.conf.Normalizations) == 0 {
+		return values
+	}
+	normalized := make([]string, len(values))
+	for i, v := range values {
+		n := c.conf.Normalization
+		if i < len(columnNames) {
+			if cn, ok := c.conf.Normalizations[columnNames[i]]; ok {
+				n = cn
+			}
+		}
+		normalized[i] = n.apply(v)
+	}
+	return normalized
+}
@@ -0,0 +1,73 @@
+package csvtodynamo
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNormalization(t *testing.T) {
+	tests := []struct {
+		spec     string
+		value    string
+		expected string
+	}{
+		{spec: "trim", value: " \tABC \r\n", expected: "ABC"},
+		{spec: "collapse", value: " a  b\t\tc ", expected: " a b c "},
+		{spec: "trim+collapse", value: " a  b\t\tc ", expected: "a b c"},
+		{spec: "lower", value: "ÉTÉ", expected: "été"},
+		{spec: "trim+upper", value: " abc ", expected: "ABC"},
+	}
+	for _, tt := range tests {
+		n, err := ParseNormalization(tt.spec)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.spec, err)
+		}
+		if actual := n.apply(tt.value); actual != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.spec, tt.expected, actual)
+		}
+	}
+}
+
+func TestParseNormalizationErrors(t *testing.T) {
+	for _, spec := range []string{"", "trim+", "title", "lower+upper"} {
+		if _, err := ParseNormalization(spec); err == nil {
+			t.Errorf("%q: expected an error", spec)
+		}
+	}
+}
+
+func TestConverterNormalization(t *testing.T) {
+	input := strings.Join([]string{
+		"id,email,name,notes",
+		"ABC ,  Alice@Example.COM ,Alice   Smith,  ",
+	}, "\n")
+	conf := NewConfiguration()
+	conf.Normalization = Normalization{Trim: true}
+	conf.AddNormalization("email", Normalization{Trim: true, Lower: true})
+	conf.AddNormalization("name", Normalization{Collapse: true})
+	if _, err := conf.AddTemplateKey("pk", "USER#{{.id}}"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c, err := NewConverter(csv.NewReader(strings.NewReader(input)), conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	actual, err := c.Read()
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	expected := map[string]*dynamodb.AttributeValue{
+		"id":    {S: aws.String("ABC")},
+		"email": {S: aws.String("alice@example.com")},
+		"name":  {S: aws.String("Alice Smith")},
+		"pk":    {S: aws.String("USER#ABC")},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
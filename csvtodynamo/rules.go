@@ -0,0 +1,61 @@
+package csvtodynamo
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Rule validates the values of a column before they're converted, so that rows from corrupted
+// exports are rejected instead of being written. Empty and null values aren't checked.
+type Rule struct {
+	// Pattern that values must match.
+	Pattern *regexp.Regexp
+	// MinLength and MaxLength are the minimum and maximum number of characters in a value. Zero
+	// has no limit.
+	MinLength, MaxLength int
+	// Min and Max are the minimum and maximum numeric values, inclusive. Values which aren't
+	// numbers break the rule. Nil has no limit.
+	Min, Max *float64
+}
+
+// check returns the rule that the value breaks, if any.
+func (r Rule) check(value string) *ErrRuleViolation {
+	if r.Pattern != nil && !r.Pattern.MatchString(value) {
+		return &ErrRuleViolation{Rule: fmt.Sprintf("pattern %q", r.Pattern), Value: value}
+	}
+	if r.MinLength > 0 || r.MaxLength > 0 {
+		length := utf8.RuneCountInString(value)
+		if r.MinLength > 0 && length < r.MinLength {
+			return &ErrRuleViolation{Rule: fmt.Sprintf("minLength %d", r.MinLength), Value: value}
+		}
+		if r.MaxLength > 0 && length > r.MaxLength {
+			return &ErrRuleViolation{Rule: fmt.Sprintf("maxLength %d", r.MaxLength), Value: value}
+		}
+	}
+	if r.Min == nil && r.Max == nil {
+		return nil
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || !dynamoNumber.MatchString(value) {
+		return &ErrRuleViolation{Rule: "number", Value: value}
+	}
+	if r.Min != nil && n < *r.Min {
+		return &ErrRuleViolation{Rule: fmt.Sprintf("min %v", *r.Min), Value: value}
+	}
+	if r.Max != nil && n > *r.Max {
+		return &ErrRuleViolation{Rule: fmt.Sprintf("max %v", *r.Max), Value: value}
+	}
+	return nil
+}
+
+// AddRule validates the values of the column with the rule. Values which break it are an
+// ErrRuleViolation.
+func (conf *Configuration) AddRule(column string, r Rule) *Configuration {
+	if conf.Rules == nil {
+		conf.Rules = make(map[string]Rule)
+	}
+	conf.Rules[column] = r
+	return conf
+}
@@ -0,0 +1,78 @@
+package csvtodynamo
+
+import (
+	"encoding/csv"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	zero, hundred := 0.0, 100.0
+	tests := []struct {
+		name         string
+		rule         Rule
+		value        string
+		expectedRule string
+	}{
+		{name: "matching patterns", rule: Rule{Pattern: regexp.MustCompile(`^[A-Z]{2}$`)}, value: "GB"},
+		{name: "patterns", rule: Rule{Pattern: regexp.MustCompile(`^[A-Z]{2}$`)}, value: "GBR", expectedRule: `pattern "^[A-Z]{2}$"`},
+		{name: "lengths in range", rule: Rule{MinLength: 2, MaxLength: 3}, value: "été"},
+		{name: "short values", rule: Rule{MinLength: 2}, value: "a", expectedRule: "minLength 2"},
+		{name: "long values", rule: Rule{MaxLength: 3}, value: "abcd", expectedRule: "maxLength 3"},
+		{name: "numbers in range", rule: Rule{Min: &zero, Max: &hundred}, value: "100"},
+		{name: "small numbers", rule: Rule{Min: &zero}, value: "-0.5", expectedRule: "min 0"},
+		{name: "large numbers", rule: Rule{Max: &hundred}, value: "1e3", expectedRule: "max 100"},
+		{name: "values which aren't numbers", rule: Rule{Min: &zero}, value: "NaN", expectedRule: "number"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rv := tt.rule.check(tt.value)
+			if tt.expectedRule == "" {
+				if rv != nil {
+					t.Errorf("expected no violation, got %v", rv)
+				}
+				return
+			}
+			if rv == nil {
+				t.Fatalf("expected a violation of %s", tt.expectedRule)
+			}
+			if rv.Rule != tt.expectedRule || rv.Value != tt.value {
+				t.Errorf("expected %q to break %s, got %q breaking %s", tt.value, tt.expectedRule, rv.Value, rv.Rule)
+			}
+		})
+	}
+}
+
+func TestConverterRules(t *testing.T) {
+	input := strings.Join([]string{
+		"id,country",
+		"1,GB",
+		"2,",
+		"3,England",
+	}, "\n")
+	conf := NewConfiguration().AddRule("country", Rule{Pattern: regexp.MustCompile(`^[A-Z]{2}$`)})
+	c, err := NewConverter(csv.NewReader(strings.NewReader(input)), conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err = c.Read(); err != nil {
+			t.Fatalf("expected empty and valid values to be converted, got %v", err)
+		}
+	}
+	_, err = c.Read()
+	var rce *ErrRowConversion
+	var rv *ErrRuleViolation
+	if !errors.As(err, &rce) || !errors.As(err, &rv) {
+		t.Fatalf("expected a rule violation, got %v", err)
+	}
+	if rce.Line != 4 || rce.Column != "country" {
+		t.Errorf("expected line 4, column country, got line %d, column %q", rce.Line, rce.Column)
+	}
+	if rv.Value != "England" {
+		t.Errorf("expected the value England, got %q", rv.Value)
+	}
+}
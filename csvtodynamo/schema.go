@@ -0,0 +1,294 @@
+package csvtodynamo
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"gopkg.in/yaml.v2"
+)
+
+// Types of columns in a Schema, in addition to the types returned by InferTypes.
+const (
+	TypeBinary    = "B"
+	TypeMap       = "M"
+	TypeList      = "L"
+	TypeStringSet = "SS"
+	TypeNumberSet = "NS"
+	TypeBinarySet = "BS"
+)
+
+// SchemaTypes are the DynamoDB types that a column of a Schema can have.
+var SchemaTypes = []string{TypeString, TypeNumber, TypeBool, TypeBinary, TypeMap, TypeList, TypeStringSet, TypeNumberSet, TypeBinarySet}
+
+// Schema describes the columns of a CSV file, so that their types and mappings can be kept in
+// source control, e.g.
+//
+//	columns:
+//	  - name: year
+//	    type: N
+//	  - name: Email Address
+//	    attribute: email
+//	  - name: notes
+//	    include: false
+//	  - name: status
+//	    default: ACTIVE
+//	    normalize: trim+upper
+//	  - name: checksum
+//	    type: B
+//	    encoding: hex
+//	  - name: age
+//	    type: N
+//	    rules:
+//	      min: 0
+//	      max: 150
+//	attributes:
+//	  - name: entityType
+//	    value: ORDER
+//	  - name: pk
+//	    template: "USER#{{.user_id}}"
+type Schema struct {
+	Columns []ColumnSchema `yaml:"columns"`
+	// Attributes are added to every item, in addition to the columns.
+	Attributes []AttributeSchema `yaml:"attributes"`
+}
+
+// AttributeSchema describes an attribute which isn't a column. It has either a constant Value,
+// or a Template.
+type AttributeSchema struct {
+	// Name of the attribute.
+	Name string `yaml:"name"`
+	// Value of the attribute in every item.
+	Value string `yaml:"value"`
+	// Type of the Value, S, N or BOOL. Defaults to S.
+	Type string `yaml:"type"`
+	// Template makes a string value from the other columns of the row. See AddTemplateKey.
+	Template string `yaml:"template"`
+}
+
+// constantTypes are the types that an AttributeSchema Value can have.
+var constantTypes = []string{TypeString, TypeNumber, TypeBool}
+
+// value returns the Value as an attribute value of the Type.
+func (a AttributeSchema) value() (*dynamodb.AttributeValue, error) {
+	switch a.Type {
+	case "", TypeString:
+		return stringValue(a.Value), nil
+	case TypeNumber:
+		if !number.MatchString(a.Value) {
+			return nil, fmt.Errorf("value %q is not a number", a.Value)
+		}
+		return numberValue(a.Value), nil
+	case TypeBool:
+		if v, ok := boolValues[a.Value]; ok {
+			return v, nil
+		}
+		return nil, fmt.Errorf("value %q is not true or false", a.Value)
+	}
+	return nil, fmt.Errorf("unknown type %q, expected one of %v", a.Type, constantTypes)
+}
+
+// ColumnSchema describes a single column of a Schema.
+type ColumnSchema struct {
+	// Name of the column in the header.
+	Name string `yaml:"name"`
+	// Type is the DynamoDB type of the attribute, one of the SchemaTypes. An empty Type leaves
+	// the type to the rest of the configuration, which stores strings by default.
+	Type string `yaml:"type"`
+	// Attribute is the name of the attribute to store the column in. Defaults to the Name.
+	Attribute string `yaml:"attribute"`
+	// Include is set to false to leave the column out of the item. Defaults to true.
+	Include *bool `yaml:"include"`
+	// Default is the value of empty cells, see AddDefault. Empty cells are left out of the item
+	// if there's no Default.
+	Default string `yaml:"default"`
+	// Encoding of the values of B columns, base64 or hex. Defaults to base64.
+	Encoding string `yaml:"encoding"`
+	// Normalize is the Normalization of the values of the column, see ParseNormalization.
+	Normalize string `yaml:"normalize"`
+	// Rules that the values of the column must follow.
+	Rules *RulesSchema `yaml:"rules"`
+}
+
+// RulesSchema describes the Rule of a column.
+type RulesSchema struct {
+	// Pattern is a regular expression that values must match. Use ^ and $ to match the whole
+	// value.
+	Pattern   string   `yaml:"pattern"`
+	MinLength int      `yaml:"minLength"`
+	MaxLength int      `yaml:"maxLength"`
+	Min       *float64 `yaml:"min"`
+	Max       *float64 `yaml:"max"`
+}
+
+// rule returns the Rule described by the schema.
+func (rs RulesSchema) rule() (r Rule, err error) {
+	if rs.Pattern != "" {
+		if r.Pattern, err = regexp.Compile(rs.Pattern); err != nil {
+			return
+		}
+	}
+	if rs.MinLength < 0 || rs.MaxLength < 0 {
+		return r, fmt.Errorf("minLength and maxLength must not be negative")
+	}
+	if rs.MaxLength > 0 && rs.MinLength > rs.MaxLength {
+		return r, fmt.Errorf("minLength %d is greater than maxLength %d", rs.MinLength, rs.MaxLength)
+	}
+	if rs.Min != nil && rs.Max != nil && *rs.Min > *rs.Max {
+		return r, fmt.Errorf("min %v is greater than max %v", *rs.Min, *rs.Max)
+	}
+	r.MinLength, r.MaxLength, r.Min, r.Max = rs.MinLength, rs.MaxLength, rs.Min, rs.Max
+	return
+}
+
+// LoadSchema reads a YAML Schema. Unknown fields are an error, so that mistyped options aren't
+// ignored.
+func LoadSchema(r io.Reader) (s Schema, err error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	if err = yaml.UnmarshalStrict(b, &s); err != nil {
+		return s, fmt.Errorf("csvtodynamo: invalid schema: %w", err)
+	}
+	return s, s.Validate()
+}
+
+// Validate checks that every column has a unique name, a valid type and valid rules, and that no
+// two columns are stored in the same attribute.
+func (s Schema) Validate() error {
+	names := make(map[string]bool, len(s.Columns))
+	attributes := make(map[string]string, len(s.Columns))
+	for i, c := range s.Columns {
+		if c.Name == "" {
+			return fmt.Errorf("csvtodynamo: invalid schema: column %d has no name", i+1)
+		}
+		if names[c.Name] {
+			return fmt.Errorf("csvtodynamo: invalid schema: column %q is defined more than once", c.Name)
+		}
+		names[c.Name] = true
+		if c.Type != "" && !contains(SchemaTypes, c.Type) {
+			return fmt.Errorf("csvtodynamo: invalid schema: column %q has unknown type %q, expected one of %v", c.Name, c.Type, SchemaTypes)
+		}
+		if c.Encoding != "" && (c.Type != TypeBinary || (c.Encoding != "base64" && c.Encoding != "hex")) {
+			return fmt.Errorf("csvtodynamo: invalid schema: column %q has encoding %q, only B columns can have an encoding of base64 or hex", c.Name, c.Encoding)
+		}
+		if c.Normalize != "" {
+			if _, err := ParseNormalization(c.Normalize); err != nil {
+				return fmt.Errorf("csvtodynamo: invalid schema: column %q: %w", c.Name, err)
+			}
+		}
+		if c.Rules != nil {
+			if _, err := c.Rules.rule(); err != nil {
+				return fmt.Errorf("csvtodynamo: invalid schema: column %q has invalid rules: %w", c.Name, err)
+			}
+		}
+		if c.Include != nil && !*c.Include {
+			continue
+		}
+		attribute := c.attribute()
+		if other, ok := attributes[attribute]; ok {
+			return fmt.Errorf("csvtodynamo: invalid schema: columns %q and %q are both stored in attribute %q", other, c.Name, attribute)
+		}
+		attributes[attribute] = c.Name
+	}
+	for i, a := range s.Attributes {
+		if a.Name == "" {
+			return fmt.Errorf("csvtodynamo: invalid schema: attribute %d has no name", i+1)
+		}
+		if other, ok := attributes[a.Name]; ok {
+			return fmt.Errorf("csvtodynamo: invalid schema: attribute %q is also the attribute of column %q", a.Name, other)
+		}
+		attributes[a.Name] = a.Name
+		if (a.Value == "") == (a.Template == "") {
+			return fmt.Errorf("csvtodynamo: invalid schema: attribute %q must have either a value or a template", a.Name)
+		}
+		if a.Template != "" {
+			if _, err := NewConfiguration().AddTemplateKey(a.Name, a.Template); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := a.value(); err != nil {
+			return fmt.Errorf("csvtodynamo: invalid schema: attribute %q: %w", a.Name, err)
+		}
+	}
+	return nil
+}
+
+func (c ColumnSchema) attribute() string {
+	if c.Attribute != "" {
+		return c.Attribute
+	}
+	return c.Name
+}
+
+// Apply the types, attribute names, excluded columns and attributes of the schema to the
+// configuration. The schema must be valid.
+func (s Schema) Apply(conf *Configuration) *Configuration {
+	for _, a := range s.Attributes {
+		// Errors are returned by Validate.
+		if a.Template != "" {
+			conf.AddTemplateKey(a.Name, a.Template)
+			continue
+		}
+		av, _ := a.value()
+		conf.AddConstant(a.Name, av)
+	}
+	for _, c := range s.Columns {
+		if c.Include != nil && !*c.Include {
+			conf.AddExcludedColumns(c.Name)
+			continue
+		}
+		if c.Attribute != "" && c.Attribute != c.Name {
+			conf.AddAttributeName(c.Name, c.Attribute)
+		}
+		if c.Default != "" {
+			conf.AddDefault(c.Name, c.Default)
+		}
+		if c.Normalize != "" {
+			n, _ := ParseNormalization(c.Normalize)
+			conf.AddNormalization(c.Name, n)
+		}
+		if c.Rules != nil {
+			r, _ := c.Rules.rule()
+			conf.AddRule(c.Name, r)
+		}
+		switch c.Type {
+		case TypeNumber:
+			conf.AddNumberKeys(c.Name)
+		case TypeBool:
+			conf.AddBoolKeys(c.Name)
+		case TypeBinary:
+			if c.Encoding == "hex" {
+				conf.AddHexBinKeys(c.Name)
+				continue
+			}
+			conf.AddBinKeys(c.Name)
+		case TypeMap:
+			conf.AddMapKeys(c.Name)
+		case TypeList:
+			conf.AddListKeys(c.Name)
+		case TypeStringSet:
+			conf.AddStringSetKeys(c.Name)
+		case TypeNumberSet:
+			conf.AddNumberSetKeys(c.Name)
+		case TypeBinarySet:
+			conf.AddBinarySetKeys(c.Name)
+		case TypeString:
+			conf.AddStringKeys(c.Name)
+		}
+	}
+	return conf
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+package csvtodynamo
+
+import (
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSchema(t *testing.T) {
+	schema, err := LoadSchema(strings.NewReader(`
+columns:
+  - name: ID
+    attribute: id
+  - name: year
+    type: N
+  - name: active
+    type: BOOL
+    attribute: isActive
+  - name: tags
+    type: SS
+  - name: notes
+    include: false
+  - name: status
+    default: ACTIVE
+  - name: checksum
+    type: B
+    encoding: hex
+`))
+	if err != nil {
+		t.Fatalf("failed to load schema: %v", err)
+	}
+	input := strings.Join([]string{
+		"ID,year,active,tags,notes,name,status,checksum",
+		"1,1999,true,\"a,b\",secret,Alice,,0x0a1b",
+	}, "\n")
+	c, err := NewConverter(csv.NewReader(strings.NewReader(input)), schema.Apply(NewConfiguration()))
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	actual, err := c.Read()
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	expected := map[string]*dynamodb.AttributeValue{
+		"id":       {S: aws.String("1")},
+		"year":     {N: aws.String("1999")},
+		"isActive": {BOOL: aws.Bool(true)},
+		"tags":     {SS: aws.StringSlice([]string{"a", "b"})},
+		"name":     {S: aws.String("Alice")},
+		"status":   {S: aws.String("ACTIVE")},
+		"checksum": {B: []byte{0x0a, 0x1b}},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestSchemaAttributes(t *testing.T) {
+	schema, err := LoadSchema(strings.NewReader(`
+columns:
+  - name: id
+    include: false
+attributes:
+  - name: entityType
+    value: ORDER
+  - name: version
+    value: "3"
+    type: N
+  - name: archived
+    value: "false"
+    type: BOOL
+  - name: pk
+    template: "ORDER#{{.id}}"
+  - name: importedAt
+    template: "{{now}}"
+`))
+	if err != nil {
+		t.Fatalf("failed to load schema: %v", err)
+	}
+	conf := schema.Apply(NewConfiguration())
+	conf.Started = time.Date(2021, time.March, 4, 10, 0, 0, 0, time.UTC)
+	c, err := NewConverter(csv.NewReader(strings.NewReader("id,total\n1,10")), conf)
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	actual, err := c.Read()
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	expected := map[string]*dynamodb.AttributeValue{
+		"total":      {S: aws.String("10")},
+		"entityType": {S: aws.String("ORDER")},
+		"version":    {N: aws.String("3")},
+		"archived":   {BOOL: aws.Bool(false)},
+		"pk":         {S: aws.String("ORDER#1")},
+		"importedAt": {S: aws.String("2021-03-04T10:00:00Z")},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestSchemaKeyColumnsUseAttributeNames(t *testing.T) {
+	conf := NewConfiguration().AddAttributeName("ID", "id").AddKeyColumns("id")
+	c, err := NewConverter(csv.NewReader(strings.NewReader("ID,name\n1,Alice")), conf)
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	actual, err := c.Read()
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	expected := map[string]*dynamodb.AttributeValue{
+		"id": {S: aws.String("1")},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestInvalidSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{
+			name:   "unknown fields",
+			schema: "columns:\n  - name: a\n    typ: N\n",
+		},
+		{
+			name:   "missing names",
+			schema: "columns:\n  - type: N\n",
+		},
+		{
+			name:   "duplicate columns",
+			schema: "columns:\n  - name: a\n  - name: a\n",
+		},
+		{
+			name:   "unknown types",
+			schema: "columns:\n  - name: a\n    type: number\n",
+		},
+		{
+			name:   "columns stored in the same attribute",
+			schema: "columns:\n  - name: a\n  - name: b\n    attribute: a\n",
+		},
+		{
+			name:   "attributes without a value or template",
+			schema: "attributes:\n  - name: a\n",
+		},
+		{
+			name:   "attributes with a value and a template",
+			schema: "attributes:\n  - name: a\n    value: x\n    template: y\n",
+		},
+		{
+			name:   "attributes with values which aren't their type",
+			schema: "attributes:\n  - name: a\n    value: x\n    type: N\n",
+		},
+		{
+			name:   "attributes with invalid templates",
+			schema: "attributes:\n  - name: a\n    template: \"{{.x\"\n",
+		},
+		{
+			name:   "attributes with the same name as a column",
+			schema: "columns:\n  - name: a\nattributes:\n  - name: a\n    value: x\n",
+		},
+		{
+			name:   "rules with invalid patterns",
+			schema: "columns:\n  - name: a\n    rules:\n      pattern: \"[\"\n",
+		},
+		{
+			name:   "rules with a min greater than the max",
+			schema: "columns:\n  - name: a\n    rules:\n      min: 10\n      max: 1\n",
+		},
+		{
+			name:   "rules with a minLength greater than the maxLength",
+			schema: "columns:\n  - name: a\n    rules:\n      minLength: 10\n      maxLength: 1\n",
+		},
+		{
+			name:   "rules with negative lengths",
+			schema: "columns:\n  - name: a\n    rules:\n      minLength: -1\n",
+		},
+		{
+			name:   "unknown normalizations",
+			schema: "columns:\n  - name: a\n    normalize: trim+title\n",
+		},
+		{
+			name:   "unknown encodings",
+			schema: "columns:\n  - name: a\n    type: B\n    encoding: base32\n",
+		},
+		{
+			name:   "encodings of columns which aren't binary",
+			schema: "columns:\n  - name: a\n    encoding: hex\n",
+		},
+		{
+			name:   "invalid YAML",
+			schema: "columns: [",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := LoadSchema(strings.NewReader(test.schema)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestSchemaExcludedColumnsCanShareAttributeNames(t *testing.T) {
+	_, err := LoadSchema(strings.NewReader("columns:\n  - name: a\n  - name: b\n    attribute: a\n    include: false\n"))
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSchemaRules(t *testing.T) {
+	schema, err := LoadSchema(strings.NewReader(`
+columns:
+  - name: email
+    rules:
+      pattern: "^[^@]+@[^@]+$"
+  - name: age
+    type: N
+    rules:
+      min: 0
+      max: 150
+`))
+	if err != nil {
+		t.Fatalf("failed to load schema: %v", err)
+	}
+	input := strings.Join([]string{
+		"email,age",
+		"a@example.com,30",
+		"a@example.com,200",
+	}, "\n")
+	c, err := NewConverter(csv.NewReader(strings.NewReader(input)), schema.Apply(NewConfiguration()))
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	if _, err = c.Read(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = c.Read()
+	var rv *ErrRuleViolation
+	if !errors.As(err, &rv) {
+		t.Fatalf("expected a rule violation, got %v", err)
+	}
+	if diff := cmp.Diff(&ErrRuleViolation{Rule: "max 150", Value: "200"}, rv); diff != "" {
+		t.Error(diff)
+	}
+}
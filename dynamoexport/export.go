@@ -0,0 +1,290 @@
+package dynamoexport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Exporter scans a DynamoDB table.
+type Exporter struct {
+	// OnPage is called after each page of the scan is written. It is not called concurrently.
+	OnPage func(p Progress)
+	// ProjectionExpression limits the attributes that are exported.
+	ProjectionExpression string
+	// FilterExpression limits the items that are exported. Items removed by the filter still
+	// consume read capacity.
+	FilterExpression string
+	// ExpressionAttributeNames are substituted into the ProjectionExpression and
+	// FilterExpression, e.g. {"#t": "type"}.
+	ExpressionAttributeNames map[string]*string
+	// ExpressionAttributeValues are substituted into the FilterExpression,
+	// e.g. {":t": {S: "tenant1"}}.
+	ExpressionAttributeValues map[string]*dynamodb.AttributeValue
+	// TotalSegments is the number of segments of the table to scan in parallel. Zero scans
+	// the table sequentially.
+	TotalSegments int
+	// MaxRCU is the maximum read capacity units consumed per second by each segment. Zero is
+	// unlimited.
+	MaxRCU float64
+	// Checkpoint to resume the scan from. If nil, the scan starts from the beginning.
+	Checkpoint *Checkpoint
+	// OnCheckpoint is called after each page of the scan has been written and flushed, with
+	// the position of every segment. It is not called concurrently. Returning an error stops
+	// the export.
+	OnCheckpoint func(c *Checkpoint) error
+	client       dynamodbiface.DynamoDBAPI
+	tableName    string
+}
+
+// Checkpoint is the position of each segment of a scan.
+type Checkpoint struct {
+	Segments []SegmentCheckpoint `json:"segments"`
+}
+
+// SegmentCheckpoint is the position of a segment of a scan.
+type SegmentCheckpoint struct {
+	// StartKey is the LastEvaluatedKey of the last page written.
+	StartKey map[string]*dynamodb.AttributeValue `json:"startKey,omitempty"`
+	// Items written from the segment.
+	Items int64 `json:"items"`
+	// Done is true when the segment has been read to the end.
+	Done bool `json:"done"`
+}
+
+// MarshalJSON writes the StartKey as DynamoDB JSON.
+func (sc SegmentCheckpoint) MarshalJSON() ([]byte, error) {
+	var startKey map[string]interface{}
+	if sc.StartKey != nil {
+		startKey = dynamoDBJSONMap(sc.StartKey)
+	}
+	return json.Marshal(struct {
+		StartKey map[string]interface{} `json:"startKey,omitempty"`
+		Items    int64                  `json:"items"`
+		Done     bool                   `json:"done"`
+	}{startKey, sc.Items, sc.Done})
+}
+
+// Progress of a segment of the scan.
+type Progress struct {
+	Segment int
+	// SegmentItems is the number of items written from the segment.
+	SegmentItems int64
+	// Items is the number of items written from all segments.
+	Items int64
+	// ConsumedRCU is the read capacity consumed by the segment.
+	ConsumedRCU float64
+	// Done is true when the segment has been read to the end.
+	Done bool
+}
+
+// NewExporter creates an Exporter for the table.
+func NewExporter(client dynamodbiface.DynamoDBAPI, tableName string) *Exporter {
+	return &Exporter{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+func (e *Exporter) scanInput(segment int) *dynamodb.ScanInput {
+	input := &dynamodb.ScanInput{
+		TableName:              aws.String(e.tableName),
+		ReturnConsumedCapacity: aws.String(dynamodb.ReturnConsumedCapacityTotal),
+	}
+	if e.ProjectionExpression != "" {
+		input.ProjectionExpression = aws.String(e.ProjectionExpression)
+	}
+	if e.FilterExpression != "" {
+		input.FilterExpression = aws.String(e.FilterExpression)
+	}
+	if len(e.ExpressionAttributeNames) > 0 {
+		input.ExpressionAttributeNames = e.ExpressionAttributeNames
+	}
+	if len(e.ExpressionAttributeValues) > 0 {
+		input.ExpressionAttributeValues = e.ExpressionAttributeValues
+	}
+	if e.Checkpoint != nil {
+		input.ExclusiveStartKey = e.Checkpoint.Segments[segment].StartKey
+	}
+	if e.TotalSegments > 1 {
+		input.Segment = aws.Int64(int64(segment))
+		input.TotalSegments = aws.Int64(int64(e.TotalSegments))
+	}
+	return input
+}
+
+// Export every item in the table to w, returning the number of items written. If a segment
+// fails, the other segments are stopped. If a Checkpoint is set, the export resumes from it, and
+// the count includes the items written before the Checkpoint.
+func (e *Exporter) Export(w Writer) (items int64, err error) {
+	segments := e.TotalSegments
+	if segments < 1 {
+		segments = 1
+	}
+	cp := &Checkpoint{Segments: make([]SegmentCheckpoint, segments)}
+	if e.Checkpoint != nil {
+		if len(e.Checkpoint.Segments) != segments {
+			return 0, fmt.Errorf("dynamoexport: checkpoint has %d segments, expected %d", len(e.Checkpoint.Segments), segments)
+		}
+		copy(cp.Segments, e.Checkpoint.Segments)
+		for _, s := range cp.Segments {
+			items += s.Items
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var m sync.Mutex
+	var exportErr error
+	var exportErrOnce sync.Once
+	fail := func(err error) {
+		exportErrOnce.Do(func() { exportErr = err })
+		cancel()
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < segments; i++ {
+		if cp.Segments[i].Done {
+			continue
+		}
+		wg.Add(1)
+		go func(segment int) {
+			defer wg.Done()
+			p := Progress{Segment: segment, SegmentItems: cp.Segments[segment].Items}
+			limiter := newRateLimiter(e.MaxRCU)
+			var writeErr error
+			err := e.client.ScanPagesWithContext(ctx, e.scanInput(segment), func(page *dynamodb.ScanOutput, lastPage bool) bool {
+				m.Lock()
+				for _, item := range page.Items {
+					if writeErr = w.Write(item); writeErr != nil {
+						break
+					}
+					p.SegmentItems++
+					items++
+				}
+				if page.ConsumedCapacity != nil {
+					p.ConsumedRCU += aws.Float64Value(page.ConsumedCapacity.CapacityUnits)
+				}
+				p.Items = items
+				p.Done = lastPage
+				if writeErr == nil && e.OnCheckpoint != nil {
+					cp.Segments[segment] = SegmentCheckpoint{StartKey: page.LastEvaluatedKey, Items: p.SegmentItems, Done: lastPage}
+					if writeErr = w.Flush(); writeErr == nil {
+						writeErr = e.OnCheckpoint(cp)
+					}
+				}
+				if writeErr == nil && e.OnPage != nil {
+					e.OnPage(p)
+				}
+				m.Unlock()
+				if writeErr != nil {
+					return false
+				}
+				if page.ConsumedCapacity != nil {
+					limiter.wait(ctx, aws.Float64Value(page.ConsumedCapacity.CapacityUnits))
+				}
+				return ctx.Err() == nil
+			})
+			if err == nil {
+				err = writeErr
+			}
+			if err != nil {
+				fail(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if exportErr != nil {
+		return items, exportErr
+	}
+	return items, w.Flush()
+}
+
+// errEnoughItems stops a discovery scan once enough items have been sampled.
+var errEnoughItems = errors.New("dynamoexport: enough items")
+
+// DiscoverColumns scans the table with the same expressions, segments and read capacity limit
+// as the export, and returns the union of the attribute names of the items, in alphabetical
+// order, so that the columns of a CSV export include sparse attributes. If maxItems is greater
+// than zero, only that many items are sampled. Otherwise, the whole table is scanned, which
+// consumes as much read capacity as the export itself. The Checkpoint and callbacks of the
+// Exporter aren't used.
+func (e *Exporter) DiscoverColumns(maxItems int64) (columns []string, items int64, err error) {
+	discovery := *e
+	discovery.Checkpoint, discovery.OnCheckpoint, discovery.OnPage = nil, nil, nil
+	c := &columnCollector{names: make(map[string]bool), max: maxItems}
+	items, err = discovery.Export(c)
+	if err != nil && !errors.Is(err, errEnoughItems) {
+		return nil, items, err
+	}
+	for name := range c.names {
+		columns = append(columns, name)
+	}
+	sort.Strings(columns)
+	return columns, items, nil
+}
+
+// columnCollector is a Writer which collects the attribute names of the items written to it.
+type columnCollector struct {
+	names map[string]bool
+	items int64
+	max   int64
+}
+
+func (c *columnCollector) Write(item map[string]*dynamodb.AttributeValue) error {
+	if c.max > 0 && c.items >= c.max {
+		return errEnoughItems
+	}
+	for name := range item {
+		c.names[name] = true
+	}
+	c.items++
+	return nil
+}
+
+func (c *columnCollector) Flush() error {
+	return nil
+}
+
+// rateLimiter limits the rate that capacity is consumed.
+type rateLimiter struct {
+	max      float64
+	start    time.Time
+	consumed float64
+	now      func() time.Time
+}
+
+func newRateLimiter(max float64) *rateLimiter {
+	return &rateLimiter{
+		max:   max,
+		start: time.Now(),
+		now:   time.Now,
+	}
+}
+
+// delay returns how long to wait after consuming capacity, so that the average rate since the
+// limiter was created does not exceed the maximum.
+func (r *rateLimiter) delay(consumed float64) time.Duration {
+	if r.max <= 0 {
+		return 0
+	}
+	r.consumed += consumed
+	return time.Duration(r.consumed/r.max*float64(time.Second)) - r.now().Sub(r.start)
+}
+
+func (r *rateLimiter) wait(ctx context.Context, consumed float64) {
+	d := r.delay(consumed)
+	if d <= 0 {
+		return
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
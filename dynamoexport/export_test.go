@@ -0,0 +1,328 @@
+package dynamoexport
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/google/go-cmp/cmp"
+)
+
+type fakeScanner struct {
+	dynamodbiface.DynamoDBAPI
+	// segments of pages.
+	segments [][][]map[string]*dynamodb.AttributeValue
+	err      error
+	m        sync.Mutex
+	inputs   []*dynamodb.ScanInput
+}
+
+func (f *fakeScanner) ScanPagesWithContext(ctx aws.Context, input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput, bool) bool, opts ...request.Option) error {
+	f.m.Lock()
+	f.inputs = append(f.inputs, input)
+	f.m.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	pages := f.segments[aws.Int64Value(input.Segment)]
+	// Skip the pages before the ExclusiveStartKey.
+	for input.ExclusiveStartKey != nil && len(pages) > 0 {
+		last := pages[0][len(pages[0])-1]
+		pages = pages[1:]
+		if cmp.Equal(last, input.ExclusiveStartKey) {
+			break
+		}
+	}
+	for i, page := range pages {
+		output := &dynamodb.ScanOutput{
+			Items:            page,
+			ConsumedCapacity: &dynamodb.ConsumedCapacity{CapacityUnits: aws.Float64(0.5)},
+		}
+		if i < len(pages)-1 {
+			output.LastEvaluatedKey = page[len(page)-1]
+		}
+		if !fn(output, i == len(pages)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+func TestExporter(t *testing.T) {
+	client := &fakeScanner{
+		segments: [][][]map[string]*dynamodb.AttributeValue{
+			{
+				{{"id": {S: aws.String("1")}}, {"id": {S: aws.String("2")}}},
+				{{"id": {S: aws.String("3")}}},
+			},
+		},
+	}
+	e := NewExporter(client, "table")
+	var progress []Progress
+	e.OnPage = func(p Progress) { progress = append(progress, p) }
+	var sb strings.Builder
+	items, err := e.Export(NewCSVWriter(&sb, ',', nil))
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if items != 3 {
+		t.Errorf("expected 3 items, got %d", items)
+	}
+	expectedProgress := []Progress{
+		{Segment: 0, SegmentItems: 2, Items: 2, ConsumedRCU: 0.5},
+		{Segment: 0, SegmentItems: 3, Items: 3, ConsumedRCU: 1, Done: true},
+	}
+	if diff := cmp.Diff(expectedProgress, progress); diff != "" {
+		t.Error(diff)
+	}
+	if diff := cmp.Diff("id\n1\n2\n3\n", sb.String()); diff != "" {
+		t.Error(diff)
+	}
+	if client.inputs[0].Segment != nil || client.inputs[0].TotalSegments != nil {
+		t.Errorf("expected a sequential scan, got %v", client.inputs[0])
+	}
+}
+
+func TestExporterSegments(t *testing.T) {
+	client := &fakeScanner{
+		segments: [][][]map[string]*dynamodb.AttributeValue{
+			{{{"id": {S: aws.String("1")}}}},
+			{{{"id": {S: aws.String("2")}}}, {{"id": {S: aws.String("3")}}}},
+			{},
+		},
+	}
+	e := NewExporter(client, "table")
+	e.TotalSegments = 3
+	done := map[int]int64{}
+	e.OnPage = func(p Progress) {
+		if p.Done {
+			done[p.Segment] = p.SegmentItems
+		}
+	}
+	var sb strings.Builder
+	items, err := e.Export(NewCSVWriter(&sb, ',', []string{"id"}))
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if items != 3 {
+		t.Errorf("expected 3 items, got %d", items)
+	}
+	if diff := cmp.Diff(map[int]int64{0: 1, 1: 2}, done); diff != "" {
+		t.Error(diff)
+	}
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	sort.Strings(lines)
+	if diff := cmp.Diff([]string{"1", "2", "3", "id"}, lines); diff != "" {
+		t.Error(diff)
+	}
+	var segments []int64
+	for _, input := range client.inputs {
+		if aws.Int64Value(input.TotalSegments) != 3 {
+			t.Errorf("expected 3 total segments, got %v", input.TotalSegments)
+		}
+		segments = append(segments, aws.Int64Value(input.Segment))
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	if diff := cmp.Diff([]int64{0, 1, 2}, segments); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestExporterError(t *testing.T) {
+	expected := errors.New("scan failed")
+	e := NewExporter(&fakeScanner{err: expected}, "table")
+	e.TotalSegments = 2
+	if _, err := e.Export(NewNDJSONWriter(&strings.Builder{})); err != expected {
+		t.Errorf("expected %v, got %v", expected, err)
+	}
+}
+
+func TestExporterExpressions(t *testing.T) {
+	client := &fakeScanner{segments: [][][]map[string]*dynamodb.AttributeValue{{}}}
+	e := NewExporter(client, "table")
+	e.ProjectionExpression = "id, #t"
+	e.FilterExpression = "tenant = :t"
+	e.ExpressionAttributeNames = map[string]*string{"#t": aws.String("type")}
+	e.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{":t": {S: aws.String("tenant1")}}
+	if _, err := e.Export(NewNDJSONWriter(&strings.Builder{})); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	expected := []*dynamodb.ScanInput{
+		{
+			TableName:                 aws.String("table"),
+			ProjectionExpression:      aws.String("id, #t"),
+			FilterExpression:          aws.String("tenant = :t"),
+			ExpressionAttributeNames:  map[string]*string{"#t": aws.String("type")},
+			ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":t": {S: aws.String("tenant1")}},
+			ReturnConsumedCapacity:    aws.String(dynamodb.ReturnConsumedCapacityTotal),
+		},
+	}
+	if diff := cmp.Diff(expected, client.inputs); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := newRateLimiter(10)
+	r.start = now
+	r.now = func() time.Time { return now }
+
+	// 5 RCU at 10 RCU per second should take half a second.
+	if d := r.delay(5); d != time.Millisecond*500 {
+		t.Errorf("expected 500ms, got %v", d)
+	}
+	// After 2 seconds, another 10 RCU is within the limit.
+	now = now.Add(time.Second * 2)
+	if d := r.delay(10); d != -time.Millisecond*500 {
+		t.Errorf("expected -500ms, got %v", d)
+	}
+	if d := newRateLimiter(0).delay(1000); d != 0 {
+		t.Errorf("expected no limit, got %v", d)
+	}
+}
+
+func TestExporterCheckpoint(t *testing.T) {
+	client := &fakeScanner{
+		segments: [][][]map[string]*dynamodb.AttributeValue{
+			{{{"id": {S: aws.String("1")}}}, {{"id": {S: aws.String("2")}}}},
+			{{{"id": {S: aws.String("3")}}}},
+		},
+	}
+	e := NewExporter(client, "table")
+	e.TotalSegments = 2
+	var checkpoints []Checkpoint
+	e.OnCheckpoint = func(c *Checkpoint) error {
+		cc := Checkpoint{Segments: make([]SegmentCheckpoint, len(c.Segments))}
+		copy(cc.Segments, c.Segments)
+		checkpoints = append(checkpoints, cc)
+		return nil
+	}
+	if _, err := e.Export(NewNDJSONWriter(&strings.Builder{})); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if len(checkpoints) != 3 {
+		t.Fatalf("expected a checkpoint per page, got %d", len(checkpoints))
+	}
+	expected := Checkpoint{
+		Segments: []SegmentCheckpoint{
+			{Items: 2, Done: true},
+			{Items: 1, Done: true},
+		},
+	}
+	if diff := cmp.Diff(expected, checkpoints[2]); diff != "" {
+		t.Error(diff)
+	}
+
+	// Resume segment 0 after its first page, with segment 1 complete.
+	client.inputs = nil
+	e = NewExporter(client, "table")
+	e.TotalSegments = 2
+	e.Checkpoint = &Checkpoint{
+		Segments: []SegmentCheckpoint{
+			{StartKey: map[string]*dynamodb.AttributeValue{"id": {S: aws.String("1")}}, Items: 1},
+			{Items: 1, Done: true},
+		},
+	}
+	var sb strings.Builder
+	items, err := e.Export(NewCSVWriter(&sb, ',', nil))
+	if err != nil {
+		t.Fatalf("failed to resume export: %v", err)
+	}
+	if items != 3 {
+		t.Errorf("expected 3 items including those before the checkpoint, got %d", items)
+	}
+	if diff := cmp.Diff("id\n2\n", sb.String()); diff != "" {
+		t.Error(diff)
+	}
+	if len(client.inputs) != 1 {
+		t.Errorf("expected only the incomplete segment to be scanned, got %d scans", len(client.inputs))
+	}
+
+	e.Checkpoint = &Checkpoint{}
+	if _, err = e.Export(NewCSVWriter(&sb, ',', nil)); err == nil {
+		t.Error("expected an error when the checkpoint has the wrong number of segments")
+	}
+}
+
+func TestSegmentCheckpointJSON(t *testing.T) {
+	sc := SegmentCheckpoint{StartKey: map[string]*dynamodb.AttributeValue{"id": {N: aws.String("1")}}, Items: 1}
+	b, err := json.Marshal(sc)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	if diff := cmp.Diff(`{"startKey":{"id":{"N":"1"}},"items":1,"done":false}`, string(b)); diff != "" {
+		t.Error(diff)
+	}
+	var actual SegmentCheckpoint
+	if err = json.Unmarshal(b, &actual); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+	if diff := cmp.Diff(sc, actual); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDiscoverColumns(t *testing.T) {
+	client := &fakeScanner{
+		segments: [][][]map[string]*dynamodb.AttributeValue{
+			{
+				{{"id": {S: aws.String("1")}}, {"id": {S: aws.String("2")}, "email": {S: aws.String("a@example.com")}}},
+				{{"id": {S: aws.String("3")}, "phone": {S: aws.String("0123")}}},
+			},
+			{
+				{{"id": {S: aws.String("4")}, "age": {N: aws.String("42")}}},
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		maxItems int64
+		segments int
+		expected []string
+	}{
+		{
+			name:     "the whole table",
+			expected: []string{"email", "id", "phone"},
+		},
+		{
+			name:     "a sample of the table",
+			maxItems: 2,
+			expected: []string{"email", "id"},
+		},
+		{
+			name:     "all segments",
+			segments: 2,
+			expected: []string{"age", "email", "id", "phone"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := NewExporter(client, "table")
+			e.TotalSegments = test.segments
+			e.OnPage = func(p Progress) { t.Error("unexpected call to OnPage") }
+			columns, _, err := e.DiscoverColumns(test.maxItems)
+			if err != nil {
+				t.Fatalf("failed to discover columns: %v", err)
+			}
+			if diff := cmp.Diff(test.expected, columns); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestDiscoverColumnsError(t *testing.T) {
+	e := NewExporter(&fakeScanner{err: errors.New("access denied")}, "table")
+	if _, _, err := e.DiscoverColumns(0); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,407 @@
+// Package dynamoexport writes the items of a DynamoDB table to files.
+package dynamoexport
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Writer writes items to an output format.
+type Writer interface {
+	Write(item map[string]*dynamodb.AttributeValue) error
+	// Flush any buffered data to the underlying writer.
+	Flush() error
+}
+
+// Formats that the table can be exported in.
+var Formats = []string{"csv", "ndjson", "dynamodb"}
+
+// NewWriter creates a Writer for the named format: csv, which can be imported by csvtodynamo,
+// ndjson for plain JSON, or dynamodb for DynamoDB JSON in the format of DynamoDB's S3 export,
+// which preserves the types of maps, lists and sets.
+func NewWriter(format string, w io.Writer, delimiter rune) (Writer, error) {
+	switch format {
+	case "csv":
+		return NewCSVWriter(w, delimiter, nil), nil
+	case "ndjson":
+		return NewNDJSONWriter(w), nil
+	case "dynamodb":
+		return NewDynamoDBJSONWriter(w), nil
+	}
+	return nil, fmt.Errorf("dynamoexport: unknown format %q, expected one of %s", format, strings.Join(Formats, ", "))
+}
+
+// CSVWriter writes items as RFC 4180 CSV. Values which contain the delimiter, quotes, line
+// breaks or leading spaces are quoted, and quotes within them are doubled.
+type CSVWriter struct {
+	// AlwaysQuote quotes every value, not just the values which need it.
+	AlwaysQuote bool
+	// TypedHeader appends the DynamoDB type of each column in the first item to the header,
+	// e.g. "year:N", so that consumers know how to read the values. Columns which aren't in the
+	// first item have no type.
+	TypedHeader bool
+	// UseCRLF ends each record with \r\n, as expected by Excel, instead of \n. Line breaks
+	// within values are written as they are.
+	UseCRLF bool
+
+	w             *bufio.Writer
+	delimiter     rune
+	columns       []string
+	headerWritten bool
+	// isColumn is the set of the columns, and dropped counts the items with each attribute
+	// which isn't a column.
+	isColumn map[string]bool
+	dropped  map[string]int64
+}
+
+// NewCSVWriter creates a CSVWriter. If columns is nil, the attribute names of the first item
+// are used, in alphabetical order. Attributes that aren't in the columns are not written.
+func NewCSVWriter(w io.Writer, delimiter rune, columns []string) *CSVWriter {
+	return &CSVWriter{
+		w:         bufio.NewWriter(w),
+		delimiter: delimiter,
+		columns:   columns,
+	}
+}
+
+// Columns returns the columns of the CSV. It is nil until the first item is written, unless
+// columns were passed to NewCSVWriter.
+func (w *CSVWriter) Columns() []string {
+	return w.columns
+}
+
+// SetColumns sets the columns to write, in order, instead of the attribute names of the first
+// item. It has no effect once the first item has been written.
+func (w *CSVWriter) SetColumns(columns []string) {
+	if !w.headerWritten {
+		w.columns = columns
+	}
+}
+
+// Append to an existing CSV with the columns, without writing another header row. If columns
+// is empty, the header row has not been written yet, so it is written as usual.
+func (w *CSVWriter) Append(columns []string) {
+	if len(columns) > 0 {
+		w.columns = columns
+		w.headerWritten = true
+	}
+}
+
+// Write the item. The header row is written before the first item.
+func (w *CSVWriter) Write(item map[string]*dynamodb.AttributeValue) (err error) {
+	if w.columns == nil {
+		w.columns = make([]string, 0, len(item))
+		for k := range item {
+			w.columns = append(w.columns, k)
+		}
+		sort.Strings(w.columns)
+	}
+	if !w.headerWritten {
+		header := w.columns
+		if w.TypedHeader {
+			header = typedHeader(w.columns, item)
+		}
+		if err = w.writeRecord(header); err != nil {
+			return
+		}
+		w.headerWritten = true
+	}
+	record := make([]string, len(w.columns))
+	for i, c := range w.columns {
+		if record[i], err = csvValue(item[c]); err != nil {
+			return fmt.Errorf("dynamoexport: attribute %q: %w", c, err)
+		}
+	}
+	w.countDropped(item)
+	return w.writeRecord(record)
+}
+
+func (w *CSVWriter) countDropped(item map[string]*dynamodb.AttributeValue) {
+	if w.isColumn == nil {
+		w.isColumn = make(map[string]bool, len(w.columns))
+		for _, c := range w.columns {
+			w.isColumn[c] = true
+		}
+		w.dropped = make(map[string]int64)
+	}
+	for name := range item {
+		if !w.isColumn[name] {
+			w.dropped[name]++
+		}
+	}
+}
+
+// Dropped returns the number of items that each attribute which isn't one of the columns was
+// dropped from, e.g. because the columns were taken from the first item, and later items have
+// other attributes.
+func (w *CSVWriter) Dropped() map[string]int64 {
+	dropped := make(map[string]int64, len(w.dropped))
+	for name, n := range w.dropped {
+		dropped[name] = n
+	}
+	return dropped
+}
+
+// typedHeader returns the columns with the DynamoDB type of their value in the item, e.g.
+// "year:N".
+func typedHeader(columns []string, item map[string]*dynamodb.AttributeValue) []string {
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c
+		if t := attributeType(item[c]); t != "" {
+			header[i] += ":" + t
+		}
+	}
+	return header
+}
+
+// attributeType returns the DynamoDB type of the attribute value, e.g. "S", or an empty string
+// if it's nil.
+func attributeType(av *dynamodb.AttributeValue) string {
+	switch {
+	case av == nil:
+		return ""
+	case av.S != nil:
+		return "S"
+	case av.N != nil:
+		return "N"
+	case av.BOOL != nil:
+		return "BOOL"
+	case av.B != nil:
+		return "B"
+	case av.M != nil:
+		return "M"
+	case av.L != nil:
+		return "L"
+	case av.SS != nil:
+		return "SS"
+	case av.NS != nil:
+		return "NS"
+	case av.BS != nil:
+		return "BS"
+	case av.NULL != nil:
+		return "NULL"
+	}
+	return ""
+}
+
+// writeRecord writes a single CSV record.
+func (w *CSVWriter) writeRecord(record []string) error {
+	if !validDelimiter(w.delimiter) {
+		return fmt.Errorf("dynamoexport: invalid CSV delimiter %q", w.delimiter)
+	}
+	var sb strings.Builder
+	for i, field := range record {
+		if i > 0 {
+			sb.WriteRune(w.delimiter)
+		}
+		// A record of a single empty value would be a blank line, which CSV readers skip.
+		if !w.AlwaysQuote && !w.needsQuotes(field) && !(len(record) == 1 && field == "") {
+			sb.WriteString(field)
+			continue
+		}
+		sb.WriteByte('"')
+		sb.WriteString(strings.Replace(field, `"`, `""`, -1))
+		sb.WriteByte('"')
+	}
+	if w.UseCRLF {
+		sb.WriteString("\r\n")
+	} else {
+		sb.WriteByte('\n')
+	}
+	_, err := w.w.WriteString(sb.String())
+	return err
+}
+
+// needsQuotes returns true if the field must be quoted to be read back unchanged.
+func (w *CSVWriter) needsQuotes(field string) bool {
+	if field == "" {
+		return false
+	}
+	if strings.ContainsRune(field, w.delimiter) || strings.ContainsAny(field, "\"\r\n") {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(field)
+	return unicode.IsSpace(r)
+}
+
+// validDelimiter returns true if the delimiter can be used to separate CSV values.
+func validDelimiter(r rune) bool {
+	return r != 0 && r != '"' && r != '\r' && r != '\n' && utf8.ValidRune(r) && r != utf8.RuneError
+}
+
+// Flush the CSV.
+func (w *CSVWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// csvValue formats the attribute value in the same way that csvtodynamo reads them: numbers and
+// booleans as text, binary as base64, and maps as DynamoDB JSON. Lists and sets are also
+// written as DynamoDB JSON.
+func csvValue(av *dynamodb.AttributeValue) (string, error) {
+	switch {
+	case av == nil || av.NULL != nil:
+		return "", nil
+	case av.S != nil:
+		return *av.S, nil
+	case av.N != nil:
+		return *av.N, nil
+	case av.BOOL != nil:
+		if *av.BOOL {
+			return "true", nil
+		}
+		return "false", nil
+	case av.B != nil:
+		return base64.StdEncoding.EncodeToString(av.B), nil
+	case av.M != nil:
+		b, err := json.Marshal(dynamoDBJSONMap(av.M))
+		return string(b), err
+	}
+	b, err := json.Marshal(dynamoDBJSON(av))
+	return string(b), err
+}
+
+// NDJSONWriter writes items as newline delimited JSON.
+type NDJSONWriter struct {
+	w *bufio.Writer
+	e *json.Encoder
+}
+
+// NewNDJSONWriter creates an NDJSONWriter.
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	bw := bufio.NewWriter(w)
+	return &NDJSONWriter{
+		w: bw,
+		e: json.NewEncoder(bw),
+	}
+}
+
+// Write the item as a single line of JSON. Numbers keep their precision, binary values are
+// base64 encoded, and sets are written as arrays.
+func (w *NDJSONWriter) Write(item map[string]*dynamodb.AttributeValue) error {
+	return w.e.Encode(plainJSONMap(item))
+}
+
+// Flush the output.
+func (w *NDJSONWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// DynamoDBJSONWriter writes items as DynamoDB JSON, one per line.
+type DynamoDBJSONWriter struct {
+	w *bufio.Writer
+	e *json.Encoder
+}
+
+// NewDynamoDBJSONWriter creates a DynamoDBJSONWriter.
+func NewDynamoDBJSONWriter(w io.Writer) *DynamoDBJSONWriter {
+	bw := bufio.NewWriter(w)
+	return &DynamoDBJSONWriter{
+		w: bw,
+		e: json.NewEncoder(bw),
+	}
+}
+
+// Write the item as a line of the form {"Item":{"name":{"S":"value"}}}.
+func (w *DynamoDBJSONWriter) Write(item map[string]*dynamodb.AttributeValue) error {
+	return w.e.Encode(map[string]interface{}{"Item": dynamoDBJSONMap(item)})
+}
+
+// Flush the output.
+func (w *DynamoDBJSONWriter) Flush() error {
+	return w.w.Flush()
+}
+
+func plainJSONMap(m map[string]*dynamodb.AttributeValue) map[string]interface{} {
+	v := make(map[string]interface{}, len(m))
+	for k, av := range m {
+		v[k] = plainJSON(av)
+	}
+	return v
+}
+
+func plainJSON(av *dynamodb.AttributeValue) interface{} {
+	switch {
+	case av.S != nil:
+		return *av.S
+	case av.N != nil:
+		return json.Number(*av.N)
+	case av.BOOL != nil:
+		return *av.BOOL
+	case av.B != nil:
+		return av.B
+	case av.M != nil:
+		return plainJSONMap(av.M)
+	case av.L != nil:
+		l := make([]interface{}, len(av.L))
+		for i, e := range av.L {
+			l[i] = plainJSON(e)
+		}
+		return l
+	case av.SS != nil:
+		return aws.StringValueSlice(av.SS)
+	case av.NS != nil:
+		ns := make([]json.Number, len(av.NS))
+		for i, n := range av.NS {
+			ns[i] = json.Number(aws.StringValue(n))
+		}
+		return ns
+	case av.BS != nil:
+		return av.BS
+	}
+	return nil
+}
+
+// DynamoDBJSON returns the item in the form of DynamoDB JSON, where each attribute value only
+// has its type set, ready to be marshalled, e.g. {"id":{"S":"a"}}.
+func DynamoDBJSON(item map[string]*dynamodb.AttributeValue) map[string]interface{} {
+	return dynamoDBJSONMap(item)
+}
+
+func dynamoDBJSONMap(m map[string]*dynamodb.AttributeValue) map[string]interface{} {
+	v := make(map[string]interface{}, len(m))
+	for k, av := range m {
+		v[k] = dynamoDBJSON(av)
+	}
+	return v
+}
+
+// dynamoDBJSON returns the attribute value with only its type set, e.g. {"S":"value"}.
+// Marshalling a dynamodb.AttributeValue directly includes every type, set to null.
+func dynamoDBJSON(av *dynamodb.AttributeValue) map[string]interface{} {
+	switch {
+	case av.S != nil:
+		return map[string]interface{}{"S": *av.S}
+	case av.N != nil:
+		return map[string]interface{}{"N": *av.N}
+	case av.BOOL != nil:
+		return map[string]interface{}{"BOOL": *av.BOOL}
+	case av.B != nil:
+		return map[string]interface{}{"B": av.B}
+	case av.M != nil:
+		return map[string]interface{}{"M": dynamoDBJSONMap(av.M)}
+	case av.L != nil:
+		l := make([]interface{}, len(av.L))
+		for i, e := range av.L {
+			l[i] = dynamoDBJSON(e)
+		}
+		return map[string]interface{}{"L": l}
+	case av.SS != nil:
+		return map[string]interface{}{"SS": aws.StringValueSlice(av.SS)}
+	case av.NS != nil:
+		return map[string]interface{}{"NS": aws.StringValueSlice(av.NS)}
+	case av.BS != nil:
+		return map[string]interface{}{"BS": av.BS}
+	}
+	return map[string]interface{}{"NULL": true}
+}
@@ -0,0 +1,351 @@
+package dynamoexport
+
+import (
+	"encoding/csv"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+
+	"github.com/a-h/ddbimport/csvtodynamo"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+)
+
+var items = []map[string]*dynamodb.AttributeValue{
+	{
+		"id":     {S: aws.String("1")},
+		"count":  {N: aws.String("12345678901234567890")},
+		"active": {BOOL: aws.Bool(true)},
+		"data":   {B: []byte{1, 2}},
+		"owner":  {M: map[string]*dynamodb.AttributeValue{"name": {S: aws.String("a,b")}}},
+		"tags":   {SS: aws.StringSlice([]string{"x", "y"})},
+	},
+	{
+		"id":   {S: aws.String("2")},
+		"list": {L: []*dynamodb.AttributeValue{{N: aws.String("1")}, {NULL: aws.Bool(true)}}},
+	},
+}
+
+func write(t *testing.T, format string) string {
+	var sb strings.Builder
+	w, err := NewWriter(format, &sb, ',')
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	for _, item := range items {
+		if err = w.Write(item); err != nil {
+			t.Fatalf("failed to write: %v", err)
+		}
+	}
+	if err = w.Flush(); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+	return sb.String()
+}
+
+func TestCSVWriter(t *testing.T) {
+	expected := strings.Join([]string{
+		`active,count,data,id,owner,tags`,
+		`true,12345678901234567890,AQI=,1,"{""name"":{""S"":""a,b""}}","{""SS"":[""x"",""y""]}"`,
+		`,,,2,,`,
+		``,
+	}, "\n")
+	if diff := cmp.Diff(expected, write(t, "csv")); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestCSVWriterRoundTrip(t *testing.T) {
+	conf := csvtodynamo.NewConfiguration().
+		AddNumberKeys("count").
+		AddBoolKeys("active").
+		AddBinKeys("data").
+		AddMapKeys("owner")
+	c, err := csvtodynamo.NewConverter(csv.NewReader(strings.NewReader(write(t, "csv"))), conf)
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	actual, err := c.Read()
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	delete(actual, "tags")
+	expected := make(map[string]*dynamodb.AttributeValue)
+	for k, v := range items[0] {
+		expected[k] = v
+	}
+	delete(expected, "tags")
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	expected := `{"active":true,"count":12345678901234567890,"data":"AQI=","id":"1","owner":{"name":"a,b"},"tags":["x","y"]}
+{"id":"2","list":[1,null]}
+`
+	if diff := cmp.Diff(expected, write(t, "ndjson")); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDynamoDBJSONWriter(t *testing.T) {
+	expected := `{"Item":{"active":{"BOOL":true},"count":{"N":"12345678901234567890"},"data":{"B":"AQI="},"id":{"S":"1"},"owner":{"M":{"name":{"S":"a,b"}}},"tags":{"SS":["x","y"]}}}
+{"Item":{"id":{"S":"2"},"list":{"L":[{"N":"1"},{"NULL":true}]}}}
+`
+	if diff := cmp.Diff(expected, write(t, "dynamodb")); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestNewWriterUnknownFormat(t *testing.T) {
+	if _, err := NewWriter("xml", &strings.Builder{}, ','); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestCSVWriterAppend(t *testing.T) {
+	var sb strings.Builder
+	w := NewCSVWriter(&sb, ',', nil)
+	w.Append([]string{"id", "name"})
+	if err := w.Write(map[string]*dynamodb.AttributeValue{"id": {S: aws.String("3")}}); err != nil {
+		t.Fatalf("failed to write: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("failed to flush: %v", err)
+	}
+	if diff := cmp.Diff("3,\n", sb.String()); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestCSVWriterQuoting(t *testing.T) {
+	tests := []struct {
+		name        string
+		delimiter   rune
+		alwaysQuote bool
+		useCRLF     bool
+		columns     []string
+		values      []string
+		expected    string
+	}{
+		{
+			name:      "values with delimiters, quotes and line breaks are quoted",
+			delimiter: ',',
+			columns:   []string{"a", "b", "c", "d"},
+			values:    []string{"x,y", `say "hi"`, "line 1\nline 2", "plain"},
+			expected:  "a,b,c,d\n\"x,y\",\"say \"\"hi\"\"\",\"line 1\nline 2\",plain\n",
+		},
+		{
+			name:      "values with leading spaces are quoted",
+			delimiter: ',',
+			columns:   []string{"a", "b"},
+			values:    []string{" x", "y "},
+			expected:  "a,b\n\" x\",y \n",
+		},
+		{
+			name:      "commas are not quoted in tab delimited files",
+			delimiter: '\t',
+			columns:   []string{"a", "b"},
+			values:    []string{"x,y", "x\ty"},
+			expected:  "a\tb\nx,y\t\"x\ty\"\n",
+		},
+		{
+			name:      "a single empty value is quoted so that the line isn't blank",
+			delimiter: ',',
+			columns:   []string{"a"},
+			values:    []string{""},
+			expected:  "a\n\"\"\n",
+		},
+		{
+			name:        "every value can be quoted",
+			delimiter:   ',',
+			alwaysQuote: true,
+			columns:     []string{"a", "b"},
+			values:      []string{"x", ""},
+			expected:    "\"a\",\"b\"\n\"x\",\"\"\n",
+		},
+		{
+			name:      "lines can end with CRLF",
+			delimiter: ',',
+			useCRLF:   true,
+			columns:   []string{"a", "b"},
+			values:    []string{"x", "y\nz"},
+			expected:  "a,b\r\nx,\"y\nz\"\r\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sb strings.Builder
+			w := NewCSVWriter(&sb, test.delimiter, test.columns)
+			w.AlwaysQuote = test.alwaysQuote
+			w.UseCRLF = test.useCRLF
+			item := make(map[string]*dynamodb.AttributeValue)
+			for i, c := range test.columns {
+				item[c] = &dynamodb.AttributeValue{S: aws.String(test.values[i])}
+			}
+			if err := w.Write(item); err != nil {
+				t.Fatalf("failed to write: %v", err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatalf("failed to flush: %v", err)
+			}
+			if diff := cmp.Diff(test.expected, sb.String()); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestCSVWriterInvalidDelimiter(t *testing.T) {
+	w := NewCSVWriter(&strings.Builder{}, '"', nil)
+	if err := w.Write(map[string]*dynamodb.AttributeValue{"id": {S: aws.String("1")}}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+// csvTable is a randomly generated table of string values, which are likely to contain
+// delimiters, quotes and line breaks.
+type csvTable struct {
+	Delimiter   rune
+	AlwaysQuote bool
+	UseCRLF     bool
+	Rows        [][]string
+}
+
+var csvDelimiters = []rune{',', ';', '\t', '|'}
+
+var csvRunes = []rune{'a', 'Z', '1', 'é', '世', ' ', ',', ';', '\t', '|', '"', '\'', '\r', '\n', '\\', '.'}
+
+func (csvTable) Generate(r *rand.Rand, size int) reflect.Value {
+	table := csvTable{
+		Delimiter:   csvDelimiters[r.Intn(len(csvDelimiters))],
+		AlwaysQuote: r.Intn(2) == 0,
+		UseCRLF:     r.Intn(2) == 0,
+	}
+	columns := 1 + r.Intn(4)
+	rows := 1 + r.Intn(size+1)
+	for i := 0; i < rows; i++ {
+		row := make([]string, columns)
+		for j := range row {
+			value := make([]rune, r.Intn(size+1))
+			for k := range value {
+				value[k] = csvRunes[r.Intn(len(csvRunes))]
+			}
+			row[j] = string(value)
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	return reflect.ValueOf(table)
+}
+
+func TestCSVWriterRoundTripProperty(t *testing.T) {
+	roundTrip := func(table csvTable) bool {
+		columns := make([]string, len(table.Rows[0]))
+		for i := range columns {
+			columns[i] = string(rune('a' + i))
+		}
+		var sb strings.Builder
+		w := NewCSVWriter(&sb, table.Delimiter, columns)
+		w.AlwaysQuote = table.AlwaysQuote
+		w.UseCRLF = table.UseCRLF
+		for _, row := range table.Rows {
+			item := make(map[string]*dynamodb.AttributeValue)
+			for i, c := range columns {
+				item[c] = &dynamodb.AttributeValue{S: aws.String(row[i])}
+			}
+			if err := w.Write(item); err != nil {
+				t.Errorf("failed to write: %v", err)
+				return false
+			}
+		}
+		if err := w.Flush(); err != nil {
+			t.Errorf("failed to flush: %v", err)
+			return false
+		}
+		r := csv.NewReader(strings.NewReader(sb.String()))
+		r.Comma = table.Delimiter
+		actual, err := r.ReadAll()
+		if err != nil {
+			t.Errorf("failed to read %q: %v", sb.String(), err)
+			return false
+		}
+		// The encoding/csv reader reads \r\n within quoted values as \n.
+		expected := [][]string{columns}
+		for _, row := range table.Rows {
+			normalized := make([]string, len(row))
+			for i, v := range row {
+				normalized[i] = strings.Replace(v, "\r\n", "\n", -1)
+			}
+			expected = append(expected, normalized)
+		}
+		if diff := cmp.Diff(expected, actual); diff != "" {
+			t.Errorf("%q: %s", sb.String(), diff)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(roundTrip, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCSVWriterColumns(t *testing.T) {
+	tests := []struct {
+		name        string
+		columns     []string
+		typedHeader bool
+		expected    string
+	}{
+		{
+			name:     "columns can be selected and ordered",
+			columns:  []string{"id", "missing", "count"},
+			expected: "id,missing,count\n1,,12345678901234567890\n2,,\n",
+		},
+		{
+			name:        "the header can include the type of each column in the first item",
+			columns:     []string{"id", "count", "list"},
+			typedHeader: true,
+			expected:    "id:S,count:N,list\n1,12345678901234567890,\n2,,\"{\"\"L\"\":[{\"\"N\"\":\"\"1\"\"},{\"\"NULL\"\":true}]}\"\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sb strings.Builder
+			w := NewCSVWriter(&sb, ',', nil)
+			w.SetColumns(test.columns)
+			w.TypedHeader = test.typedHeader
+			for _, item := range items {
+				if err := w.Write(item); err != nil {
+					t.Fatalf("failed to write: %v", err)
+				}
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatalf("failed to flush: %v", err)
+			}
+			if diff := cmp.Diff(test.expected, sb.String()); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestCSVWriterDropped(t *testing.T) {
+	var sb strings.Builder
+	w := NewCSVWriter(&sb, ',', nil)
+	for _, item := range []map[string]*dynamodb.AttributeValue{
+		{"id": {S: aws.String("1")}, "name": {S: aws.String("a")}},
+		{"id": {S: aws.String("2")}, "email": {S: aws.String("b@example.com")}},
+		{"id": {S: aws.String("3")}, "email": {S: aws.String("c@example.com")}, "age": {N: aws.String("30")}},
+	} {
+		if err := w.Write(item); err != nil {
+			t.Fatalf("failed to write: %v", err)
+		}
+	}
+	if diff := cmp.Diff(map[string]int64{"email": 2, "age": 1}, w.Dropped()); diff != "" {
+		t.Error(diff)
+	}
+}
@@ -4,14 +4,19 @@ go 1.14
 
 require (
 	github.com/aws/aws-lambda-go v1.16.0
-	github.com/aws/aws-sdk-go v1.34.0
+	github.com/aws/aws-sdk-go v1.35.0
+	github.com/go-sql-driver/mysql v1.5.0
 	github.com/google/go-cmp v0.4.0
 	github.com/google/uuid v1.1.1
+	github.com/klauspost/compress v1.10.5
 	github.com/kr/text v0.2.0 // indirect
+	github.com/lib/pq v1.8.0
 	github.com/niemeyer/pretty v0.0.0-20200227124842-a10e7caefd8e // indirect
 	github.com/rakyll/statik v0.1.7
+	github.com/stretchr/testify v1.5.1 // indirect
+	github.com/xitongsys/parquet-go v1.5.4
 	go.uber.org/zap v1.15.0
-	golang.org/x/text v0.3.8 // indirect
+	golang.org/x/text v0.3.8
 	gopkg.in/check.v1 v1.0.0-20200227125254-8fa46927fb4f // indirect
-	gopkg.in/yaml.v2 v2.2.8 // indirect
+	gopkg.in/yaml.v2 v2.2.8
 )
@@ -0,0 +1,102 @@
+// Package history stores a summary of each import in a JSON Lines file, so that recurring
+// imports of the same dataset, e.g. nightly imports, can be compared to find regressions.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Run is the summary of an import.
+type Run struct {
+	ID      string    `json:"id"`
+	Started time.Time `json:"started"`
+	// Input is the name of the input, or a comma separated list of inputs.
+	Input string `json:"input"`
+	Table string `json:"table"`
+	// Records is the number of records written.
+	Records int64 `json:"records"`
+	// Errors is the number of rows which weren't imported because of errors, e.g. rows skipped
+	// because of invalid values.
+	Errors           int64         `json:"errors"`
+	Duration         time.Duration `json:"duration"`
+	RecordsPerSecond float64       `json:"rps"`
+}
+
+// Append the run to the history file, creating it if it doesn't exist.
+func Append(name string, r Run) (err error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	b, err := json.Marshal(r)
+	if err != nil {
+		return
+	}
+	_, err = f.Write(append(b, '\n'))
+	return
+}
+
+// Load the runs in the history file, oldest first.
+func Load(name string) (runs []Run, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for line := 1; s.Scan(); line++ {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		var r Run
+		if err = json.Unmarshal(s.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("history: line %d: %w", line, err)
+		}
+		runs = append(runs, r)
+	}
+	return runs, s.Err()
+}
+
+// Find the run with the ID. If more than one run has the ID, the latest is returned.
+func Find(runs []Run, id string) (r Run, ok bool) {
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].ID == id {
+			return runs[i], true
+		}
+	}
+	return
+}
+
+// Difference between a measurement of two runs.
+type Difference struct {
+	Name string
+	A, B float64
+}
+
+// Change returns the relative change from A to B, e.g. 0.1 for a 10% increase. It's zero if A
+// is zero.
+func (d Difference) Change() float64 {
+	if d.A == 0 {
+		return 0
+	}
+	return (d.B - d.A) / d.A
+}
+
+// Compare the measurements of two runs.
+func Compare(a, b Run) []Difference {
+	return []Difference{
+		{Name: "records", A: float64(a.Records), B: float64(b.Records)},
+		{Name: "errors", A: float64(a.Errors), B: float64(b.Errors)},
+		{Name: "durationSeconds", A: a.Duration.Seconds(), B: b.Duration.Seconds()},
+		{Name: "recordsPerSecond", A: a.RecordsPerSecond, B: b.RecordsPerSecond},
+	}
+}
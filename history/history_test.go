@@ -0,0 +1,69 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "history.jsonl")
+
+	started := time.Date(2021, time.March, 4, 2, 0, 0, 0, time.UTC)
+	a := Run{ID: "a", Started: started, Input: "orders.csv", Table: "orders", Records: 1000, Duration: 10 * time.Second, RecordsPerSecond: 100}
+	b := Run{ID: "b", Started: started.Add(24 * time.Hour), Input: "orders.csv", Table: "orders", Records: 1100, Errors: 5, Duration: 20 * time.Second, RecordsPerSecond: 55}
+	for _, r := range []Run{a, b} {
+		if err = Append(name, r); err != nil {
+			t.Fatalf("failed to append: %v", err)
+		}
+	}
+	runs, err := Load(name)
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+	if diff := cmp.Diff([]Run{a, b}, runs); diff != "" {
+		t.Error(diff)
+	}
+	if _, ok := Find(runs, "c"); ok {
+		t.Error("expected run c not to be found")
+	}
+	found, ok := Find(runs, "b")
+	if !ok {
+		t.Fatal("expected run b to be found")
+	}
+	expected := []Difference{
+		{Name: "records", A: 1000, B: 1100},
+		{Name: "errors", A: 0, B: 5},
+		{Name: "durationSeconds", A: 10, B: 20},
+		{Name: "recordsPerSecond", A: 100, B: 55},
+	}
+	differences := Compare(a, found)
+	if diff := cmp.Diff(expected, differences); diff != "" {
+		t.Error(diff)
+	}
+	if change := differences[2].Change(); change != 1 {
+		t.Errorf("expected the duration to double, got a change of %v", change)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "history.jsonl")
+	ioutil.WriteFile(name, []byte("{\"id\":\"a\"}\nnot json\n"), 0644)
+	if _, err = Load(name); err == nil {
+		t.Error("expected an error")
+	}
+}
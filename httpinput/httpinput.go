@@ -0,0 +1,160 @@
+// Package httpinput reads the body of an HTTP(S) URL, resuming from where it left off using
+// Range requests if the connection fails part way through, so that large published datasets
+// can be imported without staging them in S3.
+package httpinput
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultMaxRetries is the default number of times a failed body is resumed.
+const DefaultMaxRetries = 5
+
+// ErrNotResumable is returned by Read when the connection failed, and the server doesn't
+// support Range requests, or the content changed since it was first requested.
+var ErrNotResumable = errors.New("httpinput: download failed and cannot be resumed")
+
+// Open sends a GET request to the url, and returns a Reader of the response body.
+func Open(client *http.Client, url string) (r *Reader, err error) {
+	r = &Reader{
+		MaxRetries: DefaultMaxRetries,
+		client:     client,
+		url:        url,
+		sleep:      time.Sleep,
+	}
+	resp, err := r.get(http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	r.body = resp.Body
+	r.size = resp.ContentLength
+	r.etag = resp.Header.Get("ETag")
+	r.lastModified = resp.Header.Get("Last-Modified")
+	// The offset is counted in bytes of the body as sent, so it can't be resumed if the
+	// transport decompressed it.
+	r.resumable = resp.Header.Get("Accept-Ranges") == "bytes" && !resp.Uncompressed
+	return r, nil
+}
+
+// Reader of the body of a URL. If reading the body fails, it is requested again from the
+// offset that was reached.
+type Reader struct {
+	// MaxRetries is the number of times the body is requested again after a failure.
+	MaxRetries int
+	// OnResume is called before the body is requested again. It may be nil.
+	OnResume func(offset int64, err error)
+
+	client       *http.Client
+	url          string
+	body         io.ReadCloser
+	size         int64
+	offset       int64
+	etag         string
+	lastModified string
+	resumable    bool
+	retries      int
+	sleep        func(time.Duration)
+}
+
+// Size returns the Content-Length of the response, or -1 if it is unknown.
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+// Read from the body, resuming it if the connection fails.
+func (r *Reader) Read(p []byte) (n int, err error) {
+	for {
+		n, err = r.body.Read(p)
+		r.offset += int64(n)
+		if err == nil || err == io.EOF {
+			if err == io.EOF && r.size >= 0 && r.offset < r.size {
+				// The connection was closed before the whole body was sent.
+				err = io.ErrUnexpectedEOF
+			} else {
+				return n, err
+			}
+		}
+		if n > 0 {
+			// Return what was read, and resume on the next call.
+			return n, nil
+		}
+		if err = r.resume(err); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// resume requests the body again from the offset. Each failed request uses up a retry.
+func (r *Reader) resume(cause error) error {
+	r.body.Close()
+	for {
+		if !r.resumable || r.retries >= r.MaxRetries {
+			return fmt.Errorf("%w: %v", ErrNotResumable, cause)
+		}
+		r.retries++
+		if r.OnResume != nil {
+			r.OnResume(r.offset, cause)
+		}
+		r.sleep(time.Duration(r.retries) * time.Second)
+		resp, err := r.get(http.StatusPartialContent)
+		if err == nil {
+			r.body = resp.Body
+			return nil
+		}
+		var se statusError
+		if errors.As(err, &se) && se.code < http.StatusInternalServerError {
+			// The content changed, or the server stopped accepting Range requests.
+			return fmt.Errorf("%w: %v", ErrNotResumable, err)
+		}
+		cause = err
+	}
+}
+
+// statusError is returned by get when the response doesn't have the expected status code.
+type statusError struct {
+	code   int
+	status string
+	url    string
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("httpinput: unexpected status %q from %s", e.status, e.url)
+}
+
+// get the url from the offset, expecting the status code.
+func (r *Reader) get(expected int) (resp *http.Response, err error) {
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Ask for the body as it's stored, so that the offset counts the same bytes as a Range.
+	req.Header.Set("Accept-Encoding", "identity")
+	if r.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+		// If the content has changed, the server returns all of it with a 200 status code,
+		// instead of a 206.
+		if r.etag != "" {
+			req.Header.Set("If-Range", r.etag)
+		} else if r.lastModified != "" {
+			req.Header.Set("If-Range", r.lastModified)
+		}
+	}
+	resp, err = r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != expected {
+		resp.Body.Close()
+		return nil, statusError{code: resp.StatusCode, status: resp.Status, url: r.url}
+	}
+	return resp, nil
+}
+
+// Close the body.
+func (r *Reader) Close() error {
+	return r.body.Close()
+}
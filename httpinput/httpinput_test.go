@@ -0,0 +1,153 @@
+package httpinput
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const content = "id,name\n1,a\n2,b\n3,c\n4,d\n5,e\n"
+
+// failingServer sends the first limit bytes of the content, then closes the connection.
+func failingServer(t *testing.T, limit int, acceptRanges bool) (*httptest.Server, *[]string) {
+	var ranges []string
+	s := httptest.NewServer(failingHandler(t, limit, acceptRanges, &ranges))
+	return s, &ranges
+}
+
+func failingHandler(t *testing.T, limit int, acceptRanges bool, ranges *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*ranges = append(*ranges, r.Header.Get("Range"))
+		if r.Header.Get("Accept-Encoding") != "identity" {
+			t.Errorf("expected Accept-Encoding to be identity, got %q", r.Header.Get("Accept-Encoding"))
+		}
+		if acceptRanges {
+			w.Header().Set("Accept-Ranges", "bytes")
+		}
+		w.Header().Set("ETag", `"v1"`)
+		body, status := content, http.StatusOK
+		if rng := r.Header.Get("Range"); rng != "" {
+			from, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			if r.Header.Get("If-Range") != `"v1"` {
+				t.Errorf("expected If-Range to be set to the ETag, got %q", r.Header.Get("If-Range"))
+			}
+			body, status = content[from:], http.StatusPartialContent
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", from, len(content)-1, len(content)))
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(status)
+		if len(body) > limit {
+			w.Write([]byte(body[:limit]))
+			w.(http.Flusher).Flush()
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		w.Write([]byte(body))
+	}
+}
+
+func TestReaderResumes(t *testing.T) {
+	s, ranges := failingServer(t, 10, true)
+	defer s.Close()
+
+	r, err := Open(s.Client(), s.URL)
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	r.sleep = func(time.Duration) {}
+	var resumes int
+	r.OnResume = func(offset int64, err error) { resumes++ }
+	if r.Size() != int64(len(content)) {
+		t.Errorf("expected size %d, got %d", len(content), r.Size())
+	}
+	actual, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	if string(actual) != content {
+		t.Errorf("expected %q, got %q", content, string(actual))
+	}
+	if resumes != 2 {
+		t.Errorf("expected 2 resumes, got %d", resumes)
+	}
+	expected := []string{"", "bytes=10-", "bytes=20-"}
+	if strings.Join(*ranges, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected requests %v, got %v", expected, *ranges)
+	}
+}
+
+func TestReaderRetriesFailedResume(t *testing.T) {
+	var ranges []string
+	handler := failingHandler(t, 10, true, &ranges)
+	var requests int
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch requests {
+		case 2:
+			// Drop the connection without a response.
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		case 3:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			handler(w, r)
+		}
+	}))
+	defer s.Close()
+
+	r, err := Open(s.Client(), s.URL)
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	r.sleep = func(time.Duration) {}
+	var resumes int
+	r.OnResume = func(offset int64, err error) { resumes++ }
+	actual, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("failed to read: %v", err)
+	}
+	if string(actual) != content {
+		t.Errorf("expected %q, got %q", content, string(actual))
+	}
+	if resumes != 4 {
+		t.Errorf("expected 4 resumes, got %d", resumes)
+	}
+
+	r, err = Open(s.Client(), s.URL)
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	r.sleep = func(time.Duration) {}
+	requests, r.MaxRetries = 1, 2
+	if _, err = ioutil.ReadAll(r); !errors.Is(err, ErrNotResumable) {
+		t.Errorf("expected ErrNotResumable once the retries are used up, got %v", err)
+	}
+}
+
+func TestReaderNotResumable(t *testing.T) {
+	s, _ := failingServer(t, 10, false)
+	defer s.Close()
+
+	r, err := Open(s.Client(), s.URL)
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	if _, err = ioutil.ReadAll(r); !errors.Is(err, ErrNotResumable) {
+		t.Errorf("expected ErrNotResumable, got %v", err)
+	}
+}
+
+func TestOpenUnexpectedStatus(t *testing.T) {
+	s := httptest.NewServer(http.NotFoundHandler())
+	defer s.Close()
+	if _, err := Open(s.Client(), s.URL); err == nil {
+		t.Error("expected an error for a 404")
+	}
+}
@@ -0,0 +1,292 @@
+// Package testsupport runs DynamoDB Local for integration tests of batch writes, retries and
+// end-to-end imports.
+//
+// Integration tests are skipped unless the DYNAMODB_LOCAL environment variable is set to one
+// of:
+//
+//	docker: run the amazon/dynamodb-local image.
+//	download: download DynamoDB Local to the user's cache directory and run it with java.
+//	the URL of a running DynamoDB Local, e.g. http://localhost:8000.
+package testsupport
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// EnvVar is the environment variable which enables integration tests.
+const EnvVar = "DYNAMODB_LOCAL"
+
+// Image is the Docker image used when EnvVar is 'docker'.
+const Image = "amazon/dynamodb-local"
+
+// DownloadURL is the DynamoDB Local distribution used when EnvVar is 'download'.
+const DownloadURL = "https://d1ni2b6xgvw0s0.cloudfront.net/v2.x/dynamodb_local_latest.tar.gz"
+
+// Region is the region used for DynamoDB Local sessions. DynamoDB Local accepts any region.
+const Region = "eu-west-2"
+
+// DynamoDBLocal is a running DynamoDB Local.
+type DynamoDBLocal struct {
+	// Endpoint is the URL of DynamoDB Local, e.g. http://localhost:8000, which can be passed to
+	// ddbimport's tableEndpoint flag.
+	Endpoint string
+}
+
+// Start DynamoDB Local, or skip the test if EnvVar isn't set. DynamoDB Local is stopped when the
+// test completes.
+func Start(t testing.TB) *DynamoDBLocal {
+	t.Helper()
+	mode := os.Getenv(EnvVar)
+	var d *DynamoDBLocal
+	switch {
+	case mode == "":
+		t.Skipf("set %s to 'docker', 'download' or the URL of DynamoDB Local to run integration tests", EnvVar)
+	case strings.HasPrefix(mode, "http://") || strings.HasPrefix(mode, "https://"):
+		d = &DynamoDBLocal{Endpoint: mode}
+	case mode == "docker":
+		d = startDocker(t)
+	case mode == "download":
+		d = startJava(t)
+	default:
+		t.Fatalf("invalid %s %q, use 'docker', 'download' or a URL", EnvVar, mode)
+	}
+	d.waitUntilReady(t)
+	return d
+}
+
+func startDocker(t testing.TB) *DynamoDBLocal {
+	port := freePort(t)
+	out, err := exec.Command("docker", "run", "--rm", "-d", "-p", fmt.Sprintf("127.0.0.1:%d:8000", port), Image, "-jar", "DynamoDBLocal.jar", "-inMemory").Output()
+	if err != nil {
+		t.Fatalf("failed to start DynamoDB Local container: %v", err)
+	}
+	id := strings.TrimSpace(string(out))
+	t.Cleanup(func() {
+		exec.Command("docker", "stop", id).Run()
+	})
+	return &DynamoDBLocal{Endpoint: fmt.Sprintf("http://127.0.0.1:%d", port)}
+}
+
+func startJava(t testing.TB) *DynamoDBLocal {
+	dir := download(t)
+	port := freePort(t)
+	cmd := exec.Command("java", "-Djava.library.path="+filepath.Join(dir, "DynamoDBLocal_lib"), "-jar", filepath.Join(dir, "DynamoDBLocal.jar"), "-inMemory", "-port", fmt.Sprint(port))
+	cmd.Dir = dir
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start DynamoDB Local: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	return &DynamoDBLocal{Endpoint: fmt.Sprintf("http://127.0.0.1:%d", port)}
+}
+
+// download DynamoDB Local to the user's cache directory, if it hasn't already been downloaded,
+// and return the directory.
+func download(t testing.TB) string {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		t.Fatalf("failed to find cache directory: %v", err)
+	}
+	dir := filepath.Join(cache, "ddbimport", "dynamodb-local")
+	if _, err = os.Stat(filepath.Join(dir, "DynamoDBLocal.jar")); err == nil {
+		return dir
+	}
+	resp, err := http.Get(DownloadURL)
+	if err != nil {
+		t.Fatalf("failed to download DynamoDB Local: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("failed to download DynamoDB Local: %s", resp.Status)
+	}
+	// Extract to a temporary directory first, so that an interrupted download isn't used.
+	tmp := dir + ".tmp"
+	os.RemoveAll(tmp)
+	if err = extract(resp.Body, tmp); err != nil {
+		t.Fatalf("failed to extract DynamoDB Local: %v", err)
+	}
+	if err = os.Rename(tmp, dir); err != nil {
+		t.Fatalf("failed to extract DynamoDB Local: %v", err)
+	}
+	return dir
+}
+
+func extract(r io.Reader, dir string) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gr)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Join(dir, filepath.Clean("/"+h.Name))
+		if h.Typeflag == tar.TypeDir {
+			if err = os.MkdirAll(name, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err = os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(h.Mode))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, tr)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func freePort(t testing.TB) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free port: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func (d *DynamoDBLocal) waitUntilReady(t testing.TB) {
+	deadline := time.Now().Add(time.Minute)
+	for {
+		_, err := d.Client().ListTables(&dynamodb.ListTablesInput{})
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("DynamoDB Local at %s didn't start: %v", d.Endpoint, err)
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+}
+
+// Session returns a session which connects to DynamoDB Local using static credentials.
+func (d *DynamoDBLocal) Session() *session.Session {
+	return session.Must(session.NewSession(&aws.Config{
+		Region:      aws.String(Region),
+		Endpoint:    aws.String(d.Endpoint),
+		Credentials: credentials.NewStaticCredentials("local", "local", ""),
+	}))
+}
+
+// Client returns a DynamoDB client for DynamoDB Local.
+func (d *DynamoDBLocal) Client() *dynamodb.DynamoDB {
+	return dynamodb.New(d.Session())
+}
+
+// Env returns the environment for running ddbimport against DynamoDB Local, with static
+// credentials, so that the credentials of the user running the tests aren't used.
+func (d *DynamoDBLocal) Env() []string {
+	return append(os.Environ(),
+		"AWS_ACCESS_KEY_ID=local",
+		"AWS_SECRET_ACCESS_KEY=local",
+		"AWS_SESSION_TOKEN=",
+		"AWS_PROFILE=",
+		"AWS_REGION="+Region)
+}
+
+var tables int64
+
+var invalidTableNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// CreateTable creates an on-demand table with string key attributes, the first of which is
+// the partition key, and the second, if present, the sort key. The table is deleted when the
+// test completes.
+func (d *DynamoDBLocal) CreateTable(t testing.TB, keys ...string) (tableName string) {
+	t.Helper()
+	tableName = fmt.Sprintf("%s-%d", invalidTableNameChars.ReplaceAllString(t.Name(), "-"), atomic.AddInt64(&tables, 1))
+	input := &dynamodb.CreateTableInput{
+		TableName:   aws.String(tableName),
+		BillingMode: aws.String(dynamodb.BillingModePayPerRequest),
+	}
+	for i, k := range keys {
+		keyType := dynamodb.KeyTypeHash
+		if i > 0 {
+			keyType = dynamodb.KeyTypeRange
+		}
+		input.AttributeDefinitions = append(input.AttributeDefinitions, &dynamodb.AttributeDefinition{
+			AttributeName: aws.String(k),
+			AttributeType: aws.String(dynamodb.ScalarAttributeTypeS),
+		})
+		input.KeySchema = append(input.KeySchema, &dynamodb.KeySchemaElement{
+			AttributeName: aws.String(k),
+			KeyType:       aws.String(keyType),
+		})
+	}
+	client := d.Client()
+	if _, err := client.CreateTable(input); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	t.Cleanup(func() {
+		client.DeleteTable(&dynamodb.DeleteTableInput{TableName: aws.String(tableName)})
+	})
+	if err := client.WaitUntilTableExists(&dynamodb.DescribeTableInput{TableName: aws.String(tableName)}); err != nil {
+		t.Fatalf("failed to wait for table: %v", err)
+	}
+	return tableName
+}
+
+// Items returns all of the items in the table.
+func (d *DynamoDBLocal) Items(t testing.TB, tableName string) (items []map[string]*dynamodb.AttributeValue) {
+	t.Helper()
+	err := d.Client().ScanPages(&dynamodb.ScanInput{TableName: aws.String(tableName)}, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("failed to scan table: %v", err)
+	}
+	return items
+}
+
+// Throttle makes the first n requests sent by clients of the session fail with a
+// ProvisionedThroughputExceededException, which DynamoDB Local never returns, to test retries.
+func Throttle(sess *session.Session, n int) {
+	var sent int64
+	sess.Handlers.Send.PushFront(func(r *request.Request) {
+		if atomic.AddInt64(&sent, 1) > int64(n) {
+			return
+		}
+		r.Error = awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "throttled by testsupport", nil)
+		r.HTTPResponse = &http.Response{StatusCode: http.StatusBadRequest, Header: http.Header{}, Body: http.NoBody}
+		// Stop the request from being sent.
+		r.Handlers.Send.AfterEachFn = request.HandlerListStopOnError
+	})
+}
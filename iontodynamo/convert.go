@@ -0,0 +1,101 @@
+// Package iontodynamo converts the Amazon Ion text written by DynamoDB's export to S3 feature
+// to DynamoDB records.
+package iontodynamo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/a-h/ddbimport/csvtodynamo"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// ErrNoItem is the cause of an ErrRowConversion when a top level value is not a struct with an
+// Item.
+var ErrNoItem = errors.New("iontodynamo: value has no Item")
+
+// ErrBinary is returned when the input is binary Ion, which DynamoDB doesn't export.
+var ErrBinary = errors.New("iontodynamo: binary Ion is not supported, use text Ion")
+
+// binaryVersionMarker starts every binary Ion stream.
+var binaryVersionMarker = []byte{0xe0, 0x01, 0x00, 0xea}
+
+// Converter converts Ion text to DynamoDB records. Each top level value must be a struct of
+// the form {Item:{id:"a",count:1.}}, as exported by DynamoDB.
+type Converter struct {
+	p *parser
+}
+
+// NewConverter creates a Converter which reads from r.
+func NewConverter(r io.Reader) (*Converter, error) {
+	br := bufio.NewReader(r)
+	start, err := br.Peek(len(binaryVersionMarker))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if bytes.Equal(start, binaryVersionMarker) {
+		return nil, ErrBinary
+	}
+	return &Converter{p: newParser(br)}, nil
+}
+
+// ReadBatch reads 25 items from the input.
+func (c *Converter) ReadBatch() (items []map[string]*dynamodb.AttributeValue, read int, err error) {
+	batchSize := 25
+	items = make([]map[string]*dynamodb.AttributeValue, batchSize)
+	for read = 0; read < batchSize; read++ {
+		items[read], err = c.Read()
+		if err != nil {
+			break
+		}
+	}
+	return items[:read], read, err
+}
+
+// Read a single item from the input. io.EOF is returned when there are no more values. The
+// Line of any ErrRowConversion is the line that the value starts on.
+func (c *Converter) Read() (item map[string]*dynamodb.AttributeValue, err error) {
+	for {
+		if err = c.p.skipSpace(); err != nil {
+			if err == io.EOF {
+				return nil, err
+			}
+			return nil, &csvtodynamo.ErrRowConversion{Line: c.p.line, Cause: err}
+		}
+		line := c.p.line
+		v, err := c.p.value()
+		if err != nil {
+			return nil, &csvtodynamo.ErrRowConversion{Line: line, Cause: err}
+		}
+		if v.isSystem() {
+			continue
+		}
+		if v.av.M == nil || v.av.M["Item"] == nil || v.av.M["Item"].M == nil {
+			return nil, &csvtodynamo.ErrRowConversion{Line: line, Cause: ErrNoItem}
+		}
+		item = v.av.M["Item"].M
+		for k, av := range item {
+			if err = csvtodynamo.CheckDepth(av); err != nil {
+				return nil, &csvtodynamo.ErrRowConversion{Line: line, Column: k, Cause: err}
+			}
+		}
+		return item, nil
+	}
+}
+
+// value is a parsed Ion value and its annotations.
+type value struct {
+	av          *dynamodb.AttributeValue
+	annotations []string
+	symbol      string
+}
+
+// isSystem returns true for the Ion version marker and symbol tables, which are skipped.
+func (v value) isSystem() bool {
+	if v.symbol == "$ion_1_0" && len(v.annotations) == 0 {
+		return true
+	}
+	return len(v.annotations) > 0 && v.annotations[0] == "$ion_symbol_table"
+}
@@ -0,0 +1,117 @@
+package iontodynamo
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/a-h/ddbimport/csvtodynamo"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConverter(t *testing.T) {
+	input := `$ion_1_0 {Item:{id:"a",count:1.,price:12.50,big:1.5d3,ratio:2.5e0,hex:0x1F,data:{{AQI=}},ok:true,none:null,typed:null.string,tags:$dynamodb_SS::["x","y"],scores:$dynamodb_NS::[1.,2.5],blobs:$dynamodb_BS::[{{AQ==}}],address:{city:"Leeds",'post code':"LS1"},l:[1,"b",[]]}}
+// A comment.
+$ion_1_0 {Item:{id:"bé\n",created:2020-01-02T03:04:05Z,long:'''a''' '''b''', "quoted field":sym}}
+`
+	c, err := NewConverter(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	items, read, err := c.ReadBatch()
+	if err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if read != 2 {
+		t.Errorf("expected 2 reads, read %d", read)
+	}
+	expected := []map[string]*dynamodb.AttributeValue{
+		{
+			"id":     {S: aws.String("a")},
+			"count":  {N: aws.String("1")},
+			"price":  {N: aws.String("12.50")},
+			"big":    {N: aws.String("1.5e3")},
+			"ratio":  {N: aws.String("2.5")},
+			"hex":    {N: aws.String("31")},
+			"data":   {B: []byte{1, 2}},
+			"ok":     {BOOL: aws.Bool(true)},
+			"none":   {NULL: aws.Bool(true)},
+			"typed":  {NULL: aws.Bool(true)},
+			"tags":   {SS: aws.StringSlice([]string{"x", "y"})},
+			"scores": {NS: aws.StringSlice([]string{"1", "2.5"})},
+			"blobs":  {BS: [][]byte{{1}}},
+			"address": {M: map[string]*dynamodb.AttributeValue{
+				"city":      {S: aws.String("Leeds")},
+				"post code": {S: aws.String("LS1")},
+			}},
+			"l": {L: []*dynamodb.AttributeValue{{N: aws.String("1")}, {S: aws.String("b")}, {L: []*dynamodb.AttributeValue{}}}},
+		},
+		{
+			"id":           {S: aws.String("bé\n")},
+			"created":      {S: aws.String("2020-01-02T03:04:05Z")},
+			"long":         {S: aws.String("ab")},
+			"quoted field": {S: aws.String("sym")},
+		},
+	}
+	if diff := cmp.Diff(expected, items); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestConverterErrors(t *testing.T) {
+	var tests = []struct {
+		name          string
+		input         string
+		expectedLine  int64
+		expectedCause error
+	}{
+		{
+			name:         "syntax errors include the line",
+			input:        "{Item:{id:\"a\"}}\n\n{Item:{id:\"b\" count:1}}",
+			expectedLine: 3,
+		},
+		{
+			name:         "unterminated values are errors",
+			input:        "{Item:{id:\"a\"}}\n{Item:{id:\"b\"",
+			expectedLine: 2,
+		},
+		{
+			name:          "values must have an Item",
+			input:         "{Item:{id:\"a\"}}\n{id:\"b\"}",
+			expectedLine:  2,
+			expectedCause: ErrNoItem,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewConverter(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("failed to create converter: %v", err)
+			}
+			_, read, err := c.ReadBatch()
+			if read != 1 {
+				t.Errorf("expected 1 read, read %d", read)
+			}
+			var rce *csvtodynamo.ErrRowConversion
+			if !errors.As(err, &rce) {
+				t.Fatalf("expected ErrRowConversion, got %v", err)
+			}
+			if rce.Line != tt.expectedLine {
+				t.Errorf("expected line %d, got %d", tt.expectedLine, rce.Line)
+			}
+			if tt.expectedCause != nil && !errors.Is(err, tt.expectedCause) {
+				t.Errorf("expected %v, got %v", tt.expectedCause, err)
+			}
+		})
+	}
+}
+
+func TestConverterBinary(t *testing.T) {
+	if _, err := NewConverter(strings.NewReader("\xe0\x01\x00\xea")); err != ErrBinary {
+		t.Errorf("expected ErrBinary, got %v", err)
+	}
+}
@@ -0,0 +1,491 @@
+package iontodynamo
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// parser reads the subset of Ion text used by DynamoDB exports: structs, lists, strings,
+// symbols, numbers, booleans, nulls, blobs and annotations. Timestamps are stored as strings.
+type parser struct {
+	r    *bufio.Reader
+	line int64
+	last rune
+}
+
+func newParser(r *bufio.Reader) *parser {
+	return &parser{r: r, line: 1}
+}
+
+func (p *parser) read() (rune, error) {
+	c, _, err := p.r.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if c == '\n' {
+		p.line++
+	}
+	p.last = c
+	return c, nil
+}
+
+func (p *parser) unread() {
+	if p.last == '\n' {
+		p.line--
+	}
+	p.r.UnreadRune()
+}
+
+// peek returns the next n bytes without consuming them. Fewer bytes are returned at the end of
+// the input.
+func (p *parser) peek(n int) string {
+	b, _ := p.r.Peek(n)
+	return string(b)
+}
+
+func (p *parser) skip(n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := p.read(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// expect reads the next rune, and returns an error if it isn't c.
+func (p *parser) expect(c rune) error {
+	got, err := p.read()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if got != c {
+		return fmt.Errorf("iontodynamo: expected %q, got %q", c, got)
+	}
+	return nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// skipSpace skips whitespace and comments. io.EOF is returned at the end of the input.
+func (p *parser) skipSpace() error {
+	for {
+		c, err := p.read()
+		if err != nil {
+			return err
+		}
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\v' || c == '\f':
+			continue
+		case c == '/' && p.peek(1) == "/":
+			for c != '\n' {
+				if c, err = p.read(); err != nil {
+					return err
+				}
+			}
+			continue
+		case c == '/' && p.peek(1) == "*":
+			p.skip(1)
+			for p.peek(2) != "*/" {
+				if _, err = p.read(); err != nil {
+					return unexpectedEOF(err)
+				}
+			}
+			p.skip(2)
+			continue
+		}
+		p.unread()
+		return nil
+	}
+}
+
+// skipValueSpace skips whitespace and comments within a value, where the end of the input is
+// unexpected.
+func (p *parser) skipValueSpace() error {
+	return unexpectedEOF(p.skipSpace())
+}
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentifierPart(c byte) bool {
+	return isIdentifierStart(c) || (c >= '0' && c <= '9')
+}
+
+// value reads a value and its annotations.
+func (p *parser) value() (v value, err error) {
+	for {
+		if err = p.skipValueSpace(); err != nil {
+			return
+		}
+		next := p.peek(3)
+		if next == "" {
+			return v, io.ErrUnexpectedEOF
+		}
+		if !isIdentifierStart(next[0]) && !(next[0] == '\'' && next != "'''") {
+			break
+		}
+		var symbol string
+		if symbol, err = p.symbol(); err != nil {
+			return
+		}
+		if err = p.skipSpace(); err != nil && err != io.EOF {
+			return
+		}
+		if p.peek(2) == "::" {
+			p.skip(2)
+			v.annotations = append(v.annotations, symbol)
+			continue
+		}
+		v.symbol = symbol
+		v.av, err = p.keyword(symbol)
+		return
+	}
+	next := p.peek(2)
+	switch {
+	case next == "{{":
+		v.av, err = p.lob()
+	case next[0] == '{':
+		v.av, err = p.structure()
+	case next[0] == '[':
+		v.av, err = p.list()
+		if err == nil && len(v.annotations) > 0 {
+			v.av, err = set(v.annotations[0], v.av)
+		}
+	case next[0] == '(':
+		err = fmt.Errorf("iontodynamo: s-expressions are not supported")
+	case next[0] == '"' || next[0] == '\'':
+		var s string
+		if s, err = p.text(); err == nil {
+			v.av = (&dynamodb.AttributeValue{}).SetS(s)
+		}
+	case next[0] == '-' || next[0] == '+' || (next[0] >= '0' && next[0] <= '9'):
+		v.av, err = p.number()
+	default:
+		err = fmt.Errorf("iontodynamo: unexpected %q", next[0])
+	}
+	return
+}
+
+// symbol reads an identifier or a quoted symbol.
+func (p *parser) symbol() (string, error) {
+	if p.peek(1) == "'" {
+		return p.text()
+	}
+	var sb strings.Builder
+	for {
+		next := p.peek(1)
+		if next == "" || !isIdentifierPart(next[0]) {
+			return sb.String(), nil
+		}
+		p.skip(1)
+		sb.WriteByte(next[0])
+	}
+}
+
+// keyword converts a symbol value. Symbols other than the keywords are stored as strings.
+func (p *parser) keyword(symbol string) (*dynamodb.AttributeValue, error) {
+	switch symbol {
+	case "true", "false":
+		return (&dynamodb.AttributeValue{}).SetBOOL(symbol == "true"), nil
+	case "nan":
+		return (&dynamodb.AttributeValue{}).SetS("NaN"), nil
+	case "null":
+		// Typed nulls, e.g. null.string, are stored as NULL.
+		if p.peek(1) == "." {
+			p.skip(1)
+			if _, err := p.symbol(); err != nil {
+				return nil, err
+			}
+		}
+		return (&dynamodb.AttributeValue{}).SetNULL(true), nil
+	}
+	return (&dynamodb.AttributeValue{}).SetS(symbol), nil
+}
+
+// text reads a quoted string or symbol. Adjacent long strings are concatenated.
+func (p *parser) text() (string, error) {
+	if p.peek(3) != "'''" {
+		quote, _ := p.read()
+		return p.quoted(quote, false)
+	}
+	var sb strings.Builder
+	for {
+		p.skip(3)
+		s, err := p.quoted('\'', true)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(s)
+		if err = p.skipSpace(); err != nil && err != io.EOF {
+			return "", err
+		}
+		if p.peek(3) != "'''" {
+			return sb.String(), nil
+		}
+	}
+}
+
+// quoted reads the rest of a string ending with the quote, or three quotes if long is set.
+func (p *parser) quoted(quote rune, long bool) (string, error) {
+	var sb strings.Builder
+	for {
+		c, err := p.read()
+		if err != nil {
+			return "", unexpectedEOF(err)
+		}
+		if c == quote && (!long || p.peek(2) == "''") {
+			if long {
+				p.skip(2)
+			}
+			return sb.String(), nil
+		}
+		if c != '\\' {
+			sb.WriteRune(c)
+			continue
+		}
+		if c, err = p.read(); err != nil {
+			return "", unexpectedEOF(err)
+		}
+		switch c {
+		case '\n':
+			// Escaped newlines continue the string on the next line.
+		case 'a':
+			sb.WriteByte('\a')
+		case 'b':
+			sb.WriteByte('\b')
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'v':
+			sb.WriteByte('\v')
+		case '0':
+			sb.WriteByte(0)
+		case 'x', 'u', 'U':
+			digits := map[rune]int{'x': 2, 'u': 4, 'U': 8}[c]
+			hex := p.peek(digits)
+			r, err := strconv.ParseUint(hex, 16, 32)
+			if err != nil || len(hex) != digits || !utf8.ValidRune(rune(r)) {
+				return "", fmt.Errorf("iontodynamo: invalid escape \\%c%s", c, hex)
+			}
+			p.skip(digits)
+			sb.WriteRune(rune(r))
+		default:
+			// \" \' \\ \/ and \? are the character itself.
+			sb.WriteRune(c)
+		}
+	}
+}
+
+// structure reads a struct as a map.
+func (p *parser) structure() (*dynamodb.AttributeValue, error) {
+	p.skip(1)
+	m := make(map[string]*dynamodb.AttributeValue)
+	for {
+		if err := p.skipValueSpace(); err != nil {
+			return nil, err
+		}
+		if p.peek(1) == "}" {
+			p.skip(1)
+			return (&dynamodb.AttributeValue{}).SetM(m), nil
+		}
+		var name string
+		var err error
+		if next := p.peek(1); next == "\"" {
+			name, err = p.text()
+		} else if next != "" && (next[0] == '\'' || isIdentifierStart(next[0])) {
+			name, err = p.symbol()
+		} else {
+			err = fmt.Errorf("iontodynamo: expected field name, got %q", next)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = p.skipValueSpace(); err != nil {
+			return nil, err
+		}
+		if err = p.expect(':'); err != nil {
+			return nil, err
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		m[name] = v.av
+		if err = p.separator('}'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// list reads a list.
+func (p *parser) list() (*dynamodb.AttributeValue, error) {
+	p.skip(1)
+	l := []*dynamodb.AttributeValue{}
+	for {
+		if err := p.skipValueSpace(); err != nil {
+			return nil, err
+		}
+		if p.peek(1) == "]" {
+			p.skip(1)
+			return (&dynamodb.AttributeValue{}).SetL(l), nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, fmt.Errorf("[%d]: %w", len(l), err)
+		}
+		l = append(l, v.av)
+		if err = p.separator(']'); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// separator consumes the comma between elements, but leaves the closing character for the
+// caller.
+func (p *parser) separator(end rune) error {
+	if err := p.skipValueSpace(); err != nil {
+		return err
+	}
+	if p.peek(1) == string(end) {
+		return nil
+	}
+	return p.expect(',')
+}
+
+// set converts a list annotated with the $dynamodb_SS, $dynamodb_NS or $dynamodb_BS
+// annotations that DynamoDB uses for sets.
+func set(annotation string, av *dynamodb.AttributeValue) (*dynamodb.AttributeValue, error) {
+	switch annotation {
+	case "$dynamodb_SS":
+		ss := make([]*string, len(av.L))
+		for i, e := range av.L {
+			if e.S == nil {
+				return nil, fmt.Errorf("iontodynamo: string set contains a value that isn't a string")
+			}
+			ss[i] = e.S
+		}
+		return (&dynamodb.AttributeValue{}).SetSS(ss), nil
+	case "$dynamodb_NS":
+		ns := make([]*string, len(av.L))
+		for i, e := range av.L {
+			if e.N == nil {
+				return nil, fmt.Errorf("iontodynamo: number set contains a value that isn't a number")
+			}
+			ns[i] = e.N
+		}
+		return (&dynamodb.AttributeValue{}).SetNS(ns), nil
+	case "$dynamodb_BS":
+		bs := make([][]byte, len(av.L))
+		for i, e := range av.L {
+			if e.B == nil {
+				return nil, fmt.Errorf("iontodynamo: binary set contains a value that isn't binary")
+			}
+			bs[i] = e.B
+		}
+		return (&dynamodb.AttributeValue{}).SetBS(bs), nil
+	}
+	return av, nil
+}
+
+// lob reads a blob, or a clob, as binary.
+func (p *parser) lob() (*dynamodb.AttributeValue, error) {
+	p.skip(2)
+	if err := p.skipValueSpace(); err != nil {
+		return nil, err
+	}
+	var b []byte
+	if next := p.peek(1); next == "\"" || next == "'" {
+		s, err := p.text()
+		if err != nil {
+			return nil, err
+		}
+		b = []byte(s)
+	} else {
+		var sb strings.Builder
+		for p.peek(1) != "}" {
+			c, err := p.read()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			if c != ' ' && c != '\t' && c != '\r' && c != '\n' {
+				sb.WriteRune(c)
+			}
+		}
+		var err error
+		if b, err = base64.StdEncoding.DecodeString(sb.String()); err != nil {
+			return nil, fmt.Errorf("iontodynamo: invalid blob: %w", err)
+		}
+	}
+	if err := p.skipValueSpace(); err != nil {
+		return nil, err
+	}
+	if p.peek(2) != "}}" {
+		return nil, fmt.Errorf("iontodynamo: expected \"}}\"")
+	}
+	p.skip(2)
+	return (&dynamodb.AttributeValue{}).SetB(b), nil
+}
+
+// number reads an int, decimal, float or timestamp. Ints and decimals are stored as numbers.
+// Floats are stored as numbers, except for infinity, which DynamoDB can't store as a number.
+// Timestamps are stored as strings.
+func (p *parser) number() (*dynamodb.AttributeValue, error) {
+	var sb strings.Builder
+	for {
+		next := p.peek(1)
+		if next == "" || strings.ContainsAny(next, " \t\r\n\v\f,]})/") {
+			break
+		}
+		p.skip(1)
+		sb.WriteString(next)
+	}
+	s := sb.String()
+	lower := strings.ToLower(strings.TrimLeft(s, "+-"))
+	switch {
+	case s == "+inf" || s == "-inf":
+		return (&dynamodb.AttributeValue{}).SetS(strings.Replace(s, "inf", "Inf", 1)), nil
+	case strings.HasPrefix(lower, "0x") || strings.HasPrefix(lower, "0b"):
+		i, ok := new(big.Int).SetString(s, 0)
+		if !ok {
+			return nil, fmt.Errorf("iontodynamo: invalid int %q", s)
+		}
+		return (&dynamodb.AttributeValue{}).SetN(i.String()), nil
+	case strings.ContainsAny(s, "T:") || strings.Count(s, "-") >= 2 && !strings.ContainsAny(lower, "de"):
+		return (&dynamodb.AttributeValue{}).SetS(s), nil
+	}
+	n := strings.ReplaceAll(s, "_", "")
+	if strings.ContainsAny(n, "dD") {
+		n = strings.NewReplacer("d", "e", "D", "e").Replace(n)
+	}
+	n = strings.Replace(n, ".e", "e", 1)
+	n = strings.TrimSuffix(n, ".")
+	f, err := strconv.ParseFloat(n, 64)
+	if err != nil {
+		if ne, ok := err.(*strconv.NumError); !ok || ne.Err != strconv.ErrRange {
+			return nil, fmt.Errorf("iontodynamo: invalid number %q", s)
+		}
+	} else if strings.ContainsAny(n, "eE") && !strings.ContainsAny(s, "dD") {
+		// Floats are stored with the shortest representation.
+		n = strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return (&dynamodb.AttributeValue{}).SetN(n), nil
+}
@@ -0,0 +1,354 @@
+package iontodynamo
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseValue(t *testing.T) {
+	var tests = []struct {
+		name          string
+		input         string
+		expected      *dynamodb.AttributeValue
+		expectedError bool
+	}{
+		// Strings and symbols.
+		{
+			name:     "strings",
+			input:    `"abc"`,
+			expected: &dynamodb.AttributeValue{S: aws.String("abc")},
+		},
+		{
+			name:     "symbols are strings",
+			input:    `sym`,
+			expected: &dynamodb.AttributeValue{S: aws.String("sym")},
+		},
+		{
+			name:     "quoted symbols are strings",
+			input:    `'a symbol'`,
+			expected: &dynamodb.AttributeValue{S: aws.String("a symbol")},
+		},
+		{
+			name:     "long strings",
+			input:    `'''it's'''`,
+			expected: &dynamodb.AttributeValue{S: aws.String("it's")},
+		},
+		{
+			name:     "long strings can contain two quotes",
+			input:    `'''a''b'''`,
+			expected: &dynamodb.AttributeValue{S: aws.String("a''b")},
+		},
+		{
+			name:     "adjacent long strings are concatenated",
+			input:    `'''a''' '''b'''` + "\n" + `'''c'''`,
+			expected: &dynamodb.AttributeValue{S: aws.String("abc")},
+		},
+		{
+			name:     "long strings are concatenated across comments",
+			input:    `'''a''' /* comment */ '''b''' // comment` + "\n" + `'''c'''`,
+			expected: &dynamodb.AttributeValue{S: aws.String("abc")},
+		},
+		{
+			name:     "escaped newlines continue long strings",
+			input:    "'''a\\\nb'''",
+			expected: &dynamodb.AttributeValue{S: aws.String("ab")},
+		},
+		{
+			name:     "character escapes",
+			input:    `"\t\n\"\'\\\/\?\0"`,
+			expected: &dynamodb.AttributeValue{S: aws.String("\t\n\"'\\/?\x00")},
+		},
+		{
+			name:     "hex escapes",
+			input:    `"\x41\x7e"`,
+			expected: &dynamodb.AttributeValue{S: aws.String("A~")},
+		},
+		{
+			name:     "unicode escapes",
+			input:    `"\u00e9\U0001F600"`,
+			expected: &dynamodb.AttributeValue{S: aws.String("é😀")},
+		},
+		{
+			name:          "short hex escapes are errors",
+			input:         `"\x4"`,
+			expectedError: true,
+		},
+		{
+			name:          "short unicode escapes are errors",
+			input:         `"\u00e"`,
+			expectedError: true,
+		},
+		{
+			name:          "invalid hex digits are errors",
+			input:         `"\xzz"`,
+			expectedError: true,
+		},
+		{
+			name:          "surrogate escapes are errors",
+			input:         `"\uD800"`,
+			expectedError: true,
+		},
+		{
+			name:          "code points above the unicode range are errors",
+			input:         `"\U00110000"`,
+			expectedError: true,
+		},
+		{
+			name:          "unterminated strings are errors",
+			input:         `"abc`,
+			expectedError: true,
+		},
+		// Nulls and booleans.
+		{
+			name:     "null",
+			input:    `null`,
+			expected: &dynamodb.AttributeValue{NULL: aws.Bool(true)},
+		},
+		{
+			name:     "typed null structs are null",
+			input:    `null.struct`,
+			expected: &dynamodb.AttributeValue{NULL: aws.Bool(true)},
+		},
+		{
+			name:     "typed null strings are null",
+			input:    `null.string`,
+			expected: &dynamodb.AttributeValue{NULL: aws.Bool(true)},
+		},
+		{
+			name:     "null.null is null",
+			input:    `null.null`,
+			expected: &dynamodb.AttributeValue{NULL: aws.Bool(true)},
+		},
+		{
+			name:     "booleans",
+			input:    `false`,
+			expected: &dynamodb.AttributeValue{BOOL: aws.Bool(false)},
+		},
+		// Blobs and clobs.
+		{
+			name:     "blobs",
+			input:    `{{AQI=}}`,
+			expected: &dynamodb.AttributeValue{B: []byte{1, 2}},
+		},
+		{
+			name:     "blobs can contain whitespace",
+			input:    "{{ AQ\n I= }}",
+			expected: &dynamodb.AttributeValue{B: []byte{1, 2}},
+		},
+		{
+			name:          "invalid blobs are errors",
+			input:         `{{!!}}`,
+			expectedError: true,
+		},
+		{
+			name:          "unterminated blobs are errors",
+			input:         `{{AQI=`,
+			expectedError: true,
+		},
+		{
+			name:     "clobs are binary",
+			input:    `{{"a\x01"}}`,
+			expected: &dynamodb.AttributeValue{B: []byte("a\x01")},
+		},
+		{
+			name:     "long clobs are concatenated",
+			input:    `{{ '''a''' '''b''' }}`,
+			expected: &dynamodb.AttributeValue{B: []byte("ab")},
+		},
+		{
+			name:          "clobs must end with two braces",
+			input:         `{{"a"}`,
+			expectedError: true,
+		},
+		// Numbers.
+		{
+			name:     "ints",
+			input:    `-42`,
+			expected: &dynamodb.AttributeValue{N: aws.String("-42")},
+		},
+		{
+			name:     "ints can contain underscores",
+			input:    `1_000`,
+			expected: &dynamodb.AttributeValue{N: aws.String("1000")},
+		},
+		{
+			name:     "hex ints",
+			input:    `-0x1F`,
+			expected: &dynamodb.AttributeValue{N: aws.String("-31")},
+		},
+		{
+			name:     "binary ints",
+			input:    `0b101`,
+			expected: &dynamodb.AttributeValue{N: aws.String("5")},
+		},
+		{
+			name:     "decimals without a fraction",
+			input:    `1.`,
+			expected: &dynamodb.AttributeValue{N: aws.String("1")},
+		},
+		{
+			name:     "decimals keep their precision",
+			input:    `12.50`,
+			expected: &dynamodb.AttributeValue{N: aws.String("12.50")},
+		},
+		{
+			name:     "decimals with d exponents",
+			input:    `1.5d3`,
+			expected: &dynamodb.AttributeValue{N: aws.String("1.5e3")},
+		},
+		{
+			name:     "decimals with upper case negative D exponents",
+			input:    `-1.5D-3`,
+			expected: &dynamodb.AttributeValue{N: aws.String("-1.5e-3")},
+		},
+		{
+			name:     "decimals without a fraction with d exponents",
+			input:    `1.d2`,
+			expected: &dynamodb.AttributeValue{N: aws.String("1e2")},
+		},
+		{
+			name:     "floats use the shortest representation",
+			input:    `2.5e0`,
+			expected: &dynamodb.AttributeValue{N: aws.String("2.5")},
+		},
+		{
+			name:     "floats with negative exponents aren't timestamps",
+			input:    `-1.5e-3`,
+			expected: &dynamodb.AttributeValue{N: aws.String("-0.0015")},
+		},
+		{
+			name:     "decimals with negative exponents aren't timestamps",
+			input:    `-1d-3`,
+			expected: &dynamodb.AttributeValue{N: aws.String("-1e-3")},
+		},
+		{
+			name:     "positive infinity is a string",
+			input:    `+inf`,
+			expected: &dynamodb.AttributeValue{S: aws.String("+Inf")},
+		},
+		{
+			name:     "negative infinity is a string",
+			input:    `-inf`,
+			expected: &dynamodb.AttributeValue{S: aws.String("-Inf")},
+		},
+		{
+			name:     "nan is a string",
+			input:    `nan`,
+			expected: &dynamodb.AttributeValue{S: aws.String("NaN")},
+		},
+		{
+			name:          "invalid numbers are errors",
+			input:         `12abc`,
+			expectedError: true,
+		},
+		// Timestamps.
+		{
+			name:     "timestamps with a time are strings",
+			input:    `2020-01-02T03:04:05.123-08:00`,
+			expected: &dynamodb.AttributeValue{S: aws.String("2020-01-02T03:04:05.123-08:00")},
+		},
+		{
+			name:     "dates are strings",
+			input:    `2020-01-02`,
+			expected: &dynamodb.AttributeValue{S: aws.String("2020-01-02")},
+		},
+		{
+			name:     "year timestamps are strings",
+			input:    `2020T`,
+			expected: &dynamodb.AttributeValue{S: aws.String("2020T")},
+		},
+		// Containers.
+		{
+			name:  "structs",
+			input: `{a:1, 'b c':"x", "d":true,}`,
+			expected: &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{
+				"a":   {N: aws.String("1")},
+				"b c": {S: aws.String("x")},
+				"d":   {BOOL: aws.Bool(true)},
+			}},
+		},
+		{
+			name:          "struct fields must be separated by commas",
+			input:         `{a:1 b:2}`,
+			expectedError: true,
+		},
+		{
+			name:  "lists",
+			input: `[1, "a", []]`,
+			expected: &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{
+				{N: aws.String("1")},
+				{S: aws.String("a")},
+				{L: []*dynamodb.AttributeValue{}},
+			}},
+		},
+		{
+			name:          "s-expressions are not supported",
+			input:         `(a b)`,
+			expectedError: true,
+		},
+		// Sets.
+		{
+			name:     "string sets",
+			input:    `$dynamodb_SS::["a", "b"]`,
+			expected: &dynamodb.AttributeValue{SS: aws.StringSlice([]string{"a", "b"})},
+		},
+		{
+			name:     "number sets",
+			input:    `$dynamodb_NS::[1, 2.5]`,
+			expected: &dynamodb.AttributeValue{NS: aws.StringSlice([]string{"1", "2.5"})},
+		},
+		{
+			name:     "binary sets",
+			input:    `$dynamodb_BS::[{{AQ==}}, {{Ag==}}]`,
+			expected: &dynamodb.AttributeValue{BS: [][]byte{{1}, {2}}},
+		},
+		{
+			name:          "string sets must only contain strings",
+			input:         `$dynamodb_SS::["a", 1]`,
+			expectedError: true,
+		},
+		{
+			name:          "string sets can't contain nulls",
+			input:         `$dynamodb_SS::["a", null]`,
+			expectedError: true,
+		},
+		{
+			name:          "number sets must only contain numbers",
+			input:         `$dynamodb_NS::[1, "2"]`,
+			expectedError: true,
+		},
+		{
+			name:          "binary sets must only contain binary",
+			input:         `$dynamodb_BS::["AQ=="]`,
+			expectedError: true,
+		},
+		{
+			name:     "other annotations are ignored",
+			input:    `other::[1]`,
+			expected: &dynamodb.AttributeValue{L: []*dynamodb.AttributeValue{{N: aws.String("1")}}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := newParser(bufio.NewReader(strings.NewReader(tt.input))).value()
+			if tt.expectedError {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", v.av)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, v.av); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
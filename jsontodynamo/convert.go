@@ -0,0 +1,162 @@
+// Package jsontodynamo converts JSON Lines, one JSON object per line, to DynamoDB records. Lines
+// can be plain JSON, or the DynamoDB JSON written by DynamoDB's export to S3 feature.
+package jsontodynamo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/a-h/ddbimport/csvtodynamo"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// maxLineSize is larger than the maximum size of a DynamoDB item, to allow for the overhead of
+// JSON encoding.
+const maxLineSize = 4 * 1024 * 1024
+
+// ErrNotObject is the cause of an ErrRowConversion when a line isn't a JSON object.
+var ErrNotObject = errors.New("jsontodynamo: line is not a JSON object")
+
+// ErrNoItem is the cause of an ErrRowConversion when a line of DynamoDB JSON doesn't have an
+// Item.
+var ErrNoItem = errors.New("jsontodynamo: line has no Item")
+
+// Converter converts JSON Lines to DynamoDB records.
+type Converter struct {
+	s       *bufio.Scanner
+	lines   int64
+	convert func(line []byte) (map[string]*dynamodb.AttributeValue, error)
+}
+
+// NewConverter creates a Converter which reads plain JSON from r. Blank lines are skipped.
+func NewConverter(r io.Reader) *Converter {
+	c := newConverter(r)
+	c.convert = c.plain
+	return c
+}
+
+// NewDynamoDBJSONConverter creates a Converter which reads DynamoDB JSON from r, in the format
+// written by DynamoDB's export to S3 feature and by ddbimport's dynamodb export format, where
+// each line is an object with an Item. The types of attributes are part of the input, so no
+// configuration is needed.
+func NewDynamoDBJSONConverter(r io.Reader) *Converter {
+	c := newConverter(r)
+	c.convert = c.dynamoDBJSON
+	return c
+}
+
+func newConverter(r io.Reader) *Converter {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), maxLineSize)
+	return &Converter{s: s}
+}
+
+// ReadBatch reads 25 items from the input.
+func (c *Converter) ReadBatch() (items []map[string]*dynamodb.AttributeValue, read int, err error) {
+	batchSize := 25
+	items = make([]map[string]*dynamodb.AttributeValue, batchSize)
+	for read = 0; read < batchSize; read++ {
+		items[read], err = c.Read()
+		if err != nil {
+			break
+		}
+	}
+	return items[:read], read, err
+}
+
+// Read a single item from the input. io.EOF is returned when there are no more lines.
+func (c *Converter) Read() (item map[string]*dynamodb.AttributeValue, err error) {
+	var line []byte
+	for {
+		if !c.s.Scan() {
+			if err = c.s.Err(); err != nil {
+				return nil, &csvtodynamo.ErrRowConversion{Line: c.lines + 1, Cause: err}
+			}
+			return nil, io.EOF
+		}
+		c.lines++
+		if line = bytes.TrimSpace(c.s.Bytes()); len(line) > 0 {
+			break
+		}
+	}
+	return c.convert(line)
+}
+
+func (c *Converter) plain(line []byte) (item map[string]*dynamodb.AttributeValue, err error) {
+	d := json.NewDecoder(bytes.NewReader(line))
+	d.UseNumber()
+	var v interface{}
+	if err = d.Decode(&v); err != nil {
+		return nil, &csvtodynamo.ErrRowConversion{Line: c.lines, Cause: err}
+	}
+	doc, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, &csvtodynamo.ErrRowConversion{Line: c.lines, Cause: ErrNotObject}
+	}
+	item = make(map[string]*dynamodb.AttributeValue, len(doc))
+	for k, v := range doc {
+		if item[k], err = attributeValue(v); err != nil {
+			return nil, &csvtodynamo.ErrRowConversion{Line: c.lines, Column: k, Cause: err}
+		}
+		if err = csvtodynamo.CheckDepth(item[k]); err != nil {
+			return nil, &csvtodynamo.ErrRowConversion{Line: c.lines, Column: k, Cause: err}
+		}
+	}
+	return item, nil
+}
+
+func (c *Converter) dynamoDBJSON(line []byte) (item map[string]*dynamodb.AttributeValue, err error) {
+	var envelope struct {
+		Item map[string]*dynamodb.AttributeValue
+	}
+	if err = json.Unmarshal(line, &envelope); err != nil {
+		return nil, &csvtodynamo.ErrRowConversion{Line: c.lines, Cause: err}
+	}
+	if envelope.Item == nil {
+		return nil, &csvtodynamo.ErrRowConversion{Line: c.lines, Cause: ErrNoItem}
+	}
+	for k, v := range envelope.Item {
+		if err = csvtodynamo.CheckDepth(v); err != nil {
+			return nil, &csvtodynamo.ErrRowConversion{Line: c.lines, Column: k, Cause: err}
+		}
+	}
+	return envelope.Item, nil
+}
+
+// attributeValue converts a decoded JSON value to a DynamoDB attribute value. Numbers are
+// stored as N, booleans as BOOL, null as NULL, objects as M and arrays as L.
+func attributeValue(v interface{}) (*dynamodb.AttributeValue, error) {
+	switch v := v.(type) {
+	case nil:
+		return (&dynamodb.AttributeValue{}).SetNULL(true), nil
+	case bool:
+		return (&dynamodb.AttributeValue{}).SetBOOL(v), nil
+	case json.Number:
+		return (&dynamodb.AttributeValue{}).SetN(v.String()), nil
+	case string:
+		return (&dynamodb.AttributeValue{}).SetS(v), nil
+	case []interface{}:
+		l := make([]*dynamodb.AttributeValue, len(v))
+		for i, e := range v {
+			var err error
+			if l[i], err = attributeValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return (&dynamodb.AttributeValue{}).SetL(l), nil
+	case map[string]interface{}:
+		m := make(map[string]*dynamodb.AttributeValue, len(v))
+		for k, e := range v {
+			var err error
+			if m[k], err = attributeValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return (&dynamodb.AttributeValue{}).SetM(m), nil
+	}
+	return nil, fmt.Errorf("jsontodynamo: unexpected type %T", v)
+}
@@ -0,0 +1,129 @@
+package jsontodynamo
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/a-h/ddbimport/csvtodynamo"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConverter(t *testing.T) {
+	input := `{"id":"a","n":1.5,"ok":true,"none":null,"tags":["x",2],"address":{"city":"Leeds"}}
+
+{"id":"b"}
+`
+	c := NewConverter(strings.NewReader(input))
+	items, read, err := c.ReadBatch()
+	if err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if read != 2 {
+		t.Errorf("expected 2 reads, read %d", read)
+	}
+	expected := []map[string]*dynamodb.AttributeValue{
+		{
+			"id":   {S: aws.String("a")},
+			"n":    {N: aws.String("1.5")},
+			"ok":   {BOOL: aws.Bool(true)},
+			"none": {NULL: aws.Bool(true)},
+			"tags": {L: []*dynamodb.AttributeValue{{S: aws.String("x")}, {N: aws.String("2")}}},
+			"address": {M: map[string]*dynamodb.AttributeValue{
+				"city": {S: aws.String("Leeds")},
+			}},
+		},
+		{"id": {S: aws.String("b")}},
+	}
+	if diff := cmp.Diff(expected, items); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestConverterErrors(t *testing.T) {
+	var tests = []struct {
+		name          string
+		input         string
+		expectedLine  int64
+		expectedCause error
+	}{
+		{
+			name:         "invalid JSON includes the line",
+			input:        "{\"id\":\"a\"}\n\n{\"id\":",
+			expectedLine: 3,
+		},
+		{
+			name:          "lines must be objects",
+			input:         "{\"id\":\"a\"}\n[1,2]",
+			expectedLine:  2,
+			expectedCause: ErrNotObject,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConverter(strings.NewReader(tt.input))
+			_, _, err := c.ReadBatch()
+			var rce *csvtodynamo.ErrRowConversion
+			if !errors.As(err, &rce) {
+				t.Fatalf("expected ErrRowConversion, got %v", err)
+			}
+			if rce.Line != tt.expectedLine {
+				t.Errorf("expected line %d, got %d", tt.expectedLine, rce.Line)
+			}
+			if tt.expectedCause != nil && !errors.Is(err, tt.expectedCause) {
+				t.Errorf("expected %v, got %v", tt.expectedCause, err)
+			}
+		})
+	}
+}
+
+func TestDynamoDBJSONConverter(t *testing.T) {
+	input := `{"Item":{"id":{"S":"a"},"n":{"N":"1.5"},"b":{"B":"AQI="},"tags":{"SS":["x","y"]},"address":{"M":{"city":{"S":"Leeds"}}}}}
+
+{"Item":{"id":{"S":"b"},"ok":{"BOOL":true},"none":{"NULL":true},"l":{"L":[{"N":"1"}]}}}
+`
+	c := NewDynamoDBJSONConverter(strings.NewReader(input))
+	items, read, err := c.ReadBatch()
+	if err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if read != 2 {
+		t.Errorf("expected 2 reads, read %d", read)
+	}
+	expected := []map[string]*dynamodb.AttributeValue{
+		{
+			"id":   {S: aws.String("a")},
+			"n":    {N: aws.String("1.5")},
+			"b":    {B: []byte{1, 2}},
+			"tags": {SS: aws.StringSlice([]string{"x", "y"})},
+			"address": {M: map[string]*dynamodb.AttributeValue{
+				"city": {S: aws.String("Leeds")},
+			}},
+		},
+		{
+			"id":   {S: aws.String("b")},
+			"ok":   {BOOL: aws.Bool(true)},
+			"none": {NULL: aws.Bool(true)},
+			"l":    {L: []*dynamodb.AttributeValue{{N: aws.String("1")}}},
+		},
+	}
+	if diff := cmp.Diff(expected, items); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestDynamoDBJSONConverterNoItem(t *testing.T) {
+	c := NewDynamoDBJSONConverter(strings.NewReader("{\"Item\":{\"id\":{\"S\":\"a\"}}}\n{\"id\":{\"S\":\"b\"}}"))
+	_, read, err := c.ReadBatch()
+	if read != 1 {
+		t.Errorf("expected 1 read, read %d", read)
+	}
+	var rce *csvtodynamo.ErrRowConversion
+	if !errors.As(err, &rce) || !errors.Is(err, ErrNoItem) || rce.Line != 2 {
+		t.Errorf("expected ErrNoItem on line 2, got %v", err)
+	}
+}
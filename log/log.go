@@ -1,17 +1,98 @@
 package log
 
 import (
+	"time"
+
 	"github.com/a-h/ddbimport/version"
 	"go.uber.org/zap"
 )
 
 // Default logger of the system.
-var Default *zap.Logger
+var Default Logger
 
 func init() {
 	logger, err := zap.NewProduction()
 	if err != nil {
 		panic("failed to initilize logger: " + err.Error())
 	}
-	Default = logger.With(zap.String("v", version.Version))
+	Default = NewZap(logger)
+}
+
+// Logger is the logging interface used throughout ddbimport. Programs embedding ddbimport can
+// provide their own implementation.
+type Logger interface {
+	Debug(msg string, fields ...Field)
+	Info(msg string, fields ...Field)
+	Warn(msg string, fields ...Field)
+	Error(msg string, fields ...Field)
+	// Fatal logs the message and exits the program.
+	Fatal(msg string, fields ...Field)
+	// With returns a Logger that adds the fields to every message.
+	With(fields ...Field) Logger
 }
+
+// Field is a key/value pair added to a log message.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// String field.
+func String(key, value string) Field { return Field{Key: key, Value: value} }
+
+// Strings field.
+func Strings(key string, value []string) Field { return Field{Key: key, Value: value} }
+
+// Int field.
+func Int(key string, value int) Field { return Field{Key: key, Value: value} }
+
+// Int64 field.
+func Int64(key string, value int64) Field { return Field{Key: key, Value: value} }
+
+// Float64 field.
+func Float64(key string, value float64) Field { return Field{Key: key, Value: value} }
+
+// Bool field.
+func Bool(key string, value bool) Field { return Field{Key: key, Value: value} }
+
+// Duration field.
+func Duration(key string, value time.Duration) Field { return Field{Key: key, Value: value} }
+
+// Error field, using the key "error".
+func Error(err error) Field { return Field{Key: "error", Value: err} }
+
+// Errors field.
+func Errors(key string, errs []error) Field { return Field{Key: key, Value: errs} }
+
+// Any field.
+func Any(key string, value interface{}) Field { return Field{Key: key, Value: value} }
+
+// NewZap creates a Logger that writes to a zap.Logger, adding the version of ddbimport to
+// every message.
+func NewZap(logger *zap.Logger) Logger {
+	return zapLogger{l: logger.With(zap.String("v", version.Version))}
+}
+
+type zapLogger struct {
+	l *zap.Logger
+}
+
+func (z zapLogger) Debug(msg string, fields ...Field) { z.l.Debug(msg, zapFields(fields)...) }
+func (z zapLogger) Info(msg string, fields ...Field)  { z.l.Info(msg, zapFields(fields)...) }
+func (z zapLogger) Warn(msg string, fields ...Field)  { z.l.Warn(msg, zapFields(fields)...) }
+func (z zapLogger) Error(msg string, fields ...Field) { z.l.Error(msg, zapFields(fields)...) }
+func (z zapLogger) Fatal(msg string, fields ...Field) { z.l.Fatal(msg, zapFields(fields)...) }
+func (z zapLogger) With(fields ...Field) Logger {
+	return zapLogger{l: z.l.With(zapFields(fields)...)}
+}
+
+func zapFields(fields []Field) []zap.Field {
+	zf := make([]zap.Field, len(fields))
+	for i, f := range fields {
+		zf[i] = zap.Any(f.Key, f.Value)
+	}
+	return zf
+}
+
+// Nop is a Logger that discards all messages. Fatal messages still exit the program.
+var Nop Logger = NewZap(zap.NewNop())
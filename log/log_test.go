@@ -0,0 +1,35 @@
+package log
+
+import (
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestZapLogger(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	logger := NewZap(zap.New(core)).With(String("table", "ddbimport"))
+	logger.Debug("ignored")
+	logger.Info("progress", Int64("records", 100), Error(errors.New("failed")))
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 log entry, got %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["table"] != "ddbimport" {
+		t.Errorf("expected table field, got %v", fields["table"])
+	}
+	if fields["records"] != int64(100) {
+		t.Errorf("expected records field, got %v", fields["records"])
+	}
+	if fields["error"] != "failed" {
+		t.Errorf("expected error field, got %v", fields["error"])
+	}
+	if _, ok := fields["v"]; !ok {
+		t.Error("expected version field")
+	}
+}
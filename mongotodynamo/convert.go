@@ -0,0 +1,228 @@
+// Package mongotodynamo converts documents exported by mongoexport, in MongoDB extended JSON,
+// to DynamoDB records.
+package mongotodynamo
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/a-h/ddbimport/csvtodynamo"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// DateFormat is the format that $date values are stored in. Dates are converted to UTC, so
+// that they sort in time order.
+const DateFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// Converter converts extended JSON documents to DynamoDB records.
+type Converter struct {
+	d         *json.Decoder
+	array     bool
+	documents int64
+}
+
+// NewConverter creates a Converter which reads from r. Both the default output of mongoexport,
+// one document per line, and the output of mongoexport --jsonArray are supported.
+func NewConverter(r io.Reader) (*Converter, error) {
+	br := bufio.NewReader(r)
+	first, err := firstByte(br)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	c := &Converter{
+		d: json.NewDecoder(br),
+	}
+	c.d.UseNumber()
+	if first == '[' {
+		if _, err = c.d.Token(); err != nil {
+			return nil, err
+		}
+		c.array = true
+	}
+	return c, nil
+}
+
+// firstByte returns the first non-whitespace byte of the input, without consuming it.
+func firstByte(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, br.UnreadByte()
+	}
+}
+
+// ReadBatch reads 25 items from the input.
+func (c *Converter) ReadBatch() (items []map[string]*dynamodb.AttributeValue, read int, err error) {
+	batchSize := 25
+	items = make([]map[string]*dynamodb.AttributeValue, batchSize)
+	for read = 0; read < batchSize; read++ {
+		items[read], err = c.Read()
+		if err != nil {
+			break
+		}
+	}
+	return items[:read], read, err
+}
+
+// Read a single item from the input. io.EOF is returned when there are no more documents.
+func (c *Converter) Read() (item map[string]*dynamodb.AttributeValue, err error) {
+	if c.array && !c.d.More() {
+		return nil, io.EOF
+	}
+	var doc map[string]interface{}
+	if err = c.d.Decode(&doc); err != nil {
+		if err == io.EOF {
+			return
+		}
+		return nil, &csvtodynamo.ErrRowConversion{Line: c.documents + 1, Cause: err}
+	}
+	c.documents++
+	item = make(map[string]*dynamodb.AttributeValue, len(doc))
+	for k, v := range doc {
+		if item[k], err = attributeValue(v); err != nil {
+			return nil, &csvtodynamo.ErrRowConversion{Line: c.documents, Column: k, Cause: err}
+		}
+	}
+	return item, nil
+}
+
+// attributeValue converts a value decoded from extended JSON to a DynamoDB attribute value.
+// ObjectIds are stored as hex strings, $numberLong, $numberInt, $numberDouble and
+// $numberDecimal as numbers, $date as an ISO 8601 string and $binary as binary. Other
+// extended JSON types are stored as maps.
+func attributeValue(v interface{}) (*dynamodb.AttributeValue, error) {
+	switch v := v.(type) {
+	case nil:
+		return (&dynamodb.AttributeValue{}).SetNULL(true), nil
+	case bool:
+		return (&dynamodb.AttributeValue{}).SetBOOL(v), nil
+	case json.Number:
+		return (&dynamodb.AttributeValue{}).SetN(v.String()), nil
+	case string:
+		return (&dynamodb.AttributeValue{}).SetS(v), nil
+	case []interface{}:
+		l := make([]*dynamodb.AttributeValue, len(v))
+		for i, e := range v {
+			var err error
+			if l[i], err = attributeValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return (&dynamodb.AttributeValue{}).SetL(l), nil
+	case map[string]interface{}:
+		if av, ok, err := extendedValue(v); ok {
+			return av, err
+		}
+		m := make(map[string]*dynamodb.AttributeValue, len(v))
+		for k, e := range v {
+			var err error
+			if m[k], err = attributeValue(e); err != nil {
+				return nil, err
+			}
+		}
+		return (&dynamodb.AttributeValue{}).SetM(m), nil
+	}
+	return nil, fmt.Errorf("mongotodynamo: unexpected type %T", v)
+}
+
+// extendedValue converts the extended JSON types which have a natural DynamoDB equivalent.
+// ok is false if the map is not one of those types.
+func extendedValue(m map[string]interface{}) (av *dynamodb.AttributeValue, ok bool, err error) {
+	if len(m) == 2 {
+		// Legacy binary format: {"$binary": "<base64>", "$type": "<subtype>"}.
+		if s, isString := m["$binary"].(string); isString && m["$type"] != nil {
+			av, err = binaryValue(s)
+			return av, true, err
+		}
+		return nil, false, nil
+	}
+	if len(m) != 1 {
+		return nil, false, nil
+	}
+	for k, v := range m {
+		switch k {
+		case "$oid":
+			s, isString := v.(string)
+			if !isString {
+				return nil, true, errors.New("mongotodynamo: $oid must be a string")
+			}
+			return (&dynamodb.AttributeValue{}).SetS(s), true, nil
+		case "$numberLong", "$numberInt", "$numberDouble", "$numberDecimal":
+			s, isString := v.(string)
+			if !isString {
+				return nil, true, fmt.Errorf("mongotodynamo: %s must be a string", k)
+			}
+			if f, parseErr := strconv.ParseFloat(s, 64); parseErr != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+				// Infinity and NaN can't be stored as DynamoDB numbers.
+				return (&dynamodb.AttributeValue{}).SetS(s), true, nil
+			}
+			return (&dynamodb.AttributeValue{}).SetN(s), true, nil
+		case "$date":
+			av, err = dateValue(v)
+			return av, true, err
+		case "$binary":
+			b, isMap := v.(map[string]interface{})
+			if !isMap {
+				return nil, true, errors.New("mongotodynamo: $binary must be an object")
+			}
+			s, _ := b["base64"].(string)
+			av, err = binaryValue(s)
+			return av, true, err
+		}
+	}
+	return nil, false, nil
+}
+
+// dateValue converts the relaxed format {"$date": "<ISO 8601>"}, the canonical format
+// {"$date": {"$numberLong": "<milliseconds>"}} and the legacy format {"$date": <milliseconds>}.
+func dateValue(v interface{}) (*dynamodb.AttributeValue, error) {
+	var t time.Time
+	switch v := v.(type) {
+	case string:
+		var err error
+		if t, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return nil, fmt.Errorf("mongotodynamo: invalid $date: %w", err)
+		}
+	case json.Number:
+		ms, err := v.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("mongotodynamo: invalid $date: %w", err)
+		}
+		t = fromMilliseconds(ms)
+	case map[string]interface{}:
+		s, _ := v["$numberLong"].(string)
+		ms, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("mongotodynamo: invalid $date: %w", err)
+		}
+		t = fromMilliseconds(ms)
+	default:
+		return nil, fmt.Errorf("mongotodynamo: invalid $date type %T", v)
+	}
+	return (&dynamodb.AttributeValue{}).SetS(t.UTC().Format(DateFormat)), nil
+}
+
+func fromMilliseconds(ms int64) time.Time {
+	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+}
+
+func binaryValue(s string) (*dynamodb.AttributeValue, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("mongotodynamo: invalid $binary: %w", err)
+	}
+	return (&dynamodb.AttributeValue{}).SetB(b), nil
+}
@@ -0,0 +1,186 @@
+package mongotodynamo
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/a-h/ddbimport/csvtodynamo"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConverter(t *testing.T) {
+	var tests = []struct {
+		name  string
+		input string
+	}{
+		{
+			name: "one document per line",
+			input: `{"_id":{"$oid":"5f1b2c3d4e5f6a7b8c9d0e1f"},"n":1}
+{"_id":{"$oid":"5f1b2c3d4e5f6a7b8c9d0e20"},"n":2}
+`,
+		},
+		{
+			name: "json array",
+			input: ` [
+  {"_id":{"$oid":"5f1b2c3d4e5f6a7b8c9d0e1f"},"n":1},
+  {"_id":{"$oid":"5f1b2c3d4e5f6a7b8c9d0e20"},"n":2}
+]`,
+		},
+	}
+	expected := []map[string]*dynamodb.AttributeValue{
+		{"_id": {S: aws.String("5f1b2c3d4e5f6a7b8c9d0e1f")}, "n": {N: aws.String("1")}},
+		{"_id": {S: aws.String("5f1b2c3d4e5f6a7b8c9d0e20")}, "n": {N: aws.String("2")}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := NewConverter(strings.NewReader(test.input))
+			if err != nil {
+				t.Fatalf("failed to create converter: %v", err)
+			}
+			items, read, err := c.ReadBatch()
+			if err != io.EOF {
+				t.Errorf("expected EOF, got %v", err)
+			}
+			if read != 2 {
+				t.Errorf("expected 2 items, got %d", read)
+			}
+			if diff := cmp.Diff(expected, items); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestConverterEmptyInput(t *testing.T) {
+	c, err := NewConverter(strings.NewReader(""))
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	if _, err = c.Read(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestConverterInvalidValue(t *testing.T) {
+	c, err := NewConverter(strings.NewReader(`{"a":1}
+{"a":2,"d":{"$date":"yesterday"}}`))
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	_, _, err = c.ReadBatch()
+	var rce *csvtodynamo.ErrRowConversion
+	if !errors.As(err, &rce) {
+		t.Fatalf("expected ErrRowConversion, got %v", err)
+	}
+	if rce.Line != 2 || rce.Column != "d" {
+		t.Errorf("expected line 2, column d, got line %d, column %q", rce.Line, rce.Column)
+	}
+}
+
+func TestAttributeValue(t *testing.T) {
+	var tests = []struct {
+		name     string
+		input    string
+		expected *dynamodb.AttributeValue
+	}{
+		{
+			name:     "string",
+			input:    `"abc"`,
+			expected: &dynamodb.AttributeValue{S: aws.String("abc")},
+		},
+		{
+			name:     "large numbers keep their precision",
+			input:    `12345678901234567890`,
+			expected: &dynamodb.AttributeValue{N: aws.String("12345678901234567890")},
+		},
+		{
+			name:     "null",
+			input:    `null`,
+			expected: &dynamodb.AttributeValue{NULL: aws.Bool(true)},
+		},
+		{
+			name:     "ObjectId",
+			input:    `{"$oid":"5f1b2c3d4e5f6a7b8c9d0e1f"}`,
+			expected: &dynamodb.AttributeValue{S: aws.String("5f1b2c3d4e5f6a7b8c9d0e1f")},
+		},
+		{
+			name:     "numberLong",
+			input:    `{"$numberLong":"9223372036854775807"}`,
+			expected: &dynamodb.AttributeValue{N: aws.String("9223372036854775807")},
+		},
+		{
+			name:     "numberDecimal",
+			input:    `{"$numberDecimal":"1.10"}`,
+			expected: &dynamodb.AttributeValue{N: aws.String("1.10")},
+		},
+		{
+			name:     "numberDouble infinity",
+			input:    `{"$numberDouble":"Infinity"}`,
+			expected: &dynamodb.AttributeValue{S: aws.String("Infinity")},
+		},
+		{
+			name:     "relaxed date",
+			input:    `{"$date":"2020-07-24T13:45:00.5+01:00"}`,
+			expected: &dynamodb.AttributeValue{S: aws.String("2020-07-24T12:45:00.500Z")},
+		},
+		{
+			name:     "canonical date",
+			input:    `{"$date":{"$numberLong":"1595598300500"}}`,
+			expected: &dynamodb.AttributeValue{S: aws.String("2020-07-24T13:45:00.500Z")},
+		},
+		{
+			name:     "legacy date",
+			input:    `{"$date":1595598300500}`,
+			expected: &dynamodb.AttributeValue{S: aws.String("2020-07-24T13:45:00.500Z")},
+		},
+		{
+			name:     "binary",
+			input:    `{"$binary":{"base64":"AQI=","subType":"00"}}`,
+			expected: &dynamodb.AttributeValue{B: []byte{1, 2}},
+		},
+		{
+			name:     "legacy binary",
+			input:    `{"$binary":"AQI=","$type":"00"}`,
+			expected: &dynamodb.AttributeValue{B: []byte{1, 2}},
+		},
+		{
+			name:  "nested documents and arrays",
+			input: `{"tags":["a",{"$numberInt":"1"}],"owner":{"id":{"$oid":"5f1b2c3d4e5f6a7b8c9d0e1f"}}}`,
+			expected: &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{
+				"tags": {L: []*dynamodb.AttributeValue{{S: aws.String("a")}, {N: aws.String("1")}}},
+				"owner": {M: map[string]*dynamodb.AttributeValue{
+					"id": {S: aws.String("5f1b2c3d4e5f6a7b8c9d0e1f")},
+				}},
+			}},
+		},
+		{
+			name:  "other extended types are stored as maps",
+			input: `{"$timestamp":{"t":1,"i":2}}`,
+			expected: &dynamodb.AttributeValue{M: map[string]*dynamodb.AttributeValue{
+				"$timestamp": {M: map[string]*dynamodb.AttributeValue{
+					"t": {N: aws.String("1")},
+					"i": {N: aws.String("2")},
+				}},
+			}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := NewConverter(strings.NewReader(`{"v":` + test.input + `}`))
+			if err != nil {
+				t.Fatalf("failed to create converter: %v", err)
+			}
+			item, err := c.Read()
+			if err != nil {
+				t.Fatalf("failed to read: %v", err)
+			}
+			if diff := cmp.Diff(test.expected, item["v"]); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// Package overflow stores items which are too large for DynamoDB in S3, leaving a pointer to
+// the S3 object in the table.
+package overflow
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/a-h/ddbimport/dynamoexport"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// MaxItemSize is the maximum size of a DynamoDB item.
+const MaxItemSize = 400 * 1024
+
+// DefaultPointerAttribute is the default name of the attribute which points to the S3 object.
+const DefaultPointerAttribute = "_overflow"
+
+// ItemSize returns the size of the item, as calculated by DynamoDB: the length of each
+// attribute name plus the size of its value.
+func ItemSize(item map[string]*dynamodb.AttributeValue) (size int) {
+	for name, av := range item {
+		size += len(name) + valueSize(av)
+	}
+	return
+}
+
+func valueSize(av *dynamodb.AttributeValue) (size int) {
+	switch {
+	case av.S != nil:
+		return len(*av.S)
+	case av.N != nil:
+		// Numbers are stored in up to 21 bytes, so their string length is an upper bound.
+		return len(*av.N)
+	case av.B != nil:
+		return len(av.B)
+	case av.BOOL != nil, av.NULL != nil:
+		return 1
+	case av.SS != nil:
+		for _, s := range av.SS {
+			size += len(aws.StringValue(s))
+		}
+	case av.NS != nil:
+		for _, n := range av.NS {
+			size += len(aws.StringValue(n))
+		}
+	case av.BS != nil:
+		for _, b := range av.BS {
+			size += len(b)
+		}
+	case av.L != nil:
+		size = 3
+		for _, e := range av.L {
+			size += 1 + valueSize(e)
+		}
+	case av.M != nil:
+		size = 3
+		for k, e := range av.M {
+			size += 1 + len(k) + valueSize(e)
+		}
+	}
+	return
+}
+
+// Offloader writes items larger than MaxItemSize to S3, and replaces them with their key
+// attributes and a pointer to the S3 object. It is safe for concurrent use.
+type Offloader struct {
+	// Bucket to write the items to.
+	Bucket string
+	// Prefix of the S3 keys, e.g. "overflow/".
+	Prefix string
+	// PointerAttribute is the name of the map attribute which stores the bucket, key and ETag
+	// of the S3 object.
+	PointerAttribute string
+	// KeyNames are the names of the table's key attributes, which are kept in the item.
+	KeyNames []string
+	client   s3iface.S3API
+}
+
+// New creates an Offloader which writes to the bucket.
+func New(client s3iface.S3API, bucket, prefix string, keyNames []string) *Offloader {
+	return &Offloader{
+		Bucket:           bucket,
+		Prefix:           prefix,
+		PointerAttribute: DefaultPointerAttribute,
+		KeyNames:         keyNames,
+		client:           client,
+	}
+}
+
+// Offload the item to S3 if it is too large, replacing its attributes in place. ok is true if
+// the item was offloaded. The S3 object contains the item's attributes as DynamoDB JSON.
+func (o *Offloader) Offload(item map[string]*dynamodb.AttributeValue) (ok bool, err error) {
+	if ItemSize(item) <= MaxItemSize {
+		return false, nil
+	}
+	body, err := json.Marshal(dynamoexport.DynamoDBJSON(item))
+	if err != nil {
+		return false, fmt.Errorf("overflow: failed to marshal item: %w", err)
+	}
+	key, err := o.objectKey(item)
+	if err != nil {
+		return false, err
+	}
+	poo, err := o.client.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(o.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return false, fmt.Errorf("overflow: failed to write item to s3://%s/%s: %w", o.Bucket, key, err)
+	}
+	for name := range item {
+		if !o.isKey(name) {
+			delete(item, name)
+		}
+	}
+	pointer := map[string]*dynamodb.AttributeValue{
+		"bucket": {S: aws.String(o.Bucket)},
+		"key":    {S: aws.String(key)},
+	}
+	// S3 compatible stores may not return an ETag, and an empty string attribute is invalid.
+	if aws.StringValue(poo.ETag) != "" {
+		pointer["etag"] = &dynamodb.AttributeValue{S: poo.ETag}
+	}
+	item[o.PointerAttribute] = &dynamodb.AttributeValue{M: pointer}
+	return true, nil
+}
+
+// objectKey is derived from the item's key, so that importing the same item again overwrites
+// the object.
+func (o *Offloader) objectKey(item map[string]*dynamodb.AttributeValue) (string, error) {
+	names := append([]string{}, o.KeyNames...)
+	sort.Strings(names)
+	key := make([]interface{}, len(names))
+	for i, name := range names {
+		v, ok := item[name]
+		if !ok {
+			return "", fmt.Errorf("overflow: item is missing key attribute %q", name)
+		}
+		key[i] = []interface{}{name, v}
+	}
+	b, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(b)
+	return o.Prefix + hex.EncodeToString(hash[:]) + ".json", nil
+}
+
+func (o *Offloader) isKey(name string) bool {
+	for _, k := range o.KeyNames {
+		if k == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,97 @@
+package overflow
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestItemSize(t *testing.T) {
+	item := map[string]*dynamodb.AttributeValue{
+		"name": {S: aws.String("abc")},
+		"n":    {N: aws.String("12")},
+		"ok":   {BOOL: aws.Bool(true)},
+		"l":    {L: []*dynamodb.AttributeValue{{S: aws.String("ab")}}},
+		"m":    {M: map[string]*dynamodb.AttributeValue{"k": {S: aws.String("v")}}},
+	}
+	// name: 4+3, n: 1+2, ok: 2+1, l: 1+3+1+2, m: 1+3+1+1+1.
+	if size := ItemSize(item); size != 27 {
+		t.Errorf("expected 27, got %d", size)
+	}
+}
+
+type fakeS3 struct {
+	s3iface.S3API
+	objects map[string][]byte
+	noETag  bool
+}
+
+func (f *fakeS3) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	b, err := ioutil.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.objects[*input.Bucket+"/"+*input.Key] = b
+	if f.noETag {
+		return &s3.PutObjectOutput{}, nil
+	}
+	return &s3.PutObjectOutput{ETag: aws.String(`"etag"`)}, nil
+}
+
+func TestOffload(t *testing.T) {
+	client := &fakeS3{objects: map[string][]byte{}}
+	o := New(client, "bucket", "overflow/", []string{"pk"})
+
+	small := map[string]*dynamodb.AttributeValue{"pk": {S: aws.String("1")}, "text": {S: aws.String("small")}}
+	if ok, err := o.Offload(small); ok || err != nil {
+		t.Errorf("expected small items not to be offloaded, got %v, %v", ok, err)
+	}
+
+	text := strings.Repeat("a", MaxItemSize)
+	large := map[string]*dynamodb.AttributeValue{"pk": {S: aws.String("2")}, "text": {S: aws.String(text)}}
+	ok, err := o.Offload(large)
+	if !ok || err != nil {
+		t.Fatalf("expected large item to be offloaded, got %v, %v", ok, err)
+	}
+	pointer := large[DefaultPointerAttribute].M
+	if len(large) != 2 || large["pk"] == nil || pointer == nil {
+		t.Fatalf("expected the key and pointer only, got %v", large)
+	}
+	if aws.StringValue(pointer["bucket"].S) != "bucket" || aws.StringValue(pointer["etag"].S) != `"etag"` || !strings.HasPrefix(aws.StringValue(pointer["key"].S), "overflow/") {
+		t.Errorf("unexpected pointer: %v", pointer)
+	}
+	var stored map[string]interface{}
+	if err = json.Unmarshal(client.objects["bucket/"+aws.StringValue(pointer["key"].S)], &stored); err != nil {
+		t.Fatalf("failed to unmarshal stored item: %v", err)
+	}
+	expected := map[string]interface{}{
+		"pk":   map[string]interface{}{"S": "2"},
+		"text": map[string]interface{}{"S": text},
+	}
+	if diff := cmp.Diff(expected, stored); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestOffloadWithoutETag(t *testing.T) {
+	client := &fakeS3{objects: map[string][]byte{}, noETag: true}
+	o := New(client, "bucket", "overflow/", []string{"pk"})
+	large := map[string]*dynamodb.AttributeValue{"pk": {S: aws.String("1")}, "text": {S: aws.String(strings.Repeat("a", MaxItemSize))}}
+	if ok, err := o.Offload(large); !ok || err != nil {
+		t.Fatalf("expected large item to be offloaded, got %v, %v", ok, err)
+	}
+	pointer := large[DefaultPointerAttribute].M
+	if _, ok := pointer["etag"]; ok {
+		t.Errorf("expected no etag, got %v", pointer)
+	}
+	if len(pointer) != 2 {
+		t.Errorf("expected the bucket and key, got %v", pointer)
+	}
+}
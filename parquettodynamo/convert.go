@@ -0,0 +1,317 @@
+// Package parquettodynamo converts the rows of Apache Parquet files, e.g. those written by
+// Athena and Glue, to DynamoDB records.
+package parquettodynamo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/a-h/ddbimport/csvtodynamo"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/schema"
+	"github.com/xitongsys/parquet-go/source"
+)
+
+// DateFormat is the format that DATE values are stored in.
+const DateFormat = "2006-01-02"
+
+// Converter converts the rows of a Parquet file to DynamoDB records.
+type Converter struct {
+	pr   *reader.ParquetReader
+	sh   *schema.SchemaHandler
+	rows int64
+}
+
+// NewConverter creates a Converter which reads a Parquet file. Parquet files are read from the
+// footer, and each column is read separately, so open is called to open the file once for the
+// footer and once for each column.
+func NewConverter(open func() (io.ReadSeeker, error)) (*Converter, error) {
+	f, err := newFile(open)
+	if err != nil {
+		return nil, err
+	}
+	pr, err := reader.NewParquetReader(f, nil, 1)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("parquettodynamo: failed to read file: %w", err)
+	}
+	f.Close()
+	return &Converter{pr: pr, sh: pr.SchemaHandler}, nil
+}
+
+// Rows returns the number of rows in the file.
+func (c *Converter) Rows() int64 {
+	return c.pr.GetNumRows()
+}
+
+// ReadBatch reads 25 items from the file.
+func (c *Converter) ReadBatch() (items []map[string]*dynamodb.AttributeValue, read int, err error) {
+	n := c.Rows() - c.rows
+	if n <= 0 {
+		return nil, 0, io.EOF
+	}
+	if n > 25 {
+		n = 25
+	}
+	rows, err := c.pr.ReadByNumber(int(n))
+	if err != nil {
+		return nil, 0, &csvtodynamo.ErrRowConversion{Line: c.rows + 1, Cause: err}
+	}
+	root := c.sh.GetRootInName()
+	items = make([]map[string]*dynamodb.AttributeValue, 0, len(rows))
+	for _, row := range rows {
+		c.rows++
+		av, _, err := c.attributeValue(reflect.ValueOf(row), root)
+		if err != nil {
+			var fe *fieldError
+			if errors.As(err, &fe) {
+				return items, len(items), &csvtodynamo.ErrRowConversion{Line: c.rows, Column: fe.field, Cause: fe.cause}
+			}
+			return items, len(items), &csvtodynamo.ErrRowConversion{Line: c.rows, Cause: err}
+		}
+		items = append(items, av.M)
+	}
+	if c.rows == c.Rows() {
+		err = io.EOF
+	}
+	return items, len(items), err
+}
+
+// Close the file.
+func (c *Converter) Close() error {
+	c.pr.ReadStop()
+	return nil
+}
+
+type fieldError struct {
+	field string
+	cause error
+}
+
+func (e *fieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.field, e.cause)
+}
+
+func (e *fieldError) Unwrap() error {
+	return e.cause
+}
+
+// attributeValue converts a value read from the column at path. ok is false for null values,
+// which are omitted.
+func (c *Converter) attributeValue(v reflect.Value, path string) (av *dynamodb.AttributeValue, ok bool, err error) {
+	idx, found := c.sh.MapIndex[path]
+	if !found {
+		return nil, false, fmt.Errorf("parquettodynamo: column %q not found in schema", path)
+	}
+	se := c.sh.SchemaElements[idx]
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil, false, nil
+		}
+		return c.attributeValue(v.Elem(), path)
+	case reflect.Struct:
+		m := make(map[string]*dynamodb.AttributeValue, v.NumField())
+		for i := 0; i < v.NumField(); i++ {
+			fieldPath := common.PathToStr([]string{path, v.Type().Field(i).Name})
+			name := c.sh.Infos[c.sh.MapIndex[fieldPath]].ExName
+			fav, ok, err := c.attributeValue(v.Field(i), fieldPath)
+			if err != nil {
+				if _, isFieldError := err.(*fieldError); !isFieldError {
+					err = &fieldError{field: name, cause: err}
+				}
+				return nil, false, err
+			}
+			if ok {
+				m[name] = fav
+			}
+		}
+		return (&dynamodb.AttributeValue{}).SetM(m), true, nil
+	case reflect.Slice:
+		elementPath := path
+		if se.ConvertedType != nil && *se.ConvertedType == parquet.ConvertedType_LIST {
+			elementPath = common.PathToStr([]string{path, "List", "Element"})
+		}
+		l := make([]*dynamodb.AttributeValue, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			eav, ok, err := c.attributeValue(v.Index(i), elementPath)
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok {
+				eav = (&dynamodb.AttributeValue{}).SetNULL(true)
+			}
+			l = append(l, eav)
+		}
+		return (&dynamodb.AttributeValue{}).SetL(l), true, nil
+	case reflect.Map:
+		keyPath := common.PathToStr([]string{path, "Key_value", "Key"})
+		valuePath := common.PathToStr([]string{path, "Key_value", "Value"})
+		m := make(map[string]*dynamodb.AttributeValue, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			kav, _, err := c.attributeValue(iter.Key(), keyPath)
+			if err != nil {
+				return nil, false, err
+			}
+			vav, ok, err := c.attributeValue(iter.Value(), valuePath)
+			if err != nil {
+				return nil, false, err
+			}
+			if !ok {
+				vav = (&dynamodb.AttributeValue{}).SetNULL(true)
+			}
+			m[text(kav)] = vav
+		}
+		return (&dynamodb.AttributeValue{}).SetM(m), true, nil
+	}
+	av, err = primitiveValue(v, se)
+	return av, err == nil, err
+}
+
+// text returns the string representation of a map key.
+func text(av *dynamodb.AttributeValue) string {
+	switch {
+	case av.S != nil:
+		return *av.S
+	case av.N != nil:
+		return *av.N
+	case av.BOOL != nil:
+		return strconv.FormatBool(*av.BOOL)
+	}
+	return string(av.B)
+}
+
+// primitiveValue converts a value using its Parquet type. DATE values are stored as ISO 8601
+// dates, TIMESTAMP and INT96 values as RFC 3339 strings in UTC, DECIMAL values as numbers,
+// UTF8, ENUM and JSON values as strings, and other byte arrays as binary.
+func primitiveValue(v reflect.Value, se *parquet.SchemaElement) (*dynamodb.AttributeValue, error) {
+	var ct parquet.ConvertedType = -1
+	if se.ConvertedType != nil {
+		ct = *se.ConvertedType
+	}
+	switch se.GetType() {
+	case parquet.Type_BOOLEAN:
+		return (&dynamodb.AttributeValue{}).SetBOOL(v.Bool()), nil
+	case parquet.Type_INT32, parquet.Type_INT64:
+		var i int64
+		switch v.Kind() {
+		case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return (&dynamodb.AttributeValue{}).SetN(strconv.FormatUint(v.Uint(), 10)), nil
+		default:
+			i = v.Int()
+		}
+		switch ct {
+		case parquet.ConvertedType_DATE:
+			return (&dynamodb.AttributeValue{}).SetS(time.Unix(i*86400, 0).UTC().Format(DateFormat)), nil
+		case parquet.ConvertedType_TIMESTAMP_MILLIS:
+			return timeValue(time.Unix(0, i*int64(time.Millisecond))), nil
+		case parquet.ConvertedType_TIMESTAMP_MICROS:
+			return timeValue(time.Unix(0, i*int64(time.Microsecond))), nil
+		case parquet.ConvertedType_DECIMAL:
+			return decimalValue(big.NewInt(i), se.GetScale()), nil
+		}
+		return (&dynamodb.AttributeValue{}).SetN(strconv.FormatInt(i, 10)), nil
+	case parquet.Type_FLOAT, parquet.Type_DOUBLE:
+		f := v.Float()
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			// Infinity and NaN can't be stored as DynamoDB numbers.
+			return (&dynamodb.AttributeValue{}).SetS(strconv.FormatFloat(f, 'g', -1, 64)), nil
+		}
+		bitSize := 64
+		if se.GetType() == parquet.Type_FLOAT {
+			bitSize = 32
+		}
+		return (&dynamodb.AttributeValue{}).SetN(strconv.FormatFloat(f, 'g', -1, bitSize)), nil
+	case parquet.Type_INT96:
+		return int96Value(v.String())
+	case parquet.Type_BYTE_ARRAY, parquet.Type_FIXED_LEN_BYTE_ARRAY:
+		s := v.String()
+		switch ct {
+		case parquet.ConvertedType_UTF8, parquet.ConvertedType_ENUM, parquet.ConvertedType_JSON:
+			return (&dynamodb.AttributeValue{}).SetS(s), nil
+		case parquet.ConvertedType_DECIMAL:
+			return decimalValue(twosComplement([]byte(s)), se.GetScale()), nil
+		}
+		return (&dynamodb.AttributeValue{}).SetB([]byte(s)), nil
+	}
+	return nil, fmt.Errorf("parquettodynamo: unsupported type %v", se.GetType())
+}
+
+func timeValue(t time.Time) *dynamodb.AttributeValue {
+	return (&dynamodb.AttributeValue{}).SetS(t.UTC().Format(time.RFC3339Nano))
+}
+
+// julianUnixEpoch is the Julian day number of 1970-01-01.
+const julianUnixEpoch = 2440588
+
+// int96Value converts the legacy timestamp format used by Hive, Impala and Athena: the
+// nanoseconds within the day, followed by the Julian day number, both little endian.
+func int96Value(s string) (*dynamodb.AttributeValue, error) {
+	if len(s) != 12 {
+		return nil, fmt.Errorf("parquettodynamo: invalid INT96 timestamp length %d", len(s))
+	}
+	b := []byte(s)
+	nanos := int64(binary.LittleEndian.Uint64(b[:8]))
+	days := int64(binary.LittleEndian.Uint32(b[8:]))
+	return timeValue(time.Unix((days-julianUnixEpoch)*86400, nanos)), nil
+}
+
+// twosComplement converts a big endian two's complement integer.
+func twosComplement(b []byte) *big.Int {
+	i := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		i.Sub(i, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	return i
+}
+
+func decimalValue(unscaled *big.Int, scale int32) *dynamodb.AttributeValue {
+	r := new(big.Rat).SetFrac(unscaled, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil))
+	return (&dynamodb.AttributeValue{}).SetN(r.FloatString(int(scale)))
+}
+
+// file opens a new ReadSeeker each time the Parquet reader opens the file.
+type file struct {
+	open func() (io.ReadSeeker, error)
+	io.ReadSeeker
+}
+
+func newFile(open func() (io.ReadSeeker, error)) (*file, error) {
+	rs, err := open()
+	if err != nil {
+		return nil, err
+	}
+	return &file{open: open, ReadSeeker: rs}, nil
+}
+
+var _ source.ParquetFile = &file{}
+
+func (f *file) Open(name string) (source.ParquetFile, error) {
+	return newFile(f.open)
+}
+
+func (f *file) Create(name string) (source.ParquetFile, error) {
+	return nil, errors.New("parquettodynamo: files are read only")
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	return 0, errors.New("parquettodynamo: files are read only")
+}
+
+func (f *file) Close() error {
+	if c, ok := f.ReadSeeker.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
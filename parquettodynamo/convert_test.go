@@ -0,0 +1,151 @@
+package parquettodynamo
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+type address struct {
+	City string `parquet:"name=city, type=UTF8"`
+}
+
+type row struct {
+	ID      string   `parquet:"name=id, type=UTF8"`
+	Count   int64    `parquet:"name=count, type=INT64"`
+	Price   int32    `parquet:"name=price, type=DECIMAL, basetype=INT32, scale=2, precision=9"`
+	Score   float64  `parquet:"name=score, type=DOUBLE"`
+	OK      bool     `parquet:"name=ok, type=BOOLEAN"`
+	Day     int32    `parquet:"name=day, type=DATE"`
+	Created int64    `parquet:"name=created, type=TIMESTAMP_MILLIS"`
+	Data    string   `parquet:"name=data, type=BYTE_ARRAY"`
+	Note    *string  `parquet:"name=note, type=UTF8, repetitiontype=OPTIONAL"`
+	Tags    []string `parquet:"name=tags, type=LIST, valuetype=UTF8"`
+	Address address  `parquet:"name=address"`
+}
+
+func writeParquet(t *testing.T, rows []row) []byte {
+	var buf bytes.Buffer
+	pw, err := writer.NewParquetWriterFromWriter(&buf, new(row), 1)
+	if err != nil {
+		t.Fatalf("failed to create writer: %v", err)
+	}
+	for _, r := range rows {
+		if err = pw.Write(r); err != nil {
+			t.Fatalf("failed to write row: %v", err)
+		}
+	}
+	if err = pw.WriteStop(); err != nil {
+		t.Fatalf("failed to finish writing: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestConverter(t *testing.T) {
+	data := writeParquet(t, []row{
+		{
+			ID:      "a",
+			Count:   3,
+			Price:   -1250,
+			Score:   0.5,
+			OK:      true,
+			Day:     18262,
+			Created: 1577836800123,
+			Data:    "\x01\x02",
+			Note:    aws.String("note"),
+			Tags:    []string{"x", "y"},
+			Address: address{City: "Leeds"},
+		},
+		{ID: "b"},
+	})
+	c, err := NewConverter(func() (io.ReadSeeker, error) { return bytes.NewReader(data), nil })
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	defer c.Close()
+	items, read, err := c.ReadBatch()
+	if err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if read != 2 {
+		t.Errorf("expected 2 reads, read %d", read)
+	}
+	expected := []map[string]*dynamodb.AttributeValue{
+		{
+			"id":      {S: aws.String("a")},
+			"count":   {N: aws.String("3")},
+			"price":   {N: aws.String("-12.50")},
+			"score":   {N: aws.String("0.5")},
+			"ok":      {BOOL: aws.Bool(true)},
+			"day":     {S: aws.String("2020-01-01")},
+			"created": {S: aws.String("2020-01-01T00:00:00.123Z")},
+			"data":    {B: []byte{1, 2}},
+			"note":    {S: aws.String("note")},
+			"tags":    {L: []*dynamodb.AttributeValue{{S: aws.String("x")}, {S: aws.String("y")}}},
+			"address": {M: map[string]*dynamodb.AttributeValue{
+				"city": {S: aws.String("Leeds")},
+			}},
+		},
+		{
+			"id":      {S: aws.String("b")},
+			"count":   {N: aws.String("0")},
+			"price":   {N: aws.String("0.00")},
+			"score":   {N: aws.String("0")},
+			"ok":      {BOOL: aws.Bool(false)},
+			"day":     {S: aws.String("1970-01-01")},
+			"created": {S: aws.String("1970-01-01T00:00:00Z")},
+			"data":    {B: []byte{}},
+			"tags":    {L: []*dynamodb.AttributeValue{}},
+			"address": {M: map[string]*dynamodb.AttributeValue{
+				"city": {S: aws.String("")},
+			}},
+		},
+	}
+	if diff := cmp.Diff(expected, items); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestConverterBatches(t *testing.T) {
+	rows := make([]row, 30)
+	data := writeParquet(t, rows)
+	c, err := NewConverter(func() (io.ReadSeeker, error) { return bytes.NewReader(data), nil })
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	defer c.Close()
+	if _, read, err := c.ReadBatch(); err != nil || read != 25 {
+		t.Fatalf("expected 25 items without error, got %d, %v", read, err)
+	}
+	if _, read, err := c.ReadBatch(); err != io.EOF || read != 5 {
+		t.Fatalf("expected 5 items and EOF, got %d, %v", read, err)
+	}
+	if _, read, err := c.ReadBatch(); err != io.EOF || read != 0 {
+		t.Fatalf("expected no items and EOF, got %d, %v", read, err)
+	}
+}
+
+func TestInt96(t *testing.T) {
+	// 2020-01-01T01:00:00Z is Julian day 2458850, 3600 seconds into the day.
+	b := make([]byte, 12)
+	nanos := uint64(3600 * 1e9)
+	for i := 0; i < 8; i++ {
+		b[i] = byte(nanos >> (8 * i))
+	}
+	day := uint32(2458850)
+	for i := 0; i < 4; i++ {
+		b[8+i] = byte(day >> (8 * i))
+	}
+	av, err := int96Value(string(b))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff("2020-01-01T01:00:00Z", aws.StringValue(av.S)); diff != "" {
+		t.Error(diff)
+	}
+}
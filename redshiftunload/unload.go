@@ -0,0 +1,130 @@
+// Package redshiftunload unloads the results of a Redshift query to S3 using the Redshift Data
+// API, in a CSV format that can be imported.
+package redshiftunload
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/redshiftdataapiservice"
+	"github.com/aws/aws-sdk-go/service/redshiftdataapiservice/redshiftdataapiserviceiface"
+)
+
+// Options for the UNLOAD statement.
+type Options struct {
+	// ClusterIdentifier of the Redshift cluster.
+	ClusterIdentifier string
+	// Database to run the query against.
+	Database string
+	// DbUser to connect as, using temporary credentials. Either DbUser or SecretARN is required.
+	DbUser string
+	// SecretARN of a Secrets Manager secret containing the database credentials.
+	SecretARN string
+	// IAMRole is the ARN of the role Redshift uses to write to S3.
+	IAMRole string
+	// PollInterval is the time between checks of the status of the statement.
+	PollInterval time.Duration
+}
+
+// Statement returns an UNLOAD statement which writes the results of the query to the S3 prefix
+// as gzipped CSV files, each with its own header row, along with a manifest listing the files.
+func Statement(query, prefix, iamRole string) string {
+	return fmt.Sprintf("UNLOAD (%s) TO %s IAM_ROLE %s FORMAT AS CSV HEADER GZIP MANIFEST ALLOWOVERWRITE",
+		quote(query), quote(prefix), quote(iamRole))
+}
+
+// quote a string literal. Single quotes within the literal are doubled.
+func quote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// ManifestKey returns the key of the manifest written by UNLOAD to the prefix.
+func ManifestKey(prefix string) string {
+	return prefix + "manifest"
+}
+
+// Unload the results of the query to the S3 prefix, e.g. s3://bucket/prefix/, and wait for
+// the statement to complete.
+func Unload(ctx context.Context, client redshiftdataapiserviceiface.RedshiftDataAPIServiceAPI, query, prefix string, opts Options) error {
+	input := &redshiftdataapiservice.ExecuteStatementInput{
+		ClusterIdentifier: aws.String(opts.ClusterIdentifier),
+		Database:          aws.String(opts.Database),
+		Sql:               aws.String(Statement(query, prefix, opts.IAMRole)),
+		StatementName:     aws.String("ddbimport"),
+	}
+	if opts.DbUser != "" {
+		input.DbUser = aws.String(opts.DbUser)
+	}
+	if opts.SecretARN != "" {
+		input.SecretArn = aws.String(opts.SecretARN)
+	}
+	eso, err := client.ExecuteStatementWithContext(ctx, input)
+	if err != nil {
+		return err
+	}
+	for {
+		dso, err := client.DescribeStatementWithContext(ctx, &redshiftdataapiservice.DescribeStatementInput{
+			Id: eso.Id,
+		})
+		if err != nil {
+			return err
+		}
+		switch aws.StringValue(dso.Status) {
+		case redshiftdataapiservice.StatusStringFinished:
+			return nil
+		case redshiftdataapiservice.StatusStringFailed, redshiftdataapiservice.StatusStringAborted:
+			return fmt.Errorf("redshiftunload: statement %s: %s", strings.ToLower(aws.StringValue(dso.Status)), aws.StringValue(dso.Error))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(opts.PollInterval):
+		}
+	}
+}
+
+// Object in S3.
+type Object struct {
+	Bucket string
+	Key    string
+}
+
+type manifest struct {
+	Entries []struct {
+		URL string `json:"url"`
+	} `json:"entries"`
+}
+
+// ReadManifest returns the objects listed in an UNLOAD manifest.
+func ReadManifest(r io.Reader) (objects []Object, err error) {
+	var m manifest
+	if err = json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("redshiftunload: invalid manifest: %w", err)
+	}
+	for _, e := range m.Entries {
+		bucket, key, err := ParseURL(e.URL)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, Object{Bucket: bucket, Key: key})
+	}
+	return
+}
+
+// ParseURL returns the bucket and key of an S3 URL, e.g. s3://bucket/key.
+func ParseURL(s string) (bucket, key string, err error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return
+	}
+	if u.Scheme != "s3" || u.Host == "" {
+		return "", "", fmt.Errorf("redshiftunload: invalid S3 URL %q", s)
+	}
+	return u.Host, strings.TrimPrefix(u.Path, "/"), nil
+}
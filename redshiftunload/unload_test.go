@@ -0,0 +1,110 @@
+package redshiftunload
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/redshiftdataapiservice"
+	"github.com/aws/aws-sdk-go/service/redshiftdataapiservice/redshiftdataapiserviceiface"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStatement(t *testing.T) {
+	actual := Statement("select * from films where genre = 'drama'", "s3://bucket/films/", "arn:aws:iam::123456789012:role/unload")
+	expected := "UNLOAD ('select * from films where genre = ''drama''') TO 's3://bucket/films/' IAM_ROLE 'arn:aws:iam::123456789012:role/unload' FORMAT AS CSV HEADER GZIP MANIFEST ALLOWOVERWRITE"
+	if actual != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	input := `{
+  "entries": [
+    {"url":"s3://bucket/films/0000_part_00.gz"},
+    {"url":"s3://bucket/films/0001_part_00.gz"}
+  ]
+}`
+	actual, err := ReadManifest(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	expected := []Object{
+		{Bucket: "bucket", Key: "films/0000_part_00.gz"},
+		{Bucket: "bucket", Key: "films/0001_part_00.gz"},
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	if _, _, err := ParseURL("https://bucket/key"); err == nil {
+		t.Error("expected an error for a non-S3 URL")
+	}
+	bucket, key, err := ParseURL("s3://bucket/prefix/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bucket != "bucket" || key != "prefix/" {
+		t.Errorf("expected bucket/prefix/, got %s/%s", bucket, key)
+	}
+}
+
+type fakeClient struct {
+	redshiftdataapiserviceiface.RedshiftDataAPIServiceAPI
+	executed *redshiftdataapiservice.ExecuteStatementInput
+	statuses []string
+}
+
+func (c *fakeClient) ExecuteStatementWithContext(ctx aws.Context, input *redshiftdataapiservice.ExecuteStatementInput, opts ...request.Option) (*redshiftdataapiservice.ExecuteStatementOutput, error) {
+	c.executed = input
+	return &redshiftdataapiservice.ExecuteStatementOutput{Id: aws.String("id")}, nil
+}
+
+func (c *fakeClient) DescribeStatementWithContext(ctx aws.Context, input *redshiftdataapiservice.DescribeStatementInput, opts ...request.Option) (*redshiftdataapiservice.DescribeStatementOutput, error) {
+	status := c.statuses[0]
+	c.statuses = c.statuses[1:]
+	return &redshiftdataapiservice.DescribeStatementOutput{Id: input.Id, Status: aws.String(status), Error: aws.String("syntax error")}, nil
+}
+
+func TestUnload(t *testing.T) {
+	var tests = []struct {
+		name        string
+		statuses    []string
+		expectedErr string
+	}{
+		{
+			name:     "waits for the statement to finish",
+			statuses: []string{"SUBMITTED", "STARTED", "FINISHED"},
+		},
+		{
+			name:        "returns the error of failed statements",
+			statuses:    []string{"STARTED", "FAILED"},
+			expectedErr: "redshiftunload: statement failed: syntax error",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{statuses: tt.statuses}
+			opts := Options{ClusterIdentifier: "cluster", Database: "db", DbUser: "user"}
+			err := Unload(context.Background(), client, "select 1", "s3://bucket/prefix/", opts)
+			var actualErr string
+			if err != nil {
+				actualErr = err.Error()
+			}
+			if actualErr != tt.expectedErr {
+				t.Errorf("expected error %q, got %q", tt.expectedErr, actualErr)
+			}
+			if aws.StringValue(client.executed.DbUser) != "user" || client.executed.SecretArn != nil {
+				t.Errorf("unexpected credentials: %v", client.executed)
+			}
+			if len(client.statuses) != 0 {
+				t.Errorf("expected all statuses to be read, %d remain", len(client.statuses))
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Package replay records the batches of an import, so that exactly the same batches can be
+// written again later, e.g. into another table, for auditing and debugging.
+package replay
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+
+	"github.com/a-h/ddbimport/dynamoexport"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// maxLineSize allows for a batch of 25 items of DynamoDB's maximum size, and the overhead of
+// JSON encoding.
+const maxLineSize = 25 * 4 * 1024 * 1024
+
+// ErrOutOfSequence is returned by ReadBatch when the batches of a recording aren't numbered in
+// sequence, e.g. because the recording was edited.
+var ErrOutOfSequence = errors.New("replay: batch out of sequence")
+
+// Recorder writes batches to a recording, one per line, as DynamoDB JSON. The encoding is
+// deterministic, so recording the same batches produces the same bytes. It is safe for
+// concurrent use.
+type Recorder struct {
+	m       sync.Mutex
+	w       *bufio.Writer
+	h       hash.Hash
+	batches int64
+	items   int64
+}
+
+// NewRecorder creates a Recorder which writes to w.
+func NewRecorder(w io.Writer) *Recorder {
+	h := sha256.New()
+	return &Recorder{
+		w: bufio.NewWriter(io.MultiWriter(w, h)),
+		h: h,
+	}
+}
+
+type recordedBatch struct {
+	Batch int64                    `json:"batch"`
+	Items []map[string]interface{} `json:"items"`
+}
+
+// Record the batch.
+func (r *Recorder) Record(batch []map[string]*dynamodb.AttributeValue) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	rb := recordedBatch{
+		Batch: r.batches + 1,
+		Items: make([]map[string]interface{}, len(batch)),
+	}
+	for i, item := range batch {
+		rb.Items[i] = dynamoexport.DynamoDBJSON(item)
+	}
+	line, err := json.Marshal(rb)
+	if err != nil {
+		return err
+	}
+	if _, err = r.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	r.batches++
+	r.items += int64(len(batch))
+	return nil
+}
+
+// Flush the recording.
+func (r *Recorder) Flush() error {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.w.Flush()
+}
+
+// Stats returns the number of batches and items recorded.
+func (r *Recorder) Stats() (batches, items int64) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.batches, r.items
+}
+
+// Sum returns the hex encoded SHA-256 hash of the recording written so far. Flush must be
+// called first.
+func (r *Recorder) Sum() string {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return hex.EncodeToString(r.h.Sum(nil))
+}
+
+// Reader reads the batches of a recording.
+type Reader struct {
+	s     *bufio.Scanner
+	h     hash.Hash
+	batch int64
+}
+
+// NewReader creates a Reader which reads a recording from r.
+func NewReader(r io.Reader) *Reader {
+	h := sha256.New()
+	s := bufio.NewScanner(io.TeeReader(r, h))
+	s.Buffer(make([]byte, 64*1024), maxLineSize)
+	return &Reader{s: s, h: h}
+}
+
+// ReadBatch reads the next batch. io.EOF is returned when there are no more batches.
+func (r *Reader) ReadBatch() (batch []map[string]*dynamodb.AttributeValue, err error) {
+	if !r.s.Scan() {
+		if err = r.s.Err(); err != nil {
+			return nil, fmt.Errorf("replay: batch %d: %w", r.batch+1, err)
+		}
+		return nil, io.EOF
+	}
+	var rb struct {
+		Batch int64                                 `json:"batch"`
+		Items []map[string]*dynamodb.AttributeValue `json:"items"`
+	}
+	if err = json.Unmarshal(r.s.Bytes(), &rb); err != nil {
+		return nil, fmt.Errorf("replay: batch %d: %w", r.batch+1, err)
+	}
+	if rb.Batch != r.batch+1 {
+		return nil, fmt.Errorf("%w: expected batch %d, got %d", ErrOutOfSequence, r.batch+1, rb.Batch)
+	}
+	r.batch++
+	return rb.Items, nil
+}
+
+// Sum returns the hex encoded SHA-256 hash of the recording read so far. Once ReadBatch has
+// returned io.EOF, it is the hash of the whole recording, which matches the Sum of the Recorder
+// that wrote it.
+func (r *Reader) Sum() string {
+	return hex.EncodeToString(r.h.Sum(nil))
+}
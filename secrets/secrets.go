@@ -0,0 +1,38 @@
+// Package secrets reads secrets from SSM Parameter Store, so that they aren't passed in plain
+// text, e.g. in the input of a Step Function execution, which is kept in its history.
+package secrets
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+var m sync.Mutex
+var cache = make(map[string]string)
+
+// Parameter returns the decrypted value of the SSM parameter, usually a SecureString. Values
+// are cached, so that each partition imported by a Lambda doesn't read the parameter again.
+func Parameter(client ssmiface.SSMAPI, name string) (value string, err error) {
+	m.Lock()
+	defer m.Unlock()
+	if value, ok := cache[name]; ok {
+		return value, nil
+	}
+	gpo, err := client.GetParameter(&ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", fmt.Errorf("secrets: failed to get parameter %q: %w", name, err)
+	}
+	if gpo.Parameter == nil || aws.StringValue(gpo.Parameter.Value) == "" {
+		return "", fmt.Errorf("secrets: parameter %q is empty", name)
+	}
+	value = aws.StringValue(gpo.Parameter.Value)
+	cache[name] = value
+	return value, nil
+}
@@ -0,0 +1,50 @@
+package secrets
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+type fakeSSM struct {
+	ssmiface.SSMAPI
+	values map[string]string
+	calls  int
+}
+
+func (f *fakeSSM) GetParameter(input *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
+	f.calls++
+	if !aws.BoolValue(input.WithDecryption) {
+		return &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String("encrypted")}}, nil
+	}
+	v, ok := f.values[aws.StringValue(input.Name)]
+	if !ok {
+		return nil, awserr.New(ssm.ErrCodeParameterNotFound, "not found", nil)
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssm.Parameter{Value: aws.String(v)}}, nil
+}
+
+func TestParameter(t *testing.T) {
+	client := &fakeSSM{values: map[string]string{"/ddbimport/seed": "secret", "/ddbimport/empty": ""}}
+	for i := 0; i < 2; i++ {
+		value, err := Parameter(client, "/ddbimport/seed")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if value != "secret" {
+			t.Errorf("expected the decrypted value, got %q", value)
+		}
+	}
+	if client.calls != 1 {
+		t.Errorf("expected the value to be cached, got %d calls", client.calls)
+	}
+	if _, err := Parameter(client, "/ddbimport/missing"); err == nil {
+		t.Error("expected an error for a missing parameter")
+	}
+	if _, err := Parameter(client, "/ddbimport/empty"); err == nil {
+		t.Error("expected an error for an empty parameter")
+	}
+}
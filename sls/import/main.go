@@ -1,10 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
+	"os"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -12,35 +15,34 @@ import (
 	"github.com/a-h/ddbimport/batchwriter"
 	"github.com/a-h/ddbimport/csvtodynamo"
 	"github.com/a-h/ddbimport/log"
+	"github.com/a-h/ddbimport/secrets"
+	"github.com/a-h/ddbimport/sls/linereader"
 	"github.com/a-h/ddbimport/sls/state"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/s3"
-	"go.uber.org/zap"
+	"github.com/aws/aws-sdk-go/service/ssm"
 )
 
-// Response from the Lambda.
-type Response struct {
-	ProcessedCount int64 `json:"processedCount"`
-	DurationMS     int64 `json:"durationMs"`
-}
-
-func Handler(ctx context.Context, req state.ImportInput) (resp Response, err error) {
-	logger := log.Default.With(zap.String("sourceRegion", req.Source.Region),
-		zap.String("sourceBucket", req.Source.Bucket),
-		zap.String("sourceKey", req.Source.Key),
-		zap.String("tableRegion", req.Target.Region),
-		zap.String("tableName", req.Target.TableName),
-		zap.Int64("sourceFromRange", req.Range[0]),
-		zap.Int64("sourceToRange", req.Range[1]))
-	logger.Info("starting", zap.Strings("numericFields", req.Source.NumericFields),
-		zap.Strings("booleanFields", req.Source.BooleanFields),
-		zap.Strings("mapFields", req.Source.MapFields),
-		zap.Strings("binaryFields", req.Source.BinaryFields),
-		zap.Strings("cols", req.Columns),
-		zap.String("delimiter", req.Source.Delimiter))
+func Handler(ctx context.Context, req state.ImportInput) (resp state.Output, err error) {
+	resp.Version = state.Version
+	resp.Range = req.Range[:2]
+	logger := log.Default.With(log.String("sourceRegion", req.Source.Region),
+		log.String("sourceBucket", req.Source.Bucket),
+		log.String("sourceKey", req.Source.Key),
+		log.String("tableRegion", req.Target.Region),
+		log.String("tableName", req.Target.TableName),
+		log.Int64("sourceFromRange", req.Range[0]),
+		log.Int64("sourceToRange", req.Range[1]),
+		log.Any("tags", req.Tags))
+	logger.Info("starting", log.Strings("numericFields", req.Source.NumericFields),
+		log.Strings("booleanFields", req.Source.BooleanFields),
+		log.Strings("mapFields", req.Source.MapFields),
+		log.Strings("binaryFields", req.Source.BinaryFields),
+		log.Strings("cols", req.Columns),
+		log.String("delimiter", req.Source.Delimiter))
 
 	start := time.Now()
 	var duration time.Duration
@@ -60,9 +62,14 @@ func Handler(ctx context.Context, req state.ImportInput) (resp Response, err err
 		return
 	}
 
-	// Parse the CSV data.
-	csvr := csv.NewReader(src)
-	csvr.Comma = rune(req.Source.Delimiter[0])
+	// Parse the CSV data, keeping track of the position within the whole file for errors.
+	var startLine int64
+	if len(req.Range) > 2 {
+		startLine = req.Range[2]
+	}
+	lr := linereader.New(src, startLine, req.Range[0], nil)
+	csvr := csv.NewReader(lr)
+	csvr.Comma = req.Source.Comma()
 	conf := csvtodynamo.NewConfiguration()
 	if req.Range[0] > 0 {
 		csvr.FieldsPerRecord = len(req.Columns)
@@ -72,17 +79,46 @@ func Handler(ctx context.Context, req state.ImportInput) (resp Response, err err
 	conf.AddBoolKeys(req.Source.BooleanFields...)
 	conf.AddMapKeys(req.Source.MapFields...)
 	conf.AddBinKeys(req.Source.BinaryFields...)
+	conf.AddExcludedColumns(req.Source.ExcludedFields...)
+	conf.SampleRate = req.Source.SampleRate
+	conf.SampleEvery = req.Source.SampleEvery
+	conf.SampleSeed = req.Range[0]
+	conf.RawAttribute = req.Source.RawAttribute
+	conf.RowHashAttribute = req.Source.RowHashAttribute
+	conf.SkipRepeatedHeaders = req.Source.SkipRepeatedHeaders
+	var seed string
+	if len(req.Source.AnonymizedFields) > 0 {
+		if seed, err = anonymizeSeed(req.Source.AnonymizeSeedParameter); err != nil {
+			logger.Error("failed to get the anonymize seed", log.Error(err))
+			return
+		}
+	}
+	for field, faker := range req.Source.AnonymizedFields {
+		if _, err = conf.AddAnonymizedKeys(seed, faker, field); err != nil {
+			logger.Error("failed to configure anonymization", log.Error(err))
+			return
+		}
+	}
 	reader, err := csvtodynamo.NewConverter(csvr, conf)
 	if err != nil {
-		logger.Error("failed to create CSV reader", zap.Error(err))
+		logger.Error("failed to create CSV reader", log.Error(err))
 		return
 	}
+	reader.TrackPosition(lr)
 	bw, err := batchwriter.New(req.Target.Region, req.Target.TableName)
 	if err != nil {
-		logger.Error("failed to create batch writer", zap.Error(err))
+		logger.Error("failed to create batch writer", log.Error(err))
 		return
 	}
 
+	// Share the provisioned capacity of the table with the other import Lambdas, so that
+	// together they don't throttle the table.
+	var budget *batchwriter.RateLimiter
+	if req.WCUBudget > 0 {
+		budget = batchwriter.NewRateLimiter(req.WCUBudget)
+		logger.Info("limiting write capacity", log.Float64("wcuBudget", req.WCUBudget))
+	}
+
 	var recordCount int64
 
 	// Start up workers.
@@ -95,9 +131,12 @@ func Handler(ctx context.Context, req state.ImportInput) (resp Response, err err
 		go func() {
 			defer wg.Done()
 			for batch := range batches {
+				if budget != nil {
+					budget.Wait(batchwriter.WriteUnits(batch))
+				}
 				err := bw.Write(batch)
 				if err != nil {
-					logger.Error("error executing batch put", zap.Error(err))
+					logger.Error("error executing batch put", log.Error(err))
 					errors = append(errors, err)
 					cancel()
 					return
@@ -105,8 +144,8 @@ func Handler(ctx context.Context, req state.ImportInput) (resp Response, err err
 				if recordCount := atomic.AddInt64(&recordCount, int64(len(batch))); recordCount%10000 == 0 {
 					duration = time.Since(start)
 					logger.Info("progress update",
-						zap.Int64("records", recordCount),
-						zap.Int("rps", int(float64(recordCount)/duration.Seconds())))
+						log.Int64("records", recordCount),
+						log.Int("rps", int(float64(recordCount)/duration.Seconds())))
 				}
 				select {
 				case <-ctx.Done():
@@ -122,7 +161,7 @@ fillJobQueue:
 	for {
 		batch, read, err := reader.ReadBatch()
 		if err != nil && err != io.EOF {
-			logger.Error("failed to read batch, closing down", zap.Error(err))
+			logger.Error("failed to read batch, closing down", log.Error(err))
 			cancel()
 			wg.Wait()
 			return resp, err
@@ -146,17 +185,48 @@ fillJobQueue:
 	cancel()
 	duration = time.Since(start)
 	if len(errors) > 0 {
-		logger.Error("batch execution failed", zap.Errors("errors", errors))
+		logger.Error("batch execution failed", log.Errors("errors", errors))
 		err = errors[0]
 		return
 	}
-	logger.Info("complete")
+	logger.Info("complete",
+		log.Float64("consumedWCU", bw.Capacity.Table()),
+		log.Any("indexConsumedWCU", bw.Capacity.Indexes()))
 
 	resp.ProcessedCount = recordCount
 	resp.DurationMS = time.Now().Sub(start).Milliseconds()
+	// The results of the execution would be missing this partition without its output, so fail.
+	if err = putOutput(req, resp); err != nil {
+		logger.Error("failed to write output to the results bucket", log.Error(err))
+	}
 	return
 }
 
+// putOutput writes the output to the results bucket, so that the results of executions with
+// thousands of partitions don't exceed the Step Functions output limit. The Step Function
+// discards the output returned by each import Lambda.
+func putOutput(req state.ImportInput, resp state.Output) error {
+	bucket := os.Getenv(state.ResultsBucketEnv)
+	if bucket == "" || req.Execution == "" {
+		return nil
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	sess, err := session.NewSession()
+	if err != nil {
+		return err
+	}
+	_, err = s3.New(sess).PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(state.PartitionKey(req.Execution, req.Range[0])),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	return err
+}
+
 func get(region, bucket, key string, from, to int64) (io.ReadCloser, error) {
 	sess, err := session.NewSession(&aws.Config{
 		Region: aws.String(region),
@@ -173,6 +243,15 @@ func get(region, bucket, key string, from, to int64) (io.ReadCloser, error) {
 	return goo.Body, err
 }
 
+// anonymizeSeed reads the seed from the SSM parameter, in the region of the Lambda.
+func anonymizeSeed(name string) (string, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return "", err
+	}
+	return secrets.Parameter(ssm.New(sess), name)
+}
+
 func main() {
 	lambda.Start(Handler)
 }
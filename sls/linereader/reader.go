@@ -2,9 +2,18 @@ package linereader
 
 import (
 	"bufio"
+	"fmt"
 	"io"
 )
 
+// DefaultMaxLineLength is the maximum length of a line, in bytes, unless another is configured.
+// DynamoDB items can't be larger than 400KB, so longer lines are almost always a file with the
+// wrong line endings, or one that isn't CSV at all.
+const DefaultMaxLineLength = 1024 * 1024
+
+// sniffLength is the number of bytes at the start of the source checked by IsText.
+const sniffLength = 512
+
 // New creates a new LineReader. A reader that keeps track of the line positions within the source.
 func New(r io.Reader, startLine, startOffset int64, onNewLine func(line, offset int64)) *LineReader {
 	return &LineReader{
@@ -15,16 +24,61 @@ func New(r io.Reader, startLine, startOffset int64, onNewLine func(line, offset
 	}
 }
 
-// LineReader keeps track of how many lines have been read.
+// LineReader keeps track of how many lines have been read. If it starts at the beginning of the
+// source, it returns ErrNotText if the first bytes aren't text.
 type LineReader struct {
 	r                     *bufio.Reader
 	remainder             []byte
 	bytesSinceLastNewLine int
 	eof                   bool
+	sniffed               bool
 	Line                  int64
 	Offset                int64
-	onNewLine             func(line, pos int64)
-	d                     []byte
+	// MaxLineLength is the maximum length of a line in bytes. Longer lines return an
+	// ErrLineTooLong, instead of being read into memory. Zero is unlimited.
+	MaxLineLength int
+	onNewLine     func(line, pos int64)
+	d             []byte
+}
+
+// ErrLineTooLong is returned when a line is longer than the MaxLineLength of the LineReader.
+type ErrLineTooLong struct {
+	// Line is the line number, starting at 1.
+	Line int64
+	// Offset is the byte offset of the start of the line.
+	Offset        int64
+	MaxLineLength int
+}
+
+func (e ErrLineTooLong) Error() string {
+	return fmt.Sprintf("line %d at byte offset %d is longer than the maximum of %d bytes, check the delimiter and line endings of the file", e.Line, e.Offset, e.MaxLineLength)
+}
+
+// ErrNotText is returned when the start of the source doesn't look like text, e.g. because it's
+// compressed or binary.
+type ErrNotText struct {
+	FirstBytes []byte
+}
+
+func (e ErrNotText) Error() string {
+	return fmt.Sprintf("this doesn't look like CSV; first bytes are %q", e.FirstBytes)
+}
+
+// IsText returns false if b contains a NUL byte, or if more than 1 in 10 of its bytes are control
+// characters other than whitespace, which text files don't have.
+func IsText(b []byte) bool {
+	var control int
+	for _, c := range b {
+		switch {
+		case c == 0:
+			return false
+		case c == '\t' || c == '\n' || c == '\v' || c == '\f' || c == '\r':
+			continue
+		case c < 0x20 || c == 0x7f:
+			control++
+		}
+	}
+	return control*10 <= len(b)
 }
 
 func (lr *LineReader) Read(p []byte) (n int, err error) {
@@ -34,7 +88,7 @@ func (lr *LineReader) Read(p []byte) (n int, err error) {
 	if len(lr.remainder) > 0 {
 		lr.d = lr.remainder
 	} else {
-		lr.d, err = lr.r.ReadBytes('\n')
+		err = lr.readLine()
 		if err != nil {
 			if err != io.EOF {
 				return
@@ -63,3 +117,38 @@ func (lr *LineReader) Read(p []byte) (n int, err error) {
 	}
 	return
 }
+
+// readLine reads the next line into d, a buffer at a time, so that the MaxLineLength is checked
+// before the whole line is in memory.
+func (lr *LineReader) readLine() (err error) {
+	lr.d = lr.d[:0]
+	for {
+		var buf []byte
+		buf, err = lr.r.ReadSlice('\n')
+		if !lr.sniffed {
+			lr.sniffed = true
+			if lr.Offset == 0 && !IsText(head(buf, sniffLength)) {
+				return ErrNotText{FirstBytes: append([]byte{}, head(buf, 16)...)}
+			}
+		}
+		lr.d = append(lr.d, buf...)
+		if lr.MaxLineLength > 0 && len(lr.d) > lr.MaxLineLength {
+			return ErrLineTooLong{Line: lr.Line + 1, Offset: lr.Offset, MaxLineLength: lr.MaxLineLength}
+		}
+		if err != bufio.ErrBufferFull {
+			return
+		}
+	}
+}
+
+func head(b []byte, n int) []byte {
+	if len(b) < n {
+		return b
+	}
+	return b[:n]
+}
+
+// Position returns the number of lines and bytes read, including the start line and offset.
+func (lr *LineReader) Position() (lines, offset int64) {
+	return lr.Line, lr.Offset
+}
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math/rand"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -81,3 +83,65 @@ func generateLine(min, max int) []byte {
 	}
 	return buf.Bytes()
 }
+
+func TestLineReaderMaxLineLength(t *testing.T) {
+	src := "a,b\n1,2\n" + strings.Repeat("x", 100) + "\n3,4\n"
+	lr := New(strings.NewReader(src), 0, 0, nil)
+	lr.MaxLineLength = 50
+	_, err := ioutil.ReadAll(lr)
+	expected := ErrLineTooLong{Line: 3, Offset: 8, MaxLineLength: 50}
+	if diff := cmp.Diff(expected, err); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestLineReaderMaxLineLengthAllowsLinesUpToTheMaximum(t *testing.T) {
+	src := "a,b\n" + strings.Repeat("x", 49) + "\n"
+	lr := New(strings.NewReader(src), 0, 0, nil)
+	lr.MaxLineLength = 50
+	actual, err := ioutil.ReadAll(lr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(src, string(actual)); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestLineReaderNotText(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      []byte
+		expected error
+	}{
+		{
+			name:     "gzip",
+			src:      []byte{0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 'a', '\n'},
+			expected: ErrNotText{FirstBytes: []byte{0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff, 'a', '\n'}},
+		},
+		{
+			name:     "control characters",
+			src:      []byte("\x01\x02\x03\x04abcdef"),
+			expected: ErrNotText{FirstBytes: []byte("\x01\x02\x03\x04abcdef")},
+		},
+		{
+			name: "text",
+			src:  []byte("name,\tcity\r\nAlice,Zürich\r\n"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ioutil.ReadAll(New(bytes.NewReader(test.src), 0, 0, nil))
+			if diff := cmp.Diff(test.expected, err); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestLineReaderOnlyChecksTheStartOfTheSource(t *testing.T) {
+	lr := New(strings.NewReader("\x00\x01\x02\n"), 5, 100, nil)
+	if _, err := ioutil.ReadAll(lr); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -6,27 +6,34 @@ import (
 	"io"
 	"time"
 
+	"github.com/a-h/ddbimport/batchwriter"
 	"github.com/a-h/ddbimport/log"
+	"github.com/a-h/ddbimport/sls/linereader"
 	"github.com/a-h/ddbimport/sls/preflight/process"
 	"github.com/a-h/ddbimport/sls/state"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/s3"
-	"go.uber.org/zap"
 )
 
 func Handler(ctx context.Context, req state.State) (resp state.State, err error) {
-	logger := log.Default.With(zap.String("sourceRegion", req.Source.Region),
-		zap.String("sourceBucket", req.Source.Bucket),
-		zap.String("sourceKey", req.Source.Key),
-		zap.String("tableRegion", req.Target.Region),
-		zap.String("tableName", req.Target.TableName))
-	logger.Info("starting", zap.Strings("numericFields", req.Source.NumericFields),
-		zap.Strings("booleanFields", req.Source.BooleanFields),
-		zap.Strings("mapFields", req.Source.MapFields),
-		zap.Strings("binFields", req.Source.BinaryFields),
-		zap.String("delimiter", req.Source.Delimiter))
+	// Import the results of the Athena query, if one was run.
+	if req, err = process.AthenaSource(req); err != nil {
+		return
+	}
+	logger := log.Default.With(log.String("sourceRegion", req.Source.Region),
+		log.String("sourceBucket", req.Source.Bucket),
+		log.String("sourceKey", req.Source.Key),
+		log.String("tableRegion", req.Target.Region),
+		log.String("tableName", req.Target.TableName),
+		log.Any("tags", req.Tags))
+	logger.Info("starting", log.Strings("numericFields", req.Source.NumericFields),
+		log.Strings("booleanFields", req.Source.BooleanFields),
+		log.Strings("mapFields", req.Source.MapFields),
+		log.Strings("binFields", req.Source.BinaryFields),
+		log.String("delimiter", req.Source.Delimiter))
 
 	if req.Source.Delimiter == "" {
 		req.Source.Delimiter = ","
@@ -34,6 +41,21 @@ func Handler(ctx context.Context, req state.State) (resp state.State, err error)
 	if req.Configuration.LambdaDurationSeconds < 30 {
 		req.Configuration.LambdaDurationSeconds = 300
 	}
+	if req.Configuration.MaxLineLength <= 0 {
+		req.Configuration.MaxLineLength = linereader.DefaultMaxLineLength
+	}
+	// Size the partitions from a sample of the rows on the first run of the preflight.
+	if req.Configuration.AutoPartition && req.Preflight.Line == 0 {
+		if err = autoPartition(logger, &req); err != nil {
+			return
+		}
+	}
+	// Allocate records to workers in batches of 100,000 lines by default.
+	// 100,000 lines / 25 BatchWriteOperations = 4000 operations per allocation.
+	// At 3000 records per second, each batch is 30 seconds of work.
+	if req.Configuration.PartitionLines <= 0 {
+		req.Configuration.PartitionLines = 100000
+	}
 
 	// Get the file from S3.
 	src, srcSize, err := get(req.Source.Region, req.Source.Bucket, req.Source.Key, req.Preflight.Offset)
@@ -42,16 +64,71 @@ func Handler(ctx context.Context, req state.State) (resp state.State, err error)
 	}
 	defer src.Close()
 
-	// Allocate records to workers in batches of 100,000 lines.
-	// 100,000 lines / 25 BatchWriteOperations = 4000 operations per allocation.
-	// At 3000 records per second, each batch is 30 seconds of work.
-	var workerBatch int64 = 100000
+	// The size of the whole file is only known on the first run of the preflight.
+	if req.Configuration.MinPartitions > 0 && req.Preflight.PartitionBytes == 0 {
+		req.Preflight.PartitionBytes = process.PartitionBytes(srcSize, req.Configuration.MinPartitions)
+	}
+	logger.Info("partitioning", log.Int64("partitionLines", req.Configuration.PartitionLines),
+		log.Int64("partitionBytes", req.Preflight.PartitionBytes))
 
 	start := time.Now()
 	hasTimedOut := func() bool {
 		return time.Since(start) > req.Configuration.LambdaDurationSeconds*time.Second
 	}
-	return process.Process(logger, hasTimedOut, src, srcSize, workerBatch, req)
+	if resp, err = process.Process(logger, hasTimedOut, src, srcSize, req.Configuration.PartitionLines, req); err != nil || resp.Preflight.Continue {
+		return
+	}
+	if len(resp.Batches) > state.MaxPartitions {
+		return resp, fmt.Errorf("the file has %d partitions, but the Step Function can import at most %d, increase partitionLines", len(resp.Batches), state.MaxPartitions)
+	}
+	resp.Preflight.WCUBudget = wcuBudget(logger, resp.Target, len(resp.Batches), resp.Configuration.MaxWorkers)
+	return
+}
+
+// wcuBudget divides the provisioned write capacity of the table between the import Lambdas.
+// If the table can't be described, the import Lambdas aren't limited.
+func wcuBudget(logger log.Logger, target state.Target, partitions, workers int) float64 {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(target.Region)})
+	if err != nil {
+		logger.Warn("failed to create AWS session, write capacity won't be limited", log.Error(err))
+		return 0
+	}
+	dto, err := dynamodb.New(sess).DescribeTable(&dynamodb.DescribeTableInput{TableName: aws.String(target.TableName)})
+	if err != nil {
+		logger.Warn("failed to describe table, write capacity won't be limited", log.Error(err))
+		return 0
+	}
+	wcu := batchwriter.MinProvisionedWCU(dto.Table)
+	budget := state.WCUBudget(wcu, partitions, workers)
+	logger.Info("limiting write capacity", log.Int64("provisionedWCU", wcu),
+		log.Int("partitions", partitions),
+		log.Float64("wcuPerWorker", budget))
+	return budget
+}
+
+func autoPartition(logger log.Logger, req *state.State) error {
+	src, _, err := get(req.Source.Region, req.Source.Bucket, req.Source.Key, 0)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	lr := linereader.New(src, 0, 0, nil)
+	lr.MaxLineLength = req.Configuration.MaxLineLength
+	sample, err := process.SampleRows(lr, req.Source, 1000)
+	if err != nil {
+		return err
+	}
+	rps := req.Configuration.WorkerRecordsPerSecond
+	if rps <= 0 {
+		rps = process.DefaultWorkerRecordsPerSecond
+	}
+	req.Configuration.PartitionLines = process.AutoPartitionLines(sample, rps, process.ImportTimeout)
+	logger.Info("sampled rows", log.Int64("rows", sample.Rows),
+		log.Float64("rowBytes", sample.RowBytes),
+		log.Duration("conversionCost", sample.ConversionCost),
+		log.Float64("workerRps", rps),
+		log.Int64("partitionLines", req.Configuration.PartitionLines))
+	return nil
 }
 
 func get(region, bucket, key string, startIndex int64) (io.ReadCloser, int64, error) {
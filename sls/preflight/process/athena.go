@@ -0,0 +1,34 @@
+package process
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/a-h/ddbimport/sls/state"
+)
+
+// AthenaSource sets the Bucket and Key of the Source to the location of the Athena query
+// results, if an Athena query has been run and the Key has not already been set.
+func AthenaSource(req state.State) (resp state.State, err error) {
+	resp = req
+	if req.Source.AthenaQuery == nil || req.Source.Key != "" {
+		return
+	}
+	if req.AthenaResult == nil {
+		err = fmt.Errorf("athena query results not found in state")
+		return
+	}
+	location := req.AthenaResult.QueryExecution.ResultConfiguration.OutputLocation
+	u, err := url.Parse(location)
+	if err != nil {
+		return
+	}
+	if u.Scheme != "s3" || u.Host == "" || strings.TrimPrefix(u.Path, "/") == "" {
+		err = fmt.Errorf("unexpected athena output location %q", location)
+		return
+	}
+	resp.Source.Bucket = u.Host
+	resp.Source.Key = strings.TrimPrefix(u.Path, "/")
+	return
+}
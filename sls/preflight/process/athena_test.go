@@ -0,0 +1,66 @@
+package process
+
+import (
+	"testing"
+
+	"github.com/a-h/ddbimport/sls/state"
+)
+
+func TestAthenaSource(t *testing.T) {
+	var tests = []struct {
+		name           string
+		query          *state.AthenaQuery
+		key            string
+		outputLocation string
+		expectedBucket string
+		expectedKey    string
+		expectedErr    bool
+	}{
+		{
+			name:           "the source is unchanged if there is no query",
+			key:            "data.csv",
+			expectedKey:    "data.csv",
+			outputLocation: "s3://results/abc.csv",
+		},
+		{
+			name:           "the source is set to the output location of the query",
+			query:          &state.AthenaQuery{Query: "select 1"},
+			outputLocation: "s3://results/prefix/abc.csv",
+			expectedBucket: "results",
+			expectedKey:    "prefix/abc.csv",
+		},
+		{
+			name:           "the source is unchanged by subsequent preflights",
+			query:          &state.AthenaQuery{Query: "select 1"},
+			key:            "prefix/abc.csv",
+			outputLocation: "s3://other/def.csv",
+			expectedKey:    "prefix/abc.csv",
+		},
+		{
+			name:           "output locations must be S3 objects",
+			query:          &state.AthenaQuery{Query: "select 1"},
+			outputLocation: "s3://results",
+			expectedErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var req state.State
+			req.Source.AthenaQuery = tt.query
+			req.Source.Key = tt.key
+			req.AthenaResult = &state.AthenaResult{}
+			req.AthenaResult.QueryExecution.ResultConfiguration.OutputLocation = tt.outputLocation
+			resp, err := AthenaSource(req)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if resp.Source.Bucket != tt.expectedBucket || resp.Source.Key != tt.expectedKey {
+				t.Errorf("expected s3://%s/%s, got s3://%s/%s", tt.expectedBucket, tt.expectedKey, resp.Source.Bucket, resp.Source.Key)
+			}
+		})
+	}
+}
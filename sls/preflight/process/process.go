@@ -4,29 +4,49 @@ import (
 	"encoding/csv"
 	"io"
 
+	"github.com/a-h/ddbimport/log"
 	"github.com/a-h/ddbimport/sls/linereader"
 	"github.com/a-h/ddbimport/sls/state"
-	"go.uber.org/zap"
 )
 
-func Process(logger *zap.Logger, hasTimedOut func() bool, src io.ReadCloser, srcSize int64, batchSize int64, req state.State) (resp state.State, err error) {
+// PartitionBytes returns the maximum size of a partition which divides a file of srcSize bytes
+// into at least minPartitions partitions.
+func PartitionBytes(srcSize, minPartitions int64) int64 {
+	if minPartitions <= 0 {
+		return 0
+	}
+	if pb := srcSize / minPartitions; pb > 0 {
+		return pb
+	}
+	return 1
+}
+
+// Process divides the file into partitions of batchSize lines. If the PartitionBytes of the
+// Preflight is set, partitions are also ended when they reach that size. Each partition is the
+// byte range (from, to), and the number of lines before it, so that errors in the partition are
+// reported with line numbers within the whole file. Lines longer than the
+// MaxLineLength of the Configuration, and files which don't start with text, are an error.
+func Process(logger log.Logger, hasTimedOut func() bool, src io.ReadCloser, srcSize int64, batchSize int64, req state.State) (resp state.State, err error) {
 	resp = req
 
 	// Parse the CSV data, keeping track of the byte position in the file.
-	lines := resp.Preflight.Line
-	batchStartIndex := req.Preflight.Offset
+	var lines int64
+	batchStartIndex, batchStartLine := req.Preflight.Offset, req.Preflight.Line
+	partitionBytes := req.Preflight.PartitionBytes
 	lr := linereader.New(src, resp.Preflight.Line, resp.Preflight.Offset, func(line, offset int64) {
 		lines++
 		resp.Preflight.Line = line
 		resp.Preflight.Offset = offset
-		if lines%batchSize == 0 {
-			resp.Batches = append(resp.Batches, []int64{batchStartIndex, offset})
-			batchStartIndex = offset
+		if lines == batchSize || (partitionBytes > 0 && offset-batchStartIndex >= partitionBytes) {
+			resp.Batches = append(resp.Batches, []int64{batchStartIndex, offset, batchStartLine})
+			batchStartIndex, batchStartLine = offset, line
+			lines = 0
 		}
 	})
+	lr.MaxLineLength = req.Configuration.MaxLineLength
 
 	csvr := csv.NewReader(lr)
-	csvr.Comma = rune(resp.Source.Delimiter[0])
+	csvr.Comma = resp.Source.Comma()
 	var recordCount int64
 	for {
 		var record []string
@@ -36,7 +56,7 @@ func Process(logger *zap.Logger, hasTimedOut func() bool, src io.ReadCloser, src
 		}
 		recordCount++
 		if recordCount%50000 == 0 {
-			logger.Info("progress update", zap.Int64("records", recordCount))
+			logger.Info("progress update", log.Int64("records", recordCount))
 		}
 		if resp.Preflight.Columns == nil {
 			resp.Preflight.Columns = record
@@ -44,18 +64,19 @@ func Process(logger *zap.Logger, hasTimedOut func() bool, src io.ReadCloser, src
 		if err == io.EOF {
 			// Add trailing records.
 			if batchStartIndex != lr.Offset {
-				resp.Batches = append(resp.Batches, []int64{batchStartIndex, lr.Offset})
+				resp.Batches = append(resp.Batches, []int64{batchStartIndex, lr.Offset, batchStartLine})
 			}
 			// Stop reading, start processing.
 			resp.Preflight.Continue = false
 			err = nil
-			logger.Info("complete", zap.Int64("records", recordCount))
+			logger.Info("complete", log.Int64("records", recordCount))
 			return
 		}
 		if hasTimedOut() {
 			resp.Preflight.Offset = batchStartIndex // Carry on from the start of the current batch.
-			resp.Preflight.Continue = true          // There is more to process, we didn't reach EOF.
-			logger.Info("continuing", zap.Int64("nextStartOffset", resp.Preflight.Offset))
+			resp.Preflight.Line = batchStartLine
+			resp.Preflight.Continue = true // There is more to process, we didn't reach EOF.
+			logger.Info("continuing", log.Int64("nextStartOffset", resp.Preflight.Offset))
 			return
 		}
 	}
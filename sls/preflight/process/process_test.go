@@ -1,14 +1,16 @@
 package process
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"strings"
 	"testing"
 
+	"github.com/a-h/ddbimport/log"
+	"github.com/a-h/ddbimport/sls/linereader"
 	"github.com/a-h/ddbimport/sls/state"
 	"github.com/google/go-cmp/cmp"
-	"go.uber.org/zap"
 )
 
 func TestProcess(t *testing.T) {
@@ -21,31 +23,31 @@ func TestProcess(t *testing.T) {
 			rowCount:  0,
 			batchSize: 1,
 			expectedBatches: [][]int64{
-				{0, 6}, // Just the header.
+				{0, 6, 0}, // Just the header.
 			},
 		},
 		{
 			rowCount:  1,
 			batchSize: 1,
 			expectedBatches: [][]int64{
-				{0, 6},  // Header.
-				{6, 12}, // First row.
+				{0, 6, 0},  // Header.
+				{6, 12, 1}, // First row.
 			},
 		},
 		{
 			rowCount:  2,
 			batchSize: 2,
 			expectedBatches: [][]int64{
-				{0, 12},  // Header and first row.
-				{12, 18}, // Remainder.
+				{0, 12, 0},  // Header and first row.
+				{12, 18, 2}, // Remainder.
 			},
 		},
 		{
 			rowCount:  4,
 			batchSize: 3,
 			expectedBatches: [][]int64{
-				{0, 18},  // Header and first 2 rows (6 bytes * 3 rows).
-				{18, 30}, // Remainder (6 bytes * 2 rows).
+				{0, 18, 0},  // Header and first 2 rows (6 bytes * 3 rows).
+				{18, 30, 3}, // Remainder (6 bytes * 2 rows).
 			},
 		},
 	}
@@ -60,7 +62,7 @@ func TestProcess(t *testing.T) {
 			req.Source.Delimiter = ","
 			req.Configuration.LambdaDurationSeconds = 500
 			hasTimedOut := func() bool { return false }
-			resp, err := Process(zap.New(nil), hasTimedOut, rdr, int64(size), tt.batchSize, req)
+			resp, err := Process(log.Nop, hasTimedOut, rdr, int64(size), tt.batchSize, req)
 			if err != nil {
 				t.Error(err)
 				return
@@ -90,6 +92,7 @@ func TestProcessTimeout(t *testing.T) {
 		expectedBatches    [][]int64
 		expectedContinue   bool
 		expectedFromOffset int64
+		expectedFromLine   int64
 	}{
 		{
 			name:              "timing out results in telling the step function to continue",
@@ -97,10 +100,11 @@ func TestProcessTimeout(t *testing.T) {
 			batchSize:         2,
 			timeOutAfterNRows: 2, // Including header.
 			expectedBatches: [][]int64{
-				{0, 12}, // Headers and first row.
+				{0, 12, 0}, // Headers and first row.
 			},
 			expectedContinue:   true,
 			expectedFromOffset: 12,
+			expectedFromLine:   2,
 		},
 		{
 			name:              "timing out mid-batch results in starting from the end of the last batch",
@@ -108,10 +112,11 @@ func TestProcessTimeout(t *testing.T) {
 			batchSize:         2,
 			timeOutAfterNRows: 3,
 			expectedBatches: [][]int64{
-				{0, 12}, // Headers and first row. A single batch got processed.
+				{0, 12, 0}, // Headers and first row. A single batch got processed.
 			},
 			expectedContinue:   true,
 			expectedFromOffset: 12,
+			expectedFromLine:   2,
 		},
 		{
 			name:              "when the timeout is at the same time as the EOF, EOF wins",
@@ -119,11 +124,12 @@ func TestProcessTimeout(t *testing.T) {
 			batchSize:         2,
 			timeOutAfterNRows: 4,
 			expectedBatches: [][]int64{
-				{0, 12},
-				{12, 24},
+				{0, 12, 0},
+				{12, 24, 2},
 			},
 			expectedContinue:   true,
 			expectedFromOffset: 24,
+			expectedFromLine:   4,
 		},
 	}
 	for _, tt := range tests {
@@ -140,7 +146,7 @@ func TestProcessTimeout(t *testing.T) {
 				rowCount++
 				return rowCount >= tt.timeOutAfterNRows
 			}
-			resp, err := Process(zap.New(nil), hasTimedOut, rdr, int64(size), tt.batchSize, req)
+			resp, err := Process(log.Nop, hasTimedOut, rdr, int64(size), tt.batchSize, req)
 			if err != nil {
 				t.Error(err)
 				return
@@ -155,6 +161,71 @@ func TestProcessTimeout(t *testing.T) {
 				t.Errorf("expected continue from %v, got %v", tt.expectedFromOffset, resp.Preflight.Offset)
 				fmt.Println(len(src))
 			}
+			if resp.Preflight.Line != tt.expectedFromLine {
+				t.Errorf("expected continue from line %v, got %v", tt.expectedFromLine, resp.Preflight.Line)
+			}
 		})
 	}
 }
+
+func TestProcessPartitionBytes(t *testing.T) {
+	src := generate(5)
+	rdr := ioutil.NopCloser(strings.NewReader(src))
+	var req state.State
+	req.Source.Delimiter = ","
+	req.Preflight.PartitionBytes = PartitionBytes(int64(len(src)), 3)
+	hasTimedOut := func() bool { return false }
+	resp, err := Process(log.Nop, hasTimedOut, rdr, int64(len(src)), 100000, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 36 bytes in at least 3 partitions of up to 12 bytes.
+	expected := [][]int64{{0, 12, 0}, {12, 24, 2}, {24, 36, 4}}
+	if diff := cmp.Diff(expected, resp.Batches); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestProcessMaxLineLength(t *testing.T) {
+	src := generate(2) + strings.Repeat("x", 100) + "\n"
+	rdr := ioutil.NopCloser(strings.NewReader(src))
+	var req state.State
+	req.Source.Delimiter = ","
+	req.Configuration.MaxLineLength = 50
+	hasTimedOut := func() bool { return false }
+	_, err := Process(log.Nop, hasTimedOut, rdr, int64(len(src)), 100000, req)
+	var tooLong linereader.ErrLineTooLong
+	if !errors.As(err, &tooLong) {
+		t.Fatalf("expected a line too long error, got %v", err)
+	}
+	if tooLong.Line != 4 {
+		t.Errorf("expected line 4 to be too long, got line %d", tooLong.Line)
+	}
+}
+
+func TestProcessNotText(t *testing.T) {
+	src := "\x1f\x8b\x08\x00\x00\x00\x00\x00"
+	rdr := ioutil.NopCloser(strings.NewReader(src))
+	var req state.State
+	hasTimedOut := func() bool { return false }
+	_, err := Process(log.Nop, hasTimedOut, rdr, int64(len(src)), 100000, req)
+	var notText linereader.ErrNotText
+	if !errors.As(err, &notText) {
+		t.Fatalf("expected a not text error, got %v", err)
+	}
+}
+
+func TestPartitionBytes(t *testing.T) {
+	var tests = []struct {
+		srcSize, minPartitions, expected int64
+	}{
+		{srcSize: 1000, minPartitions: 0, expected: 0},
+		{srcSize: 1000, minPartitions: 10, expected: 100},
+		{srcSize: 5, minPartitions: 10, expected: 1},
+	}
+	for _, tt := range tests {
+		if actual := PartitionBytes(tt.srcSize, tt.minPartitions); actual != tt.expected {
+			t.Errorf("PartitionBytes(%d, %d): expected %d, got %d", tt.srcSize, tt.minPartitions, tt.expected, actual)
+		}
+	}
+}
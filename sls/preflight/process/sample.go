@@ -0,0 +1,128 @@
+package process
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/a-h/ddbimport/csvtodynamo"
+	"github.com/a-h/ddbimport/sls/state"
+)
+
+// ImportTimeout is the timeout of the import Lambda, set in serverless.yml.
+const ImportTimeout = 900 * time.Second
+
+// DefaultWorkerRecordsPerSecond is the write rate of each import Lambda used to size partitions,
+// if the Configuration doesn't set one.
+const DefaultWorkerRecordsPerSecond = 3000
+
+// Sample is the average width and conversion cost of the rows at the start of a file.
+type Sample struct {
+	Rows int64
+	// RowBytes is the average size of a row, including the line ending.
+	RowBytes float64
+	// ConversionCost is the average time taken to parse a row and convert it to a DynamoDB
+	// record.
+	ConversionCost time.Duration
+}
+
+// SampleRows reads up to maxRows records after the header, and converts them using the field
+// types of the Source. Quoted values may contain newlines, so a record can span several lines.
+// A partial record at the end of the reader, e.g. when it's the start of a file, is ignored.
+func SampleRows(r io.Reader, src state.Source, maxRows int64) (s Sample, err error) {
+	br := bufio.NewReader(r)
+	header, _, err := readRecord(br)
+	if err != nil && err != io.EOF {
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString(header)
+	var records, bytes int64
+	for records < maxRows && err == nil {
+		var record string
+		var complete bool
+		record, complete, err = readRecord(br)
+		if record != "" && complete {
+			sb.WriteString(record)
+			records++
+			bytes += int64(len(record))
+		}
+	}
+	if err != nil && err != io.EOF {
+		return
+	}
+	err = nil
+	if records == 0 {
+		return
+	}
+
+	csvr := csv.NewReader(strings.NewReader(sb.String()))
+	csvr.Comma = src.Comma()
+	conf := csvtodynamo.NewConfiguration()
+	conf.AddNumberKeys(src.NumericFields...)
+	conf.AddBoolKeys(src.BooleanFields...)
+	conf.AddMapKeys(src.MapFields...)
+	conf.AddBinKeys(src.BinaryFields...)
+	start := time.Now()
+	c, err := csvtodynamo.NewConverter(csvr, conf)
+	if err != nil {
+		return
+	}
+	for {
+		if _, err = c.Read(); err != nil {
+			break
+		}
+		s.Rows++
+	}
+	if err != io.EOF {
+		return
+	}
+	err = nil
+	if s.Rows > 0 {
+		s.RowBytes = float64(bytes) / float64(records)
+		s.ConversionCost = time.Since(start) / time.Duration(s.Rows)
+	}
+	return
+}
+
+// readRecord reads lines until the quotes are balanced, so that newlines in quoted values don't
+// end the record. The record is incomplete if the reader ended inside a quoted value.
+func readRecord(br *bufio.Reader) (record string, complete bool, err error) {
+	var sb strings.Builder
+	var quotes int
+	for {
+		var line string
+		line, err = br.ReadString('\n')
+		sb.WriteString(line)
+		// Escaped quotes are doubled, so they don't change whether the value is quoted.
+		quotes += strings.Count(line, `"`)
+		if quotes%2 == 0 && (err != nil || strings.HasSuffix(line, "\n")) {
+			return sb.String(), true, err
+		}
+		if err != nil {
+			return sb.String(), false, err
+		}
+	}
+}
+
+// AutoPartitionLines returns the number of lines in each partition, so that each import Lambda
+// finishes a partition in half of its timeout. Each row consumes a write capacity unit per KB,
+// so wide rows are written more slowly than the workerRecordsPerSecond, and rows can't be
+// written faster than they are converted.
+func AutoPartitionLines(s Sample, workerRecordsPerSecond float64, timeout time.Duration) int64 {
+	if s.Rows == 0 {
+		return 0
+	}
+	rowsPerSecond := workerRecordsPerSecond / math.Max(1, math.Ceil(s.RowBytes/1024))
+	if s.ConversionCost > 0 {
+		rowsPerSecond = math.Min(rowsPerSecond, float64(time.Second)/float64(s.ConversionCost))
+	}
+	lines := int64(rowsPerSecond * timeout.Seconds() / 2)
+	if lines < 1 {
+		return 1
+	}
+	return lines
+}
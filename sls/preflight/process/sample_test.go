@@ -0,0 +1,117 @@
+package process
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a-h/ddbimport/sls/state"
+)
+
+func TestSampleRows(t *testing.T) {
+	src := state.Source{Delimiter: ",", NumericFields: []string{"b"}}
+	s, err := SampleRows(strings.NewReader(generate(10)), src, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Rows != 4 {
+		t.Errorf("expected 4 rows, got %d", s.Rows)
+	}
+	if s.RowBytes != 6 {
+		t.Errorf("expected 6 bytes per row, got %v", s.RowBytes)
+	}
+}
+
+func TestSampleRowsMultilineRecords(t *testing.T) {
+	var tests = []struct {
+		name     string
+		input    string
+		maxRows  int64
+		rows     int64
+		rowBytes float64
+	}{
+		{
+			name:     "quoted newlines don't end the record",
+			input:    "a,b\n\"1\n2\",3\n\"4\",\"5\"\"\n6\"\n",
+			maxRows:  4,
+			rows:     2,
+			rowBytes: 10,
+		},
+		{
+			name:     "the sample stops at a record boundary",
+			input:    "a,b\n\"1\n2\",3\n\"4\",\"5\"\"\n6\"\n",
+			maxRows:  1,
+			rows:     1,
+			rowBytes: 8,
+		},
+		{
+			name:     "a partial record at the end is ignored",
+			input:    "a,b\n1,2\n\"3\n4",
+			maxRows:  4,
+			rows:     1,
+			rowBytes: 4,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := SampleRows(strings.NewReader(tt.input), state.Source{Delimiter: ","}, tt.maxRows)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Rows != tt.rows {
+				t.Errorf("expected %d rows, got %d", tt.rows, s.Rows)
+			}
+			if s.RowBytes != tt.rowBytes {
+				t.Errorf("expected %v bytes per row, got %v", tt.rowBytes, s.RowBytes)
+			}
+		})
+	}
+}
+
+func TestSampleRowsHeaderOnly(t *testing.T) {
+	s, err := SampleRows(strings.NewReader("a,b,c\n"), state.Source{Delimiter: ","}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Rows != 0 {
+		t.Errorf("expected no rows, got %d", s.Rows)
+	}
+}
+
+func TestAutoPartitionLines(t *testing.T) {
+	var tests = []struct {
+		name     string
+		sample   Sample
+		expected int64
+	}{
+		{
+			name:     "narrow rows are limited by the write rate",
+			sample:   Sample{Rows: 10, RowBytes: 100, ConversionCost: time.Microsecond},
+			expected: 1000 * 60 / 2,
+		},
+		{
+			name:     "rows over 1KB consume more write capacity",
+			sample:   Sample{Rows: 10, RowBytes: 2500, ConversionCost: time.Microsecond},
+			expected: 10000,
+		},
+		{
+			name:     "expensive rows are limited by the conversion cost",
+			sample:   Sample{Rows: 10, RowBytes: 100, ConversionCost: time.Millisecond * 10},
+			expected: 100 * 60 / 2,
+		},
+		{
+			name:     "no rows",
+			sample:   Sample{},
+			expected: 0,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := AutoPartitionLines(tt.sample, 1000, time.Minute); actual != tt.expected {
+				t.Errorf("expected %d, got %d", tt.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/a-h/ddbimport/log"
+	"github.com/a-h/ddbimport/sls/state"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Handler combines the outputs written to the results bucket by each import Lambda into a single
+// object, and returns a summary of it as the output of the Step Function.
+func Handler(ctx context.Context, req state.ResultsInput) (resp state.Results, err error) {
+	logger := log.Default.With(log.String("execution", req.Execution), log.Any("tags", req.Tags))
+	bucket := os.Getenv(state.ResultsBucketEnv)
+	if bucket == "" {
+		return resp, fmt.Errorf("%s environment variable not set", state.ResultsBucketEnv)
+	}
+	sess, err := session.NewSession()
+	if err != nil {
+		return
+	}
+	svc := s3.New(sess)
+
+	var keys []string
+	err = svc.ListObjectsV2PagesWithContext(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(state.PartitionsPrefix(req.Execution)),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, o := range page.Contents {
+			keys = append(keys, aws.StringValue(o.Key))
+		}
+		return true
+	})
+	if err != nil {
+		logger.Error("failed to list partition outputs", log.Error(err))
+		return
+	}
+	outputs := make([]state.Output, len(keys))
+	for i, key := range keys {
+		if outputs[i], err = getOutput(ctx, svc, bucket, key); err != nil {
+			logger.Error("failed to get partition output", log.String("key", key), log.Error(err))
+			return
+		}
+	}
+
+	resp = state.NewResults(bucket, state.ResultsKey(req.Execution), outputs)
+	body, err := json.Marshal(outputs)
+	if err != nil {
+		return
+	}
+	_, err = svc.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(resp.Bucket),
+		Key:         aws.String(resp.Key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		logger.Error("failed to write results", log.Error(err))
+		return
+	}
+	logger.Info("complete", log.Int("partitions", resp.Partitions),
+		log.Int64("processedCount", resp.ProcessedCount),
+		log.String("key", resp.Key))
+	return resp, nil
+}
+
+func getOutput(ctx context.Context, svc *s3.S3, bucket, key string) (o state.Output, err error) {
+	goo, err := svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return
+	}
+	defer goo.Body.Close()
+	err = json.NewDecoder(goo.Body).Decode(&o)
+	return
+}
+
+func main() {
+	lambda.Start(Handler)
+}
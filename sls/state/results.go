@@ -0,0 +1,76 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ResultsBucketEnv is the environment variable containing the name of the bucket that the
+// Lambdas write the results of an execution to.
+const ResultsBucketEnv = "RESULTS_BUCKET"
+
+// ResultsInput is the input of the results Lambda, which runs after every partition has been
+// imported.
+type ResultsInput struct {
+	// Execution is the name of the Step Function execution.
+	Execution string            `json:"exec"`
+	Tags      map[string]string `json:"tags,omitempty"`
+}
+
+// Results of an execution, returned by the Step Function. The Output of each partition is too
+// large to return once there are thousands of partitions, because Step Functions limits the
+// output to 256KB, so they're written to the Key in the Bucket as a JSON array instead.
+type Results struct {
+	Version        int    `json:"version,omitempty"`
+	Bucket         string `json:"bucket"`
+	Key            string `json:"key"`
+	Partitions     int    `json:"partitions"`
+	ProcessedCount int64  `json:"processedCount"`
+	// DurationMS is the total time spent importing by every import Lambda.
+	DurationMS int64 `json:"durationMs"`
+	// MaxDurationMS is the time taken to import the slowest partition.
+	MaxDurationMS int64 `json:"maxDurationMs"`
+}
+
+// PartitionKey is the key of the Output of the partition starting at the byte offset.
+func PartitionKey(execution string, from int64) string {
+	return fmt.Sprintf("results/%s/partitions/%020d.json", execution, from)
+}
+
+// PartitionsPrefix is the prefix of the keys of the Output of every partition of the execution.
+func PartitionsPrefix(execution string) string {
+	return fmt.Sprintf("results/%s/partitions/", execution)
+}
+
+// ResultsKey is the key of the Outputs of every partition of the execution.
+func ResultsKey(execution string) string {
+	return fmt.Sprintf("results/%s/results.json", execution)
+}
+
+// NewResults summarises the outputs, and sorts them by the start of their range.
+func NewResults(bucket, key string, outputs []Output) (r Results) {
+	sort.Slice(outputs, func(i, j int) bool {
+		return rangeStart(outputs[i]) < rangeStart(outputs[j])
+	})
+	r = Results{
+		Version:    Version,
+		Bucket:     bucket,
+		Key:        key,
+		Partitions: len(outputs),
+	}
+	for _, o := range outputs {
+		r.ProcessedCount += o.ProcessedCount
+		r.DurationMS += o.DurationMS
+		if o.DurationMS > r.MaxDurationMS {
+			r.MaxDurationMS = o.DurationMS
+		}
+	}
+	return r
+}
+
+func rangeStart(o Output) int64 {
+	if len(o.Range) == 0 {
+		return 0
+	}
+	return o.Range[0]
+}
@@ -0,0 +1,41 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewResults(t *testing.T) {
+	outputs := []Output{
+		{ProcessedCount: 10, DurationMS: 300, Range: []int64{200, 300}},
+		{ProcessedCount: 20, DurationMS: 100, Range: []int64{0, 100}},
+		{ProcessedCount: 30, DurationMS: 200, Range: []int64{100, 200}},
+	}
+	actual := NewResults("bucket", ResultsKey("exec"), outputs)
+	expected := Results{
+		Version:        Version,
+		Bucket:         "bucket",
+		Key:            "results/exec/results.json",
+		Partitions:     3,
+		ProcessedCount: 60,
+		DurationMS:     600,
+		MaxDurationMS:  300,
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+	var starts []int64
+	for _, o := range outputs {
+		starts = append(starts, o.Range[0])
+	}
+	if diff := cmp.Diff([]int64{0, 100, 200}, starts); diff != "" {
+		t.Errorf("expected the outputs to be sorted by range: %s", diff)
+	}
+}
+
+func TestPartitionKey(t *testing.T) {
+	if key := PartitionKey("exec", 1024); key != "results/exec/partitions/00000000000000001024.json" {
+		t.Errorf("unexpected key %q", key)
+	}
+}
@@ -1,12 +1,20 @@
 package state
 
-import "time"
+import (
+	"time"
+	"unicode/utf8"
+)
 
 // Input to the ddbimport step function.
 type Input struct {
+	// Version of the Input. Zero is treated as version 1, from before versioning was added.
+	Version       int           `json:"version,omitempty"`
 	Source        Source        `json:"src"`
 	Configuration Configuration `json:"cnf"`
 	Target        Target        `json:"tgt"`
+	// Tags identify the import for cost allocation, e.g. team, ticket and environment. They are
+	// added to the logs of every Lambda.
+	Tags map[string]string `json:"tags,omitempty"`
 }
 
 // State of the ddbimport Step Function. Source and Target must be populated.
@@ -14,16 +22,25 @@ type Input struct {
 type State struct {
 	Input
 	Preflight Preflight `json:"prefl"`
-	// Batches of ranges (from, to).
+	// AthenaResult is populated by the Step Function after running the Source's AthenaQuery.
+	AthenaResult *AthenaResult `json:"athenaResult,omitempty"`
+	// Batches of ranges (from, to, line), where line is the number of lines before the range.
 	Batches [][]int64 `json:"batches"`
 }
 
 // ImportInput is the input to the ddbimport.
 type ImportInput struct {
 	Input
-	// Range of bytes.
+	// Range of bytes (from, to), followed by the number of lines before the range. Step
+	// Functions deployed before the number of lines was added only have the range of bytes.
 	Range   []int64  `json:"range"`
 	Columns []string `json:"cols"`
+	// Execution is the name of the Step Function execution, used to store the Output in the
+	// results bucket.
+	Execution string `json:"exec,omitempty"`
+	// WCUBudget is the write capacity units per second that the import Lambda may use. Zero is
+	// unlimited.
+	WCUBudget float64 `json:"wcu,omitempty"`
 }
 
 // Source of the CSV data to import.
@@ -36,6 +53,56 @@ type Source struct {
 	MapFields     []string `json:"mapFlds"`
 	BinaryFields  []string `json:"binFilds"`
 	Delimiter     string   `json:"delim"`
+	// SampleRate is the probability of each row being imported. Zero imports every row.
+	SampleRate float64 `json:"sample,omitempty"`
+	// SampleEvery imports every nth row of each partition. Zero imports every row.
+	SampleEvery int64 `json:"every,omitempty"`
+	// ExcludedFields are left out of the items, and the RawAttribute.
+	ExcludedFields []string `json:"exclFlds,omitempty"`
+	// AnonymizedFields maps field names to the faker used to anonymize them.
+	AnonymizedFields map[string]string `json:"anonFlds,omitempty"`
+	// AnonymizeSeedParameter is the name of the SSM parameter which holds the secret used to
+	// generate deterministic anonymized values. The secret itself isn't part of the Input,
+	// because the Input is kept in the execution history of the Step Function.
+	AnonymizeSeedParameter string `json:"anonSeedParam,omitempty"`
+	// RawAttribute is the name of an attribute to store the source row in.
+	RawAttribute string `json:"rawAttr,omitempty"`
+	// RowHashAttribute is the name of an attribute to store the hash of the source row in.
+	RowHashAttribute string `json:"rowHashAttr,omitempty"`
+	// SkipRepeatedHeaders treats rows matching the header as new headers.
+	SkipRepeatedHeaders bool `json:"skipRptHdrs,omitempty"`
+	// AthenaQuery is run by the Step Function before the import. If set, the Bucket and Key are
+	// populated by the preflight with the location of the query results.
+	AthenaQuery *AthenaQuery `json:"athena,omitempty"`
+}
+
+// Comma returns the Delimiter as a rune. If the Delimiter is empty, it is a comma.
+func (s Source) Comma() rune {
+	if s.Delimiter == "" {
+		return ','
+	}
+	r, _ := utf8.DecodeRuneInString(s.Delimiter)
+	return r
+}
+
+// AthenaQuery is an Athena query whose CSV results are imported.
+type AthenaQuery struct {
+	Query     string `json:"query"`
+	Database  string `json:"db"`
+	WorkGroup string `json:"workGroup"`
+	// OutputLocation is the S3 URL that the results are written to, e.g. s3://bucket/prefix/.
+	OutputLocation string `json:"output"`
+}
+
+// AthenaResult is the output of the Step Function's Athena StartQueryExecution task.
+type AthenaResult struct {
+	QueryExecution struct {
+		QueryExecutionID    string `json:"QueryExecutionId"`
+		ResultConfiguration struct {
+			// OutputLocation is the S3 URL of the CSV results file.
+			OutputLocation string `json:"OutputLocation"`
+		} `json:"ResultConfiguration"`
+	} `json:"QueryExecution"`
 }
 
 // Configuration of the Step Function.
@@ -45,6 +112,28 @@ type Configuration struct {
 	// LambdaDurationSeconds is the minimum amount of time each Lambda will spend executing tasks.
 	// After exceeding this, the preflight will start again.
 	LambdaDurationSeconds time.Duration `json:"lambdaDurSecs"`
+	// PartitionLines is the target number of lines in each partition of the file allocated to a
+	// worker. Defaults to 100,000. Wide rows take longer to import, so need smaller partitions.
+	PartitionLines int64 `json:"partLines,omitempty"`
+	// MinPartitions is the minimum number of partitions to divide the file into. Partitions are
+	// limited to the size of the file divided by MinPartitions, so that small files are still
+	// imported in parallel. Zero has no minimum.
+	MinPartitions int64 `json:"minParts,omitempty"`
+	// AutoPartition sets the PartitionLines from a sample of the file, so that each import
+	// Lambda finishes its partition well within its timeout.
+	AutoPartition bool `json:"autoPart,omitempty"`
+	// WorkerRecordsPerSecond is the expected write rate of each import Lambda, used by
+	// AutoPartition. Defaults to 3000.
+	WorkerRecordsPerSecond float64 `json:"workerRps,omitempty"`
+	// MaxLineLength is the maximum length of a line of the file in bytes. The preflight fails
+	// on longer lines, and on files which don't start with text. Defaults to
+	// linereader.DefaultMaxLineLength.
+	MaxLineLength int `json:"maxLineLen,omitempty"`
+	// MaxWorkers is the number of import Lambdas that the Step Function runs at once, up to
+	// MaxImportConcurrency. It's reduced by the CLI when the account doesn't have enough Lambda
+	// concurrency, so that partitions aren't throttled. Migrate sets it to MaxImportConcurrency
+	// when it's zero, because the process state reads it.
+	MaxWorkers int `json:"maxWorkers,omitempty"`
 }
 
 // Target DynamoDB table.
@@ -62,7 +151,40 @@ type Preflight struct {
 	Offset int64 `json:"o"`
 	// Continue to carry on working.
 	Continue bool `json:"cnt"`
+	// PartitionBytes is the maximum size of a partition, calculated from the MinPartitions of the
+	// Configuration and the size of the file. Zero is unlimited.
+	PartitionBytes int64 `json:"pb,omitempty"`
+
+	// WCUBudget is the write capacity units per second that each import Lambda may use, so
+	// that together they don't exceed the provisioned capacity of the table. It is set by the
+	// last run of the preflight. Zero is unlimited, e.g. for on-demand tables. It isn't omitted
+	// when empty, because the Step Function passes it to the import Lambdas.
+	WCUBudget float64 `json:"wcu"`
 
 	// Columns is the set of columns in the file.
 	Columns []string `json:"cols"`
 }
+
+// MaxImportConcurrency is the maximum number of import Lambdas that the Step Function runs at
+// once, see Configuration.MaxWorkers.
+const MaxImportConcurrency = 50
+
+// MaxPartitions is the number of partitions that the Step Function can import. Each partition
+// adds 7 events to the execution history, which Step Functions limits to 25,000 events.
+const MaxPartitions = 3500
+
+// WCUBudget divides the provisioned write capacity between the import Lambdas which run at once
+// to import the partitions, of which there are at most workers. Zero workers is
+// MaxImportConcurrency.
+func WCUBudget(wcu int64, partitions, workers int) float64 {
+	if wcu <= 0 || partitions <= 0 {
+		return 0
+	}
+	if workers <= 0 || workers > MaxImportConcurrency {
+		workers = MaxImportConcurrency
+	}
+	if partitions > workers {
+		partitions = workers
+	}
+	return float64(wcu) / float64(partitions)
+}
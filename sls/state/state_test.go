@@ -0,0 +1,24 @@
+package state
+
+import "testing"
+
+func TestWCUBudget(t *testing.T) {
+	tests := []struct {
+		wcu        int64
+		partitions int
+		workers    int
+		expected   float64
+	}{
+		{wcu: 0, partitions: 10, expected: 0},
+		{wcu: 1000, partitions: 0, expected: 0},
+		{wcu: 1000, partitions: 4, expected: 250},
+		{wcu: 1000, partitions: 200, expected: 20},
+		{wcu: 1000, partitions: 200, workers: 10, expected: 100},
+		{wcu: 1000, partitions: 4, workers: 10, expected: 250},
+	}
+	for _, test := range tests {
+		if actual := WCUBudget(test.wcu, test.partitions, test.workers); actual != test.expected {
+			t.Errorf("%d WCU, %d partitions, %d workers: expected %v, got %v", test.wcu, test.partitions, test.workers, test.expected, actual)
+		}
+	}
+}
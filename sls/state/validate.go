@@ -0,0 +1,174 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ValidationError lists every problem found in the input, so that they can all be fixed at once.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid input: " + strings.Join(e.Problems, "; ")
+}
+
+// Validate the Step Function input. The checks are written by hand to match the constraints
+// of schema.json, and TestSchemaConstraints keeps the two in step. Unlike json.Unmarshal,
+// misspelled fields are reported instead of being ignored.
+func Validate(data []byte) (input Input, err error) {
+	var problems []string
+	var raw interface{}
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return input, &ValidationError{Problems: []string{"not valid JSON: " + err.Error()}}
+	}
+	problems = unknownFields("", raw, reflect.TypeOf(input), problems)
+	if err = json.Unmarshal(data, &input); err != nil {
+		var ute *json.UnmarshalTypeError
+		if errors.As(err, &ute) {
+			problems = append(problems, fmt.Sprintf("%s: expected %v, got %s", ute.Field, ute.Type, ute.Value))
+		} else {
+			problems = append(problems, err.Error())
+		}
+		return input, &ValidationError{Problems: problems}
+	}
+	if err = input.Migrate(); err != nil {
+		problems = append(problems, "version: "+err.Error())
+	}
+	problems = append(problems, input.problems()...)
+	if len(problems) > 0 {
+		return input, &ValidationError{Problems: problems}
+	}
+	return input, nil
+}
+
+// problems checks the values of the input.
+func (input Input) problems() (problems []string) {
+	require := func(ok bool, format string, a ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, a...))
+		}
+	}
+	src, cnf, tgt := input.Source, input.Configuration, input.Target
+	require(src.Region != "", "src.region: required")
+	if src.AthenaQuery == nil {
+		require(src.Bucket != "", "src.bucket: required unless src.athena is set")
+		require(src.Key != "", "src.key: required unless src.athena is set")
+	} else {
+		require(src.AthenaQuery.Query != "", "src.athena.query: required")
+		require(strings.HasPrefix(src.AthenaQuery.OutputLocation, "s3://"), "src.athena.output: must be an S3 URL, e.g. s3://bucket/prefix/")
+	}
+	require(utf8.RuneCountInString(src.Delimiter) <= 1, "src.delim: must be a single character, got %q", src.Delimiter)
+	require(src.SampleRate >= 0 && src.SampleRate <= 1, "src.sample: must be between 0 and 1, got %v", src.SampleRate)
+	require(src.SampleEvery >= 0, "src.every: must not be negative")
+	require(len(src.AnonymizedFields) == 0 || src.AnonymizeSeedParameter != "", "src.anonSeedParam: required when src.anonFlds is set")
+	require(cnf.LambdaConcurrency >= 0, "cnf.lambdaConcur: must not be negative")
+	require(cnf.LambdaDurationSeconds >= 0, "cnf.lambdaDurSecs: must not be negative")
+	require(cnf.PartitionLines >= 0, "cnf.partLines: must not be negative")
+	require(cnf.MinPartitions >= 0, "cnf.minParts: must not be negative")
+	require(cnf.WorkerRecordsPerSecond >= 0, "cnf.workerRps: must not be negative")
+	require(cnf.MaxLineLength >= 0, "cnf.maxLineLen: must not be negative")
+	require(cnf.MaxWorkers >= 0 && cnf.MaxWorkers <= MaxImportConcurrency, "cnf.maxWorkers: must be between 0 and %d, got %d", MaxImportConcurrency, cnf.MaxWorkers)
+	require(tgt.Region != "", "tgt.region: required")
+	require(tgt.TableName != "", "tgt.table: required")
+	return
+}
+
+// unknownFields reports fields of v which don't match the JSON tags of t.
+func unknownFields(path string, v interface{}, t reflect.Type, problems []string) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	obj, isObject := v.(map[string]interface{})
+	if !isObject || t.Kind() != reflect.Struct {
+		return problems
+	}
+	fields := jsonFields(t)
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ft, ok := fields[name]
+		if !ok {
+			problems = append(problems, unknownField(path+name, name, fields))
+			continue
+		}
+		problems = unknownFields(path+name+".", obj[name], ft, problems)
+	}
+	return problems
+}
+
+func unknownField(path, name string, fields map[string]reflect.Type) string {
+	best, bestDistance := "", 3
+	for candidate := range fields {
+		if d := distance(strings.ToLower(name), strings.ToLower(candidate)); d < bestDistance || (d == bestDistance && candidate < best) {
+			best, bestDistance = candidate, d
+		}
+	}
+	if best != "" {
+		return fmt.Sprintf("%s: unknown field, did you mean %q?", path, best)
+	}
+	return fmt.Sprintf("%s: unknown field", path)
+}
+
+// jsonFields returns the JSON field names of the struct, including those of embedded structs.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if f.Anonymous && name == "" {
+			for k, v := range jsonFields(f.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		if name == "-" || f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// distance is the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,247 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidate(t *testing.T) {
+	var tests = []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:  "valid",
+			input: `{"src":{"region":"eu-west-2","bucket":"b","key":"k","numFlds":["year"],"delim":"\t"},"cnf":{"lambdaConcur":8},"tgt":{"region":"eu-west-2","table":"t"}}`,
+		},
+		{
+			name:  "delimiters can be any single character",
+			input: `{"src":{"region":"eu-west-2","bucket":"b","key":"k","delim":"¦"},"tgt":{"region":"eu-west-2","table":"t"}}`,
+		},
+		{
+			name:  "athena queries don't need a bucket and key",
+			input: `{"src":{"region":"eu-west-2","athena":{"query":"select 1","output":"s3://b/p/"}},"tgt":{"region":"eu-west-2","table":"t"}}`,
+		},
+		{
+			name:  "misspelled fields are reported",
+			input: `{"src":{"region":"eu-west-2","bucket":"b","key":"k","numFields":["year"],"delimiter":","},"tgt":{"region":"eu-west-2","table":"t","extra":1}}`,
+			expected: []string{
+				`src.delimiter: unknown field`,
+				`src.numFields: unknown field, did you mean "numFlds"?`,
+				`tgt.extra: unknown field`,
+			},
+		},
+		{
+			name:  "missing and invalid values",
+			input: `{"src":{"region":"","delim":"||","sample":2},"cnf":{"minParts":-1},"tgt":{}}`,
+			expected: []string{
+				"src.region: required",
+				"src.bucket: required unless src.athena is set",
+				"src.key: required unless src.athena is set",
+				`src.delim: must be a single character, got "||"`,
+				"src.sample: must be between 0 and 1, got 2",
+				"cnf.minParts: must not be negative",
+				"tgt.region: required",
+				"tgt.table: required",
+			},
+		},
+		{
+			name:     "anonymized fields need the seed parameter",
+			input:    `{"src":{"region":"eu-west-2","bucket":"b","key":"k","anonFlds":{"email":"email"}},"tgt":{"region":"eu-west-2","table":"t"}}`,
+			expected: []string{"src.anonSeedParam: required when src.anonFlds is set"},
+		},
+		{
+			name:     "wrong types",
+			input:    `{"src":{"region":"eu-west-2","bucket":"b","key":"k"},"cnf":{"lambdaConcur":"8"},"tgt":{"region":"eu-west-2","table":"t"}}`,
+			expected: []string{"cnf.lambdaConcur: expected int, got string"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			// The inputs are written by this version of ddbimport.
+			input := strings.Replace(tt.input, "{", fmt.Sprintf(`{"version":%d,`, Version), 1)
+			_, err := Validate([]byte(input))
+			var actual []string
+			var ve *ValidationError
+			if errors.As(err, &ve) {
+				actual = ve.Problems
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestSourceComma(t *testing.T) {
+	for delimiter, expected := range map[string]rune{"": ',', ",": ',', "\t": '\t', "|": '|', "¦": '¦', "\x01": '\x01'} {
+		if actual := (Source{Delimiter: delimiter}).Comma(); actual != expected {
+			t.Errorf("%q: expected %q, got %q", delimiter, expected, actual)
+		}
+	}
+}
+
+// TestSchemaMatchesInput checks that schema.json is updated when fields are added to the Input.
+func TestSchemaMatchesInput(t *testing.T) {
+	data, err := ioutil.ReadFile("schema.json")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	var schema map[string]interface{}
+	if err = json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+	compareSchema(t, "", schema, reflect.TypeOf(Input{}))
+}
+
+// TestSchemaConstraints checks that Validate enforces the constraints of schema.json, by
+// breaking each of them in turn in an otherwise valid input. Values on the boundary of the
+// minimum, maximum, minLength and maxLength constraints must still be valid.
+func TestSchemaConstraints(t *testing.T) {
+	data, err := ioutil.ReadFile("schema.json")
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+	var schema map[string]interface{}
+	if err = json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+	valid := fmt.Sprintf(`{"version":%d,"src":{"region":"eu-west-2","bucket":"b","key":"k","athena":{"query":"select 1","output":"s3://b/p/"}},"tgt":{"region":"eu-west-2","table":"t"}}`, Version)
+	if _, err = Validate([]byte(valid)); err != nil {
+		t.Fatalf("expected the base input to be valid, got %v", err)
+	}
+	check := func(name string, path []string, value interface{}, remove, expectProblem bool) {
+		var input map[string]interface{}
+		if err := json.Unmarshal([]byte(valid), &input); err != nil {
+			t.Fatalf("failed to parse the base input: %v", err)
+		}
+		parent := input
+		for _, p := range path[:len(path)-1] {
+			if _, ok := parent[p]; !ok {
+				parent[p] = map[string]interface{}{}
+			}
+			parent = parent[p].(map[string]interface{})
+		}
+		if remove {
+			delete(parent, path[len(path)-1])
+		} else {
+			parent[path[len(path)-1]] = value
+		}
+		data, err := json.Marshal(input)
+		if err != nil {
+			t.Fatalf("failed to marshal input: %v", err)
+		}
+		var problems []string
+		_, err = Validate(data)
+		var ve *ValidationError
+		if errors.As(err, &ve) {
+			problems = ve.Problems
+		} else if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		prefix := strings.Join(path, ".")
+		var found bool
+		for _, p := range problems {
+			if strings.HasPrefix(p, prefix+":") || strings.HasPrefix(p, prefix+".") {
+				found = true
+			}
+		}
+		if expectProblem && !found {
+			t.Errorf("%s %s: expected a problem, got %v", prefix, name, problems)
+		}
+		if !expectProblem && found {
+			t.Errorf("%s %s: unexpected problems %v", prefix, name, problems)
+		}
+	}
+	var walk func(path []string, schema map[string]interface{})
+	walk = func(path []string, schema map[string]interface{}) {
+		required, _ := schema["required"].([]interface{})
+		for _, name := range required {
+			check("required", append(append([]string{}, path...), name.(string)), nil, true, true)
+		}
+		properties, _ := schema["properties"].(map[string]interface{})
+		for name, p := range properties {
+			property := p.(map[string]interface{})
+			fieldPath := append(append([]string{}, path...), name)
+			// The version is checked by Migrate, which only accepts recent versions.
+			boundary := name != "version"
+			if min, ok := property["minimum"].(float64); ok {
+				check("minimum", fieldPath, min-1, false, true)
+				if boundary {
+					check("minimum boundary", fieldPath, min, false, false)
+				}
+			}
+			if max, ok := property["maximum"].(float64); ok {
+				check("maximum", fieldPath, max+1, false, true)
+				if boundary {
+					check("maximum boundary", fieldPath, max, false, false)
+				}
+			}
+			if min, ok := property["minLength"].(float64); ok {
+				check("minLength", fieldPath, strings.Repeat("a", int(min)-1), false, true)
+				check("minLength boundary", fieldPath, strings.Repeat("a", int(min)), false, false)
+			}
+			if max, ok := property["maxLength"].(float64); ok {
+				check("maxLength", fieldPath, strings.Repeat("a", int(max)+1), false, true)
+				check("maxLength boundary", fieldPath, strings.Repeat("a", int(max)), false, false)
+			}
+			if pattern, ok := property["pattern"].(string); ok {
+				if regexp.MustCompile(pattern).MatchString("invalid") {
+					t.Fatalf("%s: pattern %q matches the invalid value", strings.Join(fieldPath, "."), pattern)
+				}
+				check("pattern", fieldPath, "invalid", false, true)
+			}
+			if enum, ok := property["enum"].([]interface{}); ok {
+				check("enum", fieldPath, "not-in-the-enum", false, true)
+				for _, v := range enum {
+					check(fmt.Sprintf("enum value %v", v), fieldPath, v, false, false)
+				}
+			}
+			if property["type"] == "object" {
+				walk(fieldPath, property)
+			}
+		}
+	}
+	walk(nil, schema)
+}
+
+func compareSchema(t *testing.T, path string, schema map[string]interface{}, typ reflect.Type) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	fields := jsonFields(typ)
+	var schemaNames, fieldNames []string
+	for name := range properties {
+		schemaNames = append(schemaNames, name)
+	}
+	for name := range fields {
+		fieldNames = append(fieldNames, name)
+	}
+	sort.Strings(schemaNames)
+	sort.Strings(fieldNames)
+	if diff := cmp.Diff(fieldNames, schemaNames); diff != "" {
+		t.Errorf("%s: schema properties do not match the struct fields: %s", path, diff)
+	}
+	for name, ft := range fields {
+		if p, ok := properties[name].(map[string]interface{}); ok {
+			compareSchema(t, path+name+".", p, ft)
+		}
+	}
+}
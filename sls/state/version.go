@@ -0,0 +1,94 @@
+package state
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Version of the Input written by this version of ddbimport. It is incremented when a change to
+// the Input can't be safely ignored by an older Step Function, e.g. a field which changes which
+// rows are imported. It is also incremented when the Results returned by the Step Function, or
+// the ImportInput of each item of its Map state, change, because the CLI and the Lambdas are
+// deployed separately. TestOutputSchema fails until the change is acknowledged there.
+const Version = 8
+
+// MinVersion is the oldest Input version that can be migrated to the current Version. It's
+// also deployed as a tag, so it is raised when older versions of ddbimport can't read the
+// output of the Step Function.
+const MinVersion = 8
+
+// Tags on the deployed state machine, which tell the CLI which versions it accepts.
+const (
+	VersionTag    = "ddbimportVersion"
+	MinVersionTag = "ddbimportMinVersion"
+)
+
+// ErrIncompatibleVersion is returned when the Input can't be used by this version of ddbimport.
+type ErrIncompatibleVersion struct {
+	Version int
+}
+
+func (e ErrIncompatibleVersion) Error() string {
+	if e.Version > Version {
+		return fmt.Sprintf("input version %d is newer than the latest supported version %d, upgrade the Step Function by running ddbimport -install with the same version of ddbimport", e.Version, Version)
+	}
+	return fmt.Sprintf("input version %d is older than the oldest supported version %d, upgrade ddbimport", e.Version, MinVersion)
+}
+
+// Migrate the Input to the current Version. Inputs without a version were created before
+// versioning was added, and are version 1.
+func (input *Input) Migrate() error {
+	if input.Version == 0 {
+		input.Version = 1
+	}
+	if input.Version < MinVersion || input.Version > Version {
+		return ErrIncompatibleVersion{Version: input.Version}
+	}
+	// Version 2 added the version field itself, and only optional fields, so version 1 inputs
+	// are unchanged. Version 3 added Tags, which older Step Functions reject as unknown, and
+	// version 4 added the MaxLineLength of the Configuration, for the same reason. Version 5
+	// added MaxWorkers, which the process state reads, so it's set for older inputs. Version 6
+	// added the ExcludedFields of the Source, which older Step Functions reject. Version 7
+	// changed the output of the Step Function from an array of Outputs to Results in S3, which
+	// older versions of ddbimport can't read, so it's also the MinVersion. Version 8 replaced
+	// the anonymize seed with the name of the SSM parameter which holds it, so it's the
+	// MinVersion too. Later migrations go here, in order.
+	if input.Configuration.MaxWorkers == 0 {
+		input.Configuration.MaxWorkers = MaxImportConcurrency
+	}
+	input.Version = Version
+	return nil
+}
+
+// CheckDeployed returns an error if the deployed state machine, with the given tags, can't
+// accept Inputs of the current Version.
+func CheckDeployed(tags map[string]string) error {
+	deployed, deployedMin := 1, 1
+	if v, ok := tags[VersionTag]; ok {
+		var err error
+		if deployed, err = strconv.Atoi(v); err != nil {
+			return fmt.Errorf("invalid %s tag %q: %w", VersionTag, v, err)
+		}
+	}
+	if v, ok := tags[MinVersionTag]; ok {
+		var err error
+		if deployedMin, err = strconv.Atoi(v); err != nil {
+			return fmt.Errorf("invalid %s tag %q: %w", MinVersionTag, v, err)
+		}
+	}
+	if Version > deployed || Version < deployedMin {
+		return fmt.Errorf("the deployed Step Function accepts input versions %d to %d, but this version of ddbimport writes version %d, run ddbimport -install to deploy the Step Function for this version of ddbimport", deployedMin, deployed, Version)
+	}
+	return nil
+}
+
+// Output of each import Lambda. Step Functions deployed before results were written to S3 return
+// them as an array. Later versions write each one to S3, see ResultsKey.
+type Output struct {
+	// Version of the Input that the import Lambda was deployed with.
+	Version        int   `json:"version,omitempty"`
+	ProcessedCount int64 `json:"processedCount"`
+	DurationMS     int64 `json:"durationMs"`
+	// Range of bytes of the partition that was imported.
+	Range []int64 `json:"range,omitempty"`
+}
@@ -0,0 +1,155 @@
+package state
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMigrate(t *testing.T) {
+	var tests = []struct {
+		version     int
+		expectedErr bool
+	}{
+		{version: 0, expectedErr: MinVersion > 1},
+		{version: MinVersion},
+		{version: MinVersion - 1, expectedErr: true},
+		{version: Version},
+		{version: Version + 1, expectedErr: true},
+		{version: -1, expectedErr: true},
+	}
+	for _, tt := range tests {
+		input := Input{Version: tt.version}
+		err := input.Migrate()
+		if tt.expectedErr != (err != nil) {
+			t.Errorf("version %d: expected error %v, got %v", tt.version, tt.expectedErr, err)
+		}
+		if err == nil && input.Version != Version {
+			t.Errorf("version %d: expected to be migrated to %d, got %d", tt.version, Version, input.Version)
+		}
+		if err == nil && input.Configuration.MaxWorkers != MaxImportConcurrency {
+			t.Errorf("version %d: expected %d workers, got %d", tt.version, MaxImportConcurrency, input.Configuration.MaxWorkers)
+		}
+	}
+}
+
+func TestCheckDeployed(t *testing.T) {
+	var tests = []struct {
+		name        string
+		tags        map[string]string
+		expectedErr bool
+	}{
+		{
+			name:        "deployed before versioning",
+			tags:        map[string]string{},
+			expectedErr: Version > 1,
+		},
+		{
+			name: "same version",
+			tags: map[string]string{VersionTag: fmt.Sprint(Version), MinVersionTag: "1"},
+		},
+		{
+			name: "newer Step Function",
+			tags: map[string]string{VersionTag: fmt.Sprint(Version + 1), MinVersionTag: "1"},
+		},
+		{
+			name:        "newer Step Function which no longer accepts this version",
+			tags:        map[string]string{VersionTag: fmt.Sprint(Version + 2), MinVersionTag: fmt.Sprint(Version + 1)},
+			expectedErr: true,
+		},
+		{
+			name:        "invalid tag",
+			tags:        map[string]string{VersionTag: "two"},
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		if err := CheckDeployed(tt.tags); tt.expectedErr != (err != nil) {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.expectedErr, err)
+		}
+	}
+}
+
+// TestServerlessTags checks that the Step Function is deployed with the current versions.
+func TestServerlessTags(t *testing.T) {
+	data, err := ioutil.ReadFile("../serverless.yml")
+	if err != nil {
+		t.Fatalf("failed to read serverless.yml: %v", err)
+	}
+	for _, expected := range []string{
+		fmt.Sprintf("%s: \"%d\"", VersionTag, Version),
+		fmt.Sprintf("%s: \"%d\"", MinVersionTag, MinVersion),
+	} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("expected serverless.yml to contain %s", expected)
+		}
+	}
+}
+
+// TestOutputSchema pins the JSON fields passed between the Step Function, its Lambdas and the
+// CLI, apart from the Input, which is checked by the schema. If it fails, increment Version,
+// and MinVersion if older versions can't read the change, then update the expected fields.
+func TestOutputSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected []string
+	}{
+		{
+			name:     "Results returned by the Step Function",
+			value:    Results{},
+			expected: []string{"bucket", "durationMs", "key", "maxDurationMs", "partitions", "processedCount", "version"},
+		},
+		{
+			name:     "Output of each partition",
+			value:    Output{},
+			expected: []string{"durationMs", "processedCount", "range", "version"},
+		},
+		{
+			name:     "ResultsInput of the results Lambda",
+			value:    ResultsInput{},
+			expected: []string{"exec", "tags"},
+		},
+		{
+			name:     "ImportInput of each item of the Map state",
+			value:    ImportInput{},
+			expected: []string{"cols", "exec", "range", "wcu"},
+		},
+	}
+	for _, tt := range tests {
+		if diff := cmp.Diff(tt.expected, ownFields(tt.value)); diff != "" {
+			t.Errorf("%s: %s", tt.name, diff)
+		}
+	}
+
+	// The Map state's parameters must set every field of the ImportInput.
+	data, err := ioutil.ReadFile("../serverless.yml")
+	if err != nil {
+		t.Fatalf("failed to read serverless.yml: %v", err)
+	}
+	for _, name := range append(ownFields(ImportInput{}), "src", "cnf", "tgt") {
+		if !strings.Contains(string(data), fmt.Sprintf("\"%s.$\":", name)) {
+			t.Errorf("expected the Map state of serverless.yml to set %s", name)
+		}
+	}
+}
+
+// ownFields returns the sorted JSON names of the fields of the struct, excluding embedded
+// structs.
+func ownFields(v interface{}) (names []string) {
+	typ := reflect.TypeOf(v)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Anonymous {
+			continue
+		}
+		names = append(names, strings.Split(f.Tag.Get("json"), ",")[0])
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/a-h/ddbimport/log"
+	"github.com/a-h/ddbimport/sls/state"
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+// Handler validates the input of the Step Function, and returns it migrated to the current
+// version. Problems fail the execution, rather than being silently ignored.
+func Handler(ctx context.Context, req json.RawMessage) (resp state.Input, err error) {
+	if resp, err = state.Validate(req); err != nil {
+		log.Default.Error("invalid input", log.Any("tags", resp.Tags), log.Error(err))
+		return
+	}
+	return resp, nil
+}
+
+func main() {
+	lambda.Start(Handler)
+}
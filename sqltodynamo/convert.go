@@ -0,0 +1,140 @@
+// Package sqltodynamo converts the rows of a SQL query to DynamoDB records.
+package sqltodynamo
+
+import (
+	"database/sql"
+	"io"
+	"strings"
+
+	"github.com/a-h/ddbimport/csvtodynamo"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Converter converts SQL rows to DynamoDB records.
+type Converter struct {
+	rows       *sql.Rows
+	columns    []string
+	attributes []attributeType
+	rowCount   int64
+}
+
+type attributeType int
+
+const (
+	stringAttribute attributeType = iota
+	numberAttribute
+	boolAttribute
+	binaryAttribute
+)
+
+// NewConverter creates a Converter which reads from the rows. The attribute type of each column
+// is chosen from the database type of the column.
+func NewConverter(rows *sql.Rows) (*Converter, error) {
+	types, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, err
+	}
+	c := &Converter{
+		rows:       rows,
+		columns:    make([]string, len(types)),
+		attributes: make([]attributeType, len(types)),
+	}
+	for i, t := range types {
+		c.columns[i] = t.Name()
+		c.attributes[i] = attributeTypeOf(t.DatabaseTypeName())
+	}
+	return c, nil
+}
+
+// attributeTypeOf maps the database type name of a column, as returned by the driver, to the
+// DynamoDB attribute type used to store it. Unrecognised types, including dates and times,
+// are stored as strings.
+func attributeTypeOf(databaseTypeName string) attributeType {
+	name := strings.TrimPrefix(strings.ToUpper(databaseTypeName), "UNSIGNED ")
+	switch name {
+	case "INT", "INT2", "INT4", "INT8", "INTEGER", "TINYINT", "SMALLINT", "MEDIUMINT", "BIGINT",
+		"SERIAL", "BIGSERIAL", "DECIMAL", "NUMERIC", "FLOAT", "FLOAT4", "FLOAT8", "REAL", "DOUBLE",
+		"YEAR":
+		return numberAttribute
+	case "BOOL", "BOOLEAN":
+		return boolAttribute
+	case "BYTEA", "BINARY", "VARBINARY", "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB":
+		return binaryAttribute
+	}
+	return stringAttribute
+}
+
+// Columns returns the column names of the query.
+func (c *Converter) Columns() []string {
+	return c.columns
+}
+
+// ReadBatch reads 25 items from the query.
+func (c *Converter) ReadBatch() (items []map[string]*dynamodb.AttributeValue, read int, err error) {
+	batchSize := 25
+	items = make([]map[string]*dynamodb.AttributeValue, batchSize)
+	for read = 0; read < batchSize; read++ {
+		items[read], err = c.Read()
+		if err != nil {
+			break
+		}
+	}
+	return items[:read], read, err
+}
+
+// Read a single item from the query. NULL values are omitted from the item. io.EOF is returned
+// when there are no more rows.
+func (c *Converter) Read() (item map[string]*dynamodb.AttributeValue, err error) {
+	if !c.rows.Next() {
+		if err = c.rows.Err(); err != nil {
+			return
+		}
+		return nil, io.EOF
+	}
+	c.rowCount++
+	values := make([]interface{}, len(c.columns))
+	for i, t := range c.attributes {
+		if t == binaryAttribute {
+			values[i] = new([]byte)
+			continue
+		}
+		values[i] = new(sql.NullString)
+	}
+	if err = c.rows.Scan(values...); err != nil {
+		return nil, &csvtodynamo.ErrRowConversion{Line: c.rowCount, Cause: err}
+	}
+	item = make(map[string]*dynamodb.AttributeValue, len(c.columns))
+	for i, column := range c.columns {
+		if v := attributeValue(c.attributes[i], values[i]); v != nil {
+			item[column] = v
+		}
+	}
+	return item, nil
+}
+
+func attributeValue(t attributeType, value interface{}) *dynamodb.AttributeValue {
+	if t == binaryAttribute {
+		b := *value.(*[]byte)
+		if b == nil {
+			return nil
+		}
+		return (&dynamodb.AttributeValue{}).SetB(b)
+	}
+	s := value.(*sql.NullString)
+	if !s.Valid {
+		return nil
+	}
+	switch t {
+	case numberAttribute:
+		return (&dynamodb.AttributeValue{}).SetN(s.String)
+	case boolAttribute:
+		return (&dynamodb.AttributeValue{}).SetBOOL(boolValues[strings.ToLower(s.String)])
+	}
+	return (&dynamodb.AttributeValue{}).SetS(s.String)
+}
+
+var boolValues = map[string]bool{
+	"1":    true,
+	"t":    true,
+	"true": true,
+}
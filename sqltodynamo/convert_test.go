@@ -0,0 +1,146 @@
+package sqltodynamo
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestConverter(t *testing.T) {
+	sql.Register("fake", fakeDriver{
+		columns: []fakeColumn{
+			{name: "id", databaseType: "INT4"},
+			{name: "price", databaseType: "NUMERIC"},
+			{name: "active", databaseType: "BOOL"},
+			{name: "data", databaseType: "BYTEA"},
+			{name: "name", databaseType: "VARCHAR"},
+			{name: "qty", databaseType: "UNSIGNED INT"},
+		},
+		rows: [][]driver.Value{
+			{int64(1), []byte("1.50"), true, []byte{1, 2}, "one", int64(3)},
+			{int64(2), nil, false, nil, nil, nil},
+		},
+	})
+	db, err := sql.Open("fake", "")
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	defer db.Close()
+	rows, err := db.Query("select")
+	if err != nil {
+		t.Fatalf("failed to query: %v", err)
+	}
+	defer rows.Close()
+	c, err := NewConverter(rows)
+	if err != nil {
+		t.Fatalf("failed to create converter: %v", err)
+	}
+	if diff := cmp.Diff([]string{"id", "price", "active", "data", "name", "qty"}, c.Columns()); diff != "" {
+		t.Errorf("unexpected columns: %v", diff)
+	}
+	items, read, err := c.ReadBatch()
+	if err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+	if read != 2 {
+		t.Errorf("expected 2 items, got %d", read)
+	}
+	expected := []map[string]*dynamodb.AttributeValue{
+		{
+			"id":     {N: aws.String("1")},
+			"price":  {N: aws.String("1.50")},
+			"active": {BOOL: aws.Bool(true)},
+			"data":   {B: []byte{1, 2}},
+			"name":   {S: aws.String("one")},
+			"qty":    {N: aws.String("3")},
+		},
+		{
+			"id":     {N: aws.String("2")},
+			"active": {BOOL: aws.Bool(false)},
+		},
+	}
+	if diff := cmp.Diff(expected, items); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestAttributeTypeOf(t *testing.T) {
+	var tests = []struct {
+		databaseType string
+		expected     attributeType
+	}{
+		{databaseType: "BIGINT", expected: numberAttribute},
+		{databaseType: "UNSIGNED BIGINT", expected: numberAttribute},
+		{databaseType: "float8", expected: numberAttribute},
+		{databaseType: "DECIMAL", expected: numberAttribute},
+		{databaseType: "BOOLEAN", expected: boolAttribute},
+		{databaseType: "BLOB", expected: binaryAttribute},
+		{databaseType: "TEXT", expected: stringAttribute},
+		{databaseType: "TIMESTAMPTZ", expected: stringAttribute},
+		{databaseType: "", expected: stringAttribute},
+	}
+	for _, test := range tests {
+		if actual := attributeTypeOf(test.databaseType); actual != test.expected {
+			t.Errorf("%q: expected %v, got %v", test.databaseType, test.expected, actual)
+		}
+	}
+}
+
+type fakeColumn struct {
+	name         string
+	databaseType string
+}
+
+type fakeDriver struct {
+	columns []fakeColumn
+	rows    [][]driver.Value
+}
+
+func (d fakeDriver) Open(name string) (driver.Conn, error) { return fakeConn{d}, nil }
+
+type fakeConn struct{ d fakeDriver }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.d}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return nil, driver.ErrSkip }
+
+type fakeStmt struct{ d fakeDriver }
+
+func (s fakeStmt) Close() error                                    { return nil }
+func (s fakeStmt) NumInput() int                                   { return 0 }
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) { return nil, driver.ErrSkip }
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return &fakeRows{d: s.d}, nil
+}
+
+type fakeRows struct {
+	d     fakeDriver
+	index int
+}
+
+func (r *fakeRows) Columns() (names []string) {
+	for _, c := range r.d.columns {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+func (r *fakeRows) ColumnTypeDatabaseTypeName(index int) string {
+	return r.d.columns[index].databaseType
+}
+
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.index >= len(r.d.rows) {
+		return io.EOF
+	}
+	copy(dest, r.d.rows[r.index])
+	r.index++
+	return nil
+}
@@ -0,0 +1,104 @@
+package streamtodynamo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go/service/dynamodbstreams/dynamodbstreamsiface"
+)
+
+// DynamoDBStream reads changes from a DynamoDB Stream. The stream must be configured to include
+// new images.
+type DynamoDBStream struct {
+	client       dynamodbstreamsiface.DynamoDBStreamsAPI
+	streamARN    string
+	iteratorType string
+}
+
+// NewDynamoDBStream creates a DynamoDBStream reader. The iteratorType is
+// dynamodbstreams.ShardIteratorTypeTrimHorizon to read all available records, or
+// dynamodbstreams.ShardIteratorTypeLatest to read only new records.
+func NewDynamoDBStream(client dynamodbstreamsiface.DynamoDBStreamsAPI, streamARN, iteratorType string) *DynamoDBStream {
+	return &DynamoDBStream{
+		client:       client,
+		streamARN:    streamARN,
+		iteratorType: iteratorType,
+	}
+}
+
+// Run reads the stream until the context is cancelled or an error occurs, passing the changes
+// read from each shard to apply.
+func (s *DynamoDBStream) Run(ctx context.Context, apply ApplyFunc, opts Options) error {
+	return run(ctx, s, apply, opts)
+}
+
+func (s *DynamoDBStream) shards() (shards []shard, err error) {
+	var exclusiveStartShardID *string
+	for {
+		dso, err := s.client.DescribeStream(&dynamodbstreams.DescribeStreamInput{
+			StreamArn:             aws.String(s.streamARN),
+			ExclusiveStartShardId: exclusiveStartShardID,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, sh := range dso.StreamDescription.Shards {
+			shards = append(shards, shard{
+				id:     aws.StringValue(sh.ShardId),
+				parent: aws.StringValue(sh.ParentShardId),
+			})
+		}
+		exclusiveStartShardID = dso.StreamDescription.LastEvaluatedShardId
+		if exclusiveStartShardID == nil {
+			return shards, nil
+		}
+	}
+}
+
+func (s *DynamoDBStream) iterator(shardID string) (string, error) {
+	gsio, err := s.client.GetShardIterator(&dynamodbstreams.GetShardIteratorInput{
+		StreamArn:         aws.String(s.streamARN),
+		ShardId:           aws.String(shardID),
+		ShardIteratorType: aws.String(s.iteratorType),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(gsio.ShardIterator), nil
+}
+
+func (s *DynamoDBStream) records(iterator string) (changes []Change, next string, err error) {
+	gro, err := s.client.GetRecords(&dynamodbstreams.GetRecordsInput{
+		ShardIterator: aws.String(iterator),
+	})
+	if err != nil {
+		return
+	}
+	for _, r := range gro.Records {
+		var c Change
+		if c, err = newChange(aws.StringValue(r.EventName), r.Dynamodb.Keys, r.Dynamodb.NewImage); err != nil {
+			return
+		}
+		changes = append(changes, c)
+	}
+	return changes, aws.StringValue(gro.NextShardIterator), nil
+}
+
+func newChange(eventName string, keys, newImage map[string]*dynamodb.AttributeValue) (c Change, err error) {
+	switch eventName {
+	case dynamodbstreams.OperationTypeInsert, dynamodbstreams.OperationTypeModify:
+		if newImage == nil {
+			err = fmt.Errorf("streamtodynamo: %s record has no new image, the stream must include new images", eventName)
+			return
+		}
+		c.Keys, c.Item = keys, newImage
+	case dynamodbstreams.OperationTypeRemove:
+		c.Delete, c.Keys = true, keys
+	default:
+		err = fmt.Errorf("streamtodynamo: unknown event name %q", eventName)
+	}
+	return
+}
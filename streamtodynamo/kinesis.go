@@ -0,0 +1,109 @@
+package streamtodynamo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/kinesis"
+	"github.com/aws/aws-sdk-go/service/kinesis/kinesisiface"
+)
+
+// KinesisStream reads DynamoDB change records from a Kinesis data stream, in the format
+// produced by Kinesis Data Streams for DynamoDB.
+type KinesisStream struct {
+	client       kinesisiface.KinesisAPI
+	streamName   string
+	iteratorType string
+}
+
+// NewKinesisStream creates a KinesisStream reader. The iteratorType is
+// kinesis.ShardIteratorTypeTrimHorizon to read all available records, or
+// kinesis.ShardIteratorTypeLatest to read only new records.
+func NewKinesisStream(client kinesisiface.KinesisAPI, streamName, iteratorType string) *KinesisStream {
+	return &KinesisStream{
+		client:       client,
+		streamName:   streamName,
+		iteratorType: iteratorType,
+	}
+}
+
+// Run reads the stream until the context is cancelled or an error occurs, passing the changes
+// read from each shard to apply.
+func (s *KinesisStream) Run(ctx context.Context, apply ApplyFunc, opts Options) error {
+	return run(ctx, s, apply, opts)
+}
+
+func (s *KinesisStream) shards() (shards []shard, err error) {
+	input := &kinesis.ListShardsInput{
+		StreamName: aws.String(s.streamName),
+	}
+	for {
+		lso, err := s.client.ListShards(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, sh := range lso.Shards {
+			shards = append(shards, shard{
+				id:     aws.StringValue(sh.ShardId),
+				parent: aws.StringValue(sh.ParentShardId),
+			})
+		}
+		if lso.NextToken == nil {
+			return shards, nil
+		}
+		input = &kinesis.ListShardsInput{
+			NextToken: lso.NextToken,
+		}
+	}
+}
+
+func (s *KinesisStream) iterator(shardID string) (string, error) {
+	gsio, err := s.client.GetShardIterator(&kinesis.GetShardIteratorInput{
+		StreamName:        aws.String(s.streamName),
+		ShardId:           aws.String(shardID),
+		ShardIteratorType: aws.String(s.iteratorType),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(gsio.ShardIterator), nil
+}
+
+func (s *KinesisStream) records(iterator string) (changes []Change, next string, err error) {
+	gro, err := s.client.GetRecords(&kinesis.GetRecordsInput{
+		ShardIterator: aws.String(iterator),
+	})
+	if err != nil {
+		return
+	}
+	for _, r := range gro.Records {
+		var c Change
+		if c, err = parseKinesisRecord(r.Data); err != nil {
+			err = fmt.Errorf("%w: sequence number %s", err, aws.StringValue(r.SequenceNumber))
+			return
+		}
+		changes = append(changes, c)
+	}
+	return changes, aws.StringValue(gro.NextShardIterator), nil
+}
+
+// kinesisRecord is the JSON format of DynamoDB change records in Kinesis.
+type kinesisRecord struct {
+	EventName string `json:"eventName"`
+	DynamoDB  struct {
+		Keys     map[string]*dynamodb.AttributeValue `json:"Keys"`
+		NewImage map[string]*dynamodb.AttributeValue `json:"NewImage"`
+	} `json:"dynamodb"`
+}
+
+func parseKinesisRecord(data []byte) (c Change, err error) {
+	var r kinesisRecord
+	if err = json.Unmarshal(data, &r); err != nil {
+		err = fmt.Errorf("streamtodynamo: invalid change record: %w", err)
+		return
+	}
+	return newChange(r.EventName, r.DynamoDB.Keys, r.DynamoDB.NewImage)
+}
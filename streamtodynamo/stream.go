@@ -0,0 +1,219 @@
+// Package streamtodynamo applies change records from a DynamoDB Stream, or a Kinesis stream of
+// DynamoDB change records, to a DynamoDB table.
+package streamtodynamo
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a-h/ddbimport/batchwriter"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Change to an item in the source table.
+type Change struct {
+	// Delete is true if the item was removed from the source table.
+	Delete bool
+	// Keys of the item.
+	Keys map[string]*dynamodb.AttributeValue
+	// Item is the new image of the item. It is nil when Delete is true.
+	Item map[string]*dynamodb.AttributeValue
+}
+
+// ApplyFunc applies the changes read from a shard, in order.
+type ApplyFunc func(shardID string, changes []Change) error
+
+// Writer applies changes to a table using a BatchWriter.
+type Writer struct {
+	bw batchwriter.BatchWriter
+}
+
+// NewWriter creates a Writer.
+func NewWriter(bw batchwriter.BatchWriter) *Writer {
+	return &Writer{
+		bw: bw,
+	}
+}
+
+// Apply the changes in batches of up to 25 items. A batch is written early if it would
+// otherwise contain two changes to the same item, so that changes are applied in order.
+func (w *Writer) Apply(shardID string, changes []Change) error {
+	for _, batch := range batches(changes) {
+		if err := w.bw.WriteRequests(batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func batches(changes []Change) (batches [][]*dynamodb.WriteRequest) {
+	var batch []*dynamodb.WriteRequest
+	keys := map[string]bool{}
+	for _, c := range changes {
+		k := keyOf(c.Keys)
+		if len(batch) == 25 || keys[k] {
+			batches = append(batches, batch)
+			batch = nil
+			keys = map[string]bool{}
+		}
+		keys[k] = true
+		batch = append(batch, writeRequest(c))
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return
+}
+
+func writeRequest(c Change) *dynamodb.WriteRequest {
+	if c.Delete {
+		return &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{Key: c.Keys},
+		}
+	}
+	return &dynamodb.WriteRequest{
+		PutRequest: &dynamodb.PutRequest{Item: c.Item},
+	}
+}
+
+// keyOf returns a string that uniquely identifies the keys.
+func keyOf(keys map[string]*dynamodb.AttributeValue) string {
+	names := make([]string, 0, len(keys))
+	for k := range keys {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var sb strings.Builder
+	for _, k := range names {
+		v := keys[k]
+		sb.WriteString(k)
+		sb.WriteByte(0)
+		sb.WriteString(aws.StringValue(v.S))
+		sb.WriteString(aws.StringValue(v.N))
+		sb.Write(v.B)
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+// shard of a stream.
+type shard struct {
+	id     string
+	parent string
+}
+
+// shardSource is implemented by each type of stream.
+type shardSource interface {
+	// shards returns the shards currently in the stream.
+	shards() ([]shard, error)
+	// iterator returns the starting iterator for the shard.
+	iterator(shardID string) (string, error)
+	// records returns the changes available from the iterator. The next iterator is empty
+	// when the shard has been closed and all of its records have been read.
+	records(iterator string) (changes []Change, next string, err error)
+}
+
+// Options for reading streams.
+type Options struct {
+	// PollInterval is the time to wait before reading from a shard again when no records are
+	// returned.
+	PollInterval time.Duration
+	// DiscoveryInterval is the time between checks for new shards.
+	DiscoveryInterval time.Duration
+}
+
+// DefaultOptions are the Options used if none are provided.
+var DefaultOptions = Options{
+	PollInterval:      time.Second,
+	DiscoveryInterval: time.Second * 30,
+}
+
+// run reads each shard of the source in its own goroutine, until the context is cancelled or
+// an error occurs. A child shard is only read after its parent shard has been read, so that
+// changes to each item are applied in order.
+func run(ctx context.Context, src shardSource, apply ApplyFunc, opts Options) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var m sync.Mutex
+	started := map[string]bool{}
+	done := map[string]bool{}
+	var wg sync.WaitGroup
+	var runErr error
+	var runErrOnce sync.Once
+	fail := func(err error) {
+		runErrOnce.Do(func() { runErr = err })
+		cancel()
+	}
+	for {
+		shards, err := src.shards()
+		if err != nil {
+			fail(err)
+			break
+		}
+		known := make(map[string]bool, len(shards))
+		for _, s := range shards {
+			known[s.id] = true
+		}
+		m.Lock()
+		for _, s := range shards {
+			// Parents that are no longer in the stream have been trimmed, and don't need to be read.
+			if started[s.id] || (s.parent != "" && known[s.parent] && !done[s.parent]) {
+				continue
+			}
+			started[s.id] = true
+			wg.Add(1)
+			go func(id string) {
+				defer wg.Done()
+				if err := readShard(ctx, src, id, apply, opts); err != nil {
+					fail(err)
+					return
+				}
+				m.Lock()
+				done[id] = true
+				m.Unlock()
+			}(s.id)
+		}
+		m.Unlock()
+		select {
+		case <-ctx.Done():
+		case <-time.After(opts.DiscoveryInterval):
+			continue
+		}
+		break
+	}
+	wg.Wait()
+	if runErr != nil {
+		return runErr
+	}
+	return ctx.Err()
+}
+
+func readShard(ctx context.Context, src shardSource, shardID string, apply ApplyFunc, opts Options) error {
+	iterator, err := src.iterator(shardID)
+	if err != nil {
+		return err
+	}
+	for iterator != "" && ctx.Err() == nil {
+		var changes []Change
+		changes, iterator, err = src.records(iterator)
+		if err != nil {
+			return err
+		}
+		if len(changes) > 0 {
+			if err = apply(shardID, changes); err != nil {
+				return err
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(opts.PollInterval):
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package streamtodynamo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/google/go-cmp/cmp"
+)
+
+func key(id string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}}
+}
+
+func TestBatches(t *testing.T) {
+	var changes []Change
+	for i := 0; i < 30; i++ {
+		changes = append(changes, Change{Keys: key(fmt.Sprint(i)), Item: key(fmt.Sprint(i))})
+	}
+	// A change to an item already in the current batch starts a new batch.
+	changes = append(changes, Change{Keys: key("29"), Delete: true})
+
+	var sizes []int
+	for _, b := range batches(changes) {
+		sizes = append(sizes, len(b))
+	}
+	if diff := cmp.Diff([]int{25, 5, 1}, sizes); diff != "" {
+		t.Error(diff)
+	}
+	last := batches(changes)[2][0]
+	if last.DeleteRequest == nil || last.PutRequest != nil {
+		t.Errorf("expected a delete request, got %v", last)
+	}
+}
+
+func TestParseKinesisRecord(t *testing.T) {
+	var tests = []struct {
+		name     string
+		data     string
+		expected Change
+		err      bool
+	}{
+		{
+			name: "inserts are puts",
+			data: `{"eventName":"INSERT","dynamodb":{"Keys":{"id":{"S":"1"}},"NewImage":{"id":{"S":"1"},"n":{"N":"2"}}}}`,
+			expected: Change{
+				Keys: key("1"),
+				Item: map[string]*dynamodb.AttributeValue{"id": {S: aws.String("1")}, "n": {N: aws.String("2")}},
+			},
+		},
+		{
+			name:     "removes are deletes",
+			data:     `{"eventName":"REMOVE","dynamodb":{"Keys":{"id":{"S":"1"}}}}`,
+			expected: Change{Delete: true, Keys: key("1")},
+		},
+		{
+			name: "modify records must have a new image",
+			data: `{"eventName":"MODIFY","dynamodb":{"Keys":{"id":{"S":"1"}}}}`,
+			err:  true,
+		},
+		{
+			name: "invalid JSON is an error",
+			data: `{`,
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := parseKinesisRecord([]byte(tt.data))
+			if (err != nil) != tt.err {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+type fakeSource struct {
+	m     sync.Mutex
+	list  []shard
+	data  map[string][][]Change
+	reads map[string]int
+}
+
+func (fs *fakeSource) shards() ([]shard, error) { return fs.list, nil }
+
+func (fs *fakeSource) iterator(shardID string) (string, error) { return shardID, nil }
+
+func (fs *fakeSource) records(iterator string) (changes []Change, next string, err error) {
+	fs.m.Lock()
+	defer fs.m.Unlock()
+	i := fs.reads[iterator]
+	fs.reads[iterator]++
+	pages := fs.data[iterator]
+	if i >= len(pages) {
+		return nil, "", nil
+	}
+	return pages[i], iterator, nil
+}
+
+func TestRunReadsParentShardsFirst(t *testing.T) {
+	src := &fakeSource{
+		list: []shard{{id: "child", parent: "parent"}, {id: "parent"}, {id: "orphan", parent: "trimmed"}},
+		data: map[string][][]Change{
+			"parent": {{{Keys: key("1"), Item: key("1")}}, {{Keys: key("1"), Delete: true}}},
+			"child":  {{{Keys: key("1"), Item: key("1")}}},
+			"orphan": {{{Keys: key("2"), Item: key("2")}}},
+		},
+		reads: map[string]int{},
+	}
+	var m sync.Mutex
+	var order []string
+	apply := func(shardID string, changes []Change) error {
+		m.Lock()
+		defer m.Unlock()
+		order = append(order, shardID)
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancel()
+	err := run(ctx, src, apply, Options{PollInterval: time.Millisecond, DiscoveryInterval: time.Millisecond * 10})
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	var parentOrder []string
+	for _, id := range order {
+		if id != "orphan" {
+			parentOrder = append(parentOrder, id)
+		}
+	}
+	if diff := cmp.Diff([]string{"parent", "parent", "child"}, parentOrder); diff != "" {
+		t.Error(diff)
+	}
+	if len(order) != 4 {
+		t.Errorf("expected 4 applies, got %v", order)
+	}
+}
+
+func TestRunStopsOnError(t *testing.T) {
+	src := &fakeSource{
+		list:  []shard{{id: "a"}},
+		data:  map[string][][]Change{"a": {{{Keys: key("1"), Item: key("1")}}}},
+		reads: map[string]int{},
+	}
+	expected := fmt.Errorf("failed")
+	err := run(context.Background(), src, func(string, []Change) error { return expected }, Options{PollInterval: time.Millisecond, DiscoveryInterval: time.Hour})
+	if err != expected {
+		t.Errorf("expected %v, got %v", expected, err)
+	}
+}
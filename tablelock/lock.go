@@ -0,0 +1,201 @@
+// Package tablelock records the imports running against each DynamoDB table in a metadata
+// table, so that two imports into the same table aren't started by accident.
+package tablelock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/google/uuid"
+)
+
+// DefaultMetadataTable is the name of the metadata table created by ddbimport -install.
+const DefaultMetadataTable = "ddbimport-metadata"
+
+// ErrLocked is returned when another import holds the lock.
+type ErrLocked struct {
+	// Owner of the lock, e.g. the host name and process ID of the other import.
+	Owner string
+	// Started is when the other import took the lock.
+	Started time.Time
+}
+
+func (e ErrLocked) Error() string {
+	return fmt.Sprintf("tablelock: another import (%s) has been running since %s", e.Owner, e.Started.Format(time.RFC3339))
+}
+
+// ErrNoMetadataTable is returned when the metadata table doesn't exist.
+var ErrNoMetadataTable = errors.New("tablelock: metadata table not found")
+
+// ErrAccessDenied is returned when the credentials aren't allowed to write to the metadata
+// table.
+var ErrAccessDenied = errors.New("tablelock: access to the metadata table denied")
+
+// Locker takes locks on tables. Locks are leases which expire unless they are renewed, so a
+// lock held by a process that crashed is released automatically.
+type Locker struct {
+	// Lease is how long a lock is held for without being renewed.
+	Lease time.Duration
+	// Owner is stored with the lock, to identify the import holding it.
+	Owner         string
+	client        dynamodbiface.DynamoDBAPI
+	metadataTable string
+	now           func() time.Time
+}
+
+// New creates a Locker which stores locks in the metadata table. The metadata table has a
+// string partition key named "pk".
+func New(client dynamodbiface.DynamoDBAPI, metadataTable string) *Locker {
+	host, _ := os.Hostname()
+	return &Locker{
+		Lease:         time.Minute * 5,
+		Owner:         fmt.Sprintf("%s/%d/%s", host, os.Getpid(), uuid.New().String()),
+		client:        client,
+		metadataTable: metadataTable,
+		now:           time.Now,
+	}
+}
+
+// Lock is a lock on a table, which is renewed until it is released.
+type Lock struct {
+	l       *Locker
+	key     string
+	started time.Time
+	stop    chan struct{}
+	stopped sync.WaitGroup
+	m       sync.Mutex
+	err     error
+}
+
+func key(region, tableName string) string {
+	return "import/" + region + "/" + tableName
+}
+
+// Lock the table, returning ErrLocked if another import holds the lock.
+func (l *Locker) Lock(region, tableName string) (lock *Lock, err error) {
+	lock = &Lock{
+		l:       l,
+		key:     key(region, tableName),
+		started: l.now(),
+		stop:    make(chan struct{}),
+	}
+	if err = lock.put(true); err != nil {
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return nil, l.holder(lock.key)
+		}
+		if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeResourceNotFoundException {
+			return nil, ErrNoMetadataTable
+		}
+		if errors.As(err, &aerr) && aerr.Code() == "AccessDeniedException" {
+			return nil, ErrAccessDenied
+		}
+		return nil, err
+	}
+	lock.stopped.Add(1)
+	go lock.renew()
+	return lock, nil
+}
+
+// put the lock. The first put fails if the lock is held by another owner, and renewals fail if
+// the lock has been taken by another owner after expiring.
+func (lock *Lock) put(first bool) error {
+	now := lock.l.now()
+	expires := now.Add(lock.l.Lease).Unix()
+	input := &dynamodb.PutItemInput{
+		TableName: aws.String(lock.l.metadataTable),
+		Item: map[string]*dynamodb.AttributeValue{
+			"pk":      {S: aws.String(lock.key)},
+			"owner":   {S: aws.String(lock.l.Owner)},
+			"started": {S: aws.String(lock.started.UTC().Format(time.RFC3339))},
+			"expires": {N: aws.String(strconv.FormatInt(expires, 10))},
+			// ttl removes expired locks from the table.
+			"ttl": {N: aws.String(strconv.FormatInt(expires, 10))},
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(lock.l.Owner)},
+		},
+	}
+	if first {
+		input.ConditionExpression = aws.String("attribute_not_exists(pk) OR #owner = :owner OR expires < :now")
+		input.ExpressionAttributeValues[":now"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(now.Unix(), 10))}
+	} else {
+		input.ConditionExpression = aws.String("attribute_not_exists(pk) OR #owner = :owner")
+	}
+	input.ExpressionAttributeNames = map[string]*string{"#owner": aws.String("owner")}
+	_, err := lock.l.client.PutItem(input)
+	return err
+}
+
+// holder returns an ErrLocked describing the current owner of the lock.
+func (l *Locker) holder(key string) error {
+	gio, err := l.client.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(l.metadataTable),
+		Key:            map[string]*dynamodb.AttributeValue{"pk": {S: aws.String(key)}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("tablelock: table is locked, but failed to get the owner: %w", err)
+	}
+	var e ErrLocked
+	if v, ok := gio.Item["owner"]; ok {
+		e.Owner = aws.StringValue(v.S)
+	}
+	if v, ok := gio.Item["started"]; ok {
+		e.Started, _ = time.Parse(time.RFC3339, aws.StringValue(v.S))
+	}
+	return e
+}
+
+func (lock *Lock) renew() {
+	defer lock.stopped.Done()
+	ticker := time.NewTicker(lock.l.Lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-lock.stop:
+			return
+		case <-ticker.C:
+			if err := lock.put(false); err != nil {
+				lock.m.Lock()
+				lock.err = err
+				lock.m.Unlock()
+			}
+		}
+	}
+}
+
+// Err returns the last error renewing the lock, if any. If the lease expired before it was
+// renewed, another import may have taken the lock.
+func (lock *Lock) Err() error {
+	lock.m.Lock()
+	defer lock.m.Unlock()
+	return lock.err
+}
+
+// Release the lock, if it is still held by this owner.
+func (lock *Lock) Release() error {
+	close(lock.stop)
+	lock.stopped.Wait()
+	_, err := lock.l.client.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName:                 aws.String(lock.l.metadataTable),
+		Key:                       map[string]*dynamodb.AttributeValue{"pk": {S: aws.String(lock.key)}},
+		ConditionExpression:       aws.String("#owner = :owner"),
+		ExpressionAttributeNames:  map[string]*string{"#owner": aws.String("owner")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":owner": {S: aws.String(lock.l.Owner)}},
+	})
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		// The lock expired, and was taken by another import.
+		return nil
+	}
+	return err
+}
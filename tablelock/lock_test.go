@@ -0,0 +1,164 @@
+package tablelock
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// fakeMetadataTable evaluates the lock conditions against items held in memory.
+type fakeMetadataTable struct {
+	dynamodbiface.DynamoDBAPI
+	m     sync.Mutex
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func newFakeMetadataTable() *fakeMetadataTable {
+	return &fakeMetadataTable{items: map[string]map[string]*dynamodb.AttributeValue{}}
+}
+
+var errConditionFailed = awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "condition failed", nil)
+
+func (f *fakeMetadataTable) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	pk := aws.StringValue(input.Item["pk"].S)
+	if existing, ok := f.items[pk]; ok && aws.StringValue(existing["owner"].S) != aws.StringValue(input.ExpressionAttributeValues[":owner"].S) {
+		now, canTakeExpired := input.ExpressionAttributeValues[":now"]
+		if !canTakeExpired {
+			return nil, errConditionFailed
+		}
+		expires, _ := strconv.ParseInt(aws.StringValue(existing["expires"].N), 10, 64)
+		if n, _ := strconv.ParseInt(aws.StringValue(now.N), 10, 64); expires >= n {
+			return nil, errConditionFailed
+		}
+	}
+	f.items[pk] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeMetadataTable) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	return &dynamodb.GetItemOutput{Item: f.items[aws.StringValue(input.Key["pk"].S)]}, nil
+}
+
+func (f *fakeMetadataTable) DeleteItem(input *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	f.m.Lock()
+	defer f.m.Unlock()
+	pk := aws.StringValue(input.Key["pk"].S)
+	if existing, ok := f.items[pk]; ok && aws.StringValue(existing["owner"].S) != aws.StringValue(input.ExpressionAttributeValues[":owner"].S) {
+		return nil, errConditionFailed
+	}
+	delete(f.items, pk)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func newTestLocker(client dynamodbiface.DynamoDBAPI, owner string, now *time.Time) *Locker {
+	l := New(client, "metadata")
+	l.Owner = owner
+	l.now = func() time.Time { return *now }
+	return l
+}
+
+func TestLock(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	client := newFakeMetadataTable()
+	a := newTestLocker(client, "a", &now)
+	b := newTestLocker(client, "b", &now)
+
+	lock, err := a.Lock("eu-west-2", "table")
+	if err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+	// A different table can be locked at the same time.
+	other, err := b.Lock("eu-west-2", "other")
+	if err != nil {
+		t.Fatalf("failed to lock other table: %v", err)
+	}
+	defer other.Release()
+
+	_, err = b.Lock("eu-west-2", "table")
+	var locked ErrLocked
+	if !errors.As(err, &locked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+	if locked.Owner != "a" || !locked.Started.Equal(now) {
+		t.Errorf("unexpected lock holder: %+v", locked)
+	}
+
+	if err = lock.Release(); err != nil {
+		t.Fatalf("failed to release: %v", err)
+	}
+	lock, err = b.Lock("eu-west-2", "table")
+	if err != nil {
+		t.Fatalf("failed to lock after release: %v", err)
+	}
+	lock.Release()
+}
+
+func TestLockExpires(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	client := newFakeMetadataTable()
+	a := newTestLocker(client, "a", &now)
+	b := newTestLocker(client, "b", &now)
+
+	lock, err := a.Lock("eu-west-2", "table")
+	if err != nil {
+		t.Fatalf("failed to lock: %v", err)
+	}
+	// Simulate a crash, where the lock is not renewed.
+	close(lock.stop)
+	lock.stopped.Wait()
+
+	now = now.Add(a.Lease + time.Second)
+	lockB, err := b.Lock("eu-west-2", "table")
+	if err != nil {
+		t.Fatalf("expected expired lock to be taken, got %v", err)
+	}
+	defer lockB.Release()
+
+	// The crashed owner can't renew the lock that was taken.
+	if err = lock.put(false); err != errConditionFailed {
+		t.Errorf("expected renewal to fail, got %v", err)
+	}
+}
+
+func TestLockNoMetadataTable(t *testing.T) {
+	now := time.Now()
+	l := newTestLocker(notFoundClient{}, "a", &now)
+	if _, err := l.Lock("eu-west-2", "table"); err != ErrNoMetadataTable {
+		t.Errorf("expected ErrNoMetadataTable, got %v", err)
+	}
+}
+
+type notFoundClient struct {
+	dynamodbiface.DynamoDBAPI
+}
+
+func (notFoundClient) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	return nil, awserr.New(dynamodb.ErrCodeResourceNotFoundException, "not found", nil)
+}
+
+func TestLockAccessDenied(t *testing.T) {
+	now := time.Now()
+	l := newTestLocker(accessDeniedClient{}, "a", &now)
+	if _, err := l.Lock("eu-west-2", "table"); err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied, got %v", err)
+	}
+}
+
+type accessDeniedClient struct {
+	dynamodbiface.DynamoDBAPI
+}
+
+func (accessDeniedClient) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	return nil, awserr.New("AccessDeniedException", "not authorized to perform: dynamodb:PutItem", nil)
+}
@@ -0,0 +1,85 @@
+// Package transcode converts inputs in other character encodings to UTF-8 before they're parsed,
+// e.g. the UTF-16 exports of SQL Server, or the Windows-1252 files saved by Excel.
+package transcode
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// Auto reads UTF-8, unless the input starts with a UTF-16 byte order mark. Byte order marks are
+// removed.
+const Auto = "auto"
+
+// Encodings are the names of the supported encodings.
+var Encodings = []string{Auto, "utf-8", "utf-16le", "utf-16be", "windows-1252", "latin-1"}
+
+var (
+	utf8BOM    = []byte{0xEF, 0xBB, 0xBF}
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+)
+
+// Valid returns true if the encoding is supported.
+func Valid(name string) bool {
+	_, ok := lookup(name)
+	return ok
+}
+
+// NewReader returns a reader of r which converts the named encoding to UTF-8. A byte order mark
+// at the start of r is removed, and for UTF-16, sets the byte order.
+func NewReader(r io.Reader, name string) (io.Reader, error) {
+	enc, ok := lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("transcode: unsupported encoding %q, use one of %s", name, strings.Join(Encodings, ", "))
+	}
+	if enc != nil {
+		return transform.NewReader(r, enc.NewDecoder()), nil
+	}
+	// UTF-8 is passed through unchanged, apart from the byte order mark.
+	br := bufio.NewReader(r)
+	start, err := br.Peek(len(utf8BOM))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if bytes.HasPrefix(start, utf8BOM) {
+		_, err = br.Discard(len(utf8BOM))
+		return br, err
+	}
+	if normalize(name) == Auto && (bytes.HasPrefix(start, utf16LEBOM) || bytes.HasPrefix(start, utf16BEBOM)) {
+		return transform.NewReader(br, unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder()), nil
+	}
+	return br, nil
+}
+
+// lookup returns the encoding with the name, or nil for UTF-8.
+func lookup(name string) (enc encoding.Encoding, ok bool) {
+	switch normalize(name) {
+	case "", Auto, "utf-8", "utf8":
+		return nil, true
+	case "utf-16le":
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), true
+	case "utf-16be":
+		return unicode.UTF16(unicode.BigEndian, unicode.UseBOM), true
+	case "windows-1252", "cp1252":
+		return charmap.Windows1252, true
+	case "latin-1", "latin1", "iso-8859-1":
+		return charmap.ISO8859_1, true
+	}
+	return nil, false
+}
+
+func normalize(name string) string {
+	if name == "" {
+		return Auto
+	}
+	return strings.ToLower(name)
+}
@@ -0,0 +1,104 @@
+package transcode
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewReader(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		input    []byte
+		expected string
+	}{
+		{
+			name:     "plain UTF-8 is unchanged",
+			encoding: Auto,
+			input:    []byte("id,name\n1,Zoë\n"),
+			expected: "id,name\n1,Zoë\n",
+		},
+		{
+			name:     "UTF-8 byte order marks are removed",
+			encoding: Auto,
+			input:    append([]byte{0xEF, 0xBB, 0xBF}, "id,name\n"...),
+			expected: "id,name\n",
+		},
+		{
+			name:     "UTF-8 byte order marks are removed when the encoding is passed",
+			encoding: "utf-8",
+			input:    append([]byte{0xEF, 0xBB, 0xBF}, "id\n"...),
+			expected: "id\n",
+		},
+		{
+			name:     "invalid UTF-8 is passed through",
+			encoding: Auto,
+			input:    []byte{'a', 0xFF, '\n'},
+			expected: "a\xff\n",
+		},
+		{
+			name:     "UTF-16LE is detected from the byte order mark",
+			encoding: Auto,
+			input:    []byte{0xFF, 0xFE, 'i', 0, 'd', 0, '\n', 0},
+			expected: "id\n",
+		},
+		{
+			name:     "UTF-16BE is detected from the byte order mark",
+			encoding: Auto,
+			input:    []byte{0xFE, 0xFF, 0, 'i', 0, 'd', 0, '\n'},
+			expected: "id\n",
+		},
+		{
+			name:     "UTF-16LE without a byte order mark",
+			encoding: "UTF-16LE",
+			input:    []byte{'i', 0, 'd', 0, 0xEB, 0, '\n', 0},
+			expected: "idë\n",
+		},
+		{
+			name:     "Windows-1252",
+			encoding: "windows-1252",
+			input:    []byte{'c', 'a', 'f', 0xE9, ' ', 0x80, '5', '\n'},
+			expected: "café €5\n",
+		},
+		{
+			name:     "Latin-1",
+			encoding: "latin-1",
+			input:    []byte{'c', 'a', 'f', 0xE9, '\n'},
+			expected: "café\n",
+		},
+		{
+			name:     "empty input",
+			encoding: Auto,
+			input:    nil,
+			expected: "",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(tt.input), tt.encoding)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			actual, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("unexpected error reading: %v", err)
+			}
+			if diff := cmp.Diff(tt.expected, string(actual)); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestNewReaderUnsupportedEncoding(t *testing.T) {
+	if Valid("ebcdic") {
+		t.Error("expected ebcdic to be invalid")
+	}
+	if _, err := NewReader(bytes.NewReader(nil), "ebcdic"); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,175 @@
+// Package wal is a write-ahead log of the batches of records sent to DynamoDB, so that an
+// interrupted import can be resumed without sending the records that were acknowledged again.
+package wal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Range of records [From, To), numbered in the order that they are read from the input.
+type Range struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// Entry in the log.
+type Entry struct {
+	// Op is "send" before a batch is sent, and "ack" after it has been written.
+	Op     string  `json:"op"`
+	Ranges []Range `json:"ranges"`
+}
+
+// Log is a write-ahead log. It is safe for concurrent use.
+type Log struct {
+	m     sync.Mutex
+	f     *os.File
+	w     *bufio.Writer
+	acked []Range
+	// sent are the ranges sent by a previous run, but not acknowledged.
+	sent []Range
+}
+
+// Open the log, reading the entries written by a previous run, if the file exists. An
+// incomplete last entry, written when the process stopped, is truncated, so that new entries
+// start on a new line.
+func Open(name string) (l *Log, err error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return
+	}
+	l = &Log{f: f, w: bufio.NewWriter(f)}
+	var sent []Range
+	// complete is the length of the entries which were read.
+	var complete int64
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		b, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// The last line is incomplete, if it doesn't end with a newline.
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		var e Entry
+		if err = json.Unmarshal(b, &e); err != nil {
+			// The last line may be incomplete, if the process stopped while writing it.
+			if _, err := r.Peek(1); err == io.EOF {
+				break
+			}
+			f.Close()
+			return nil, fmt.Errorf("wal: line %d: %w", line, err)
+		}
+		complete += int64(len(b))
+		switch e.Op {
+		case "ack":
+			l.acked = append(l.acked, e.Ranges...)
+		case "send":
+			sent = append(sent, e.Ranges...)
+		}
+	}
+	if err = f.Truncate(complete); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("wal: failed to truncate the incomplete last line: %w", err)
+	}
+	l.acked = merge(l.acked)
+	for _, r := range merge(sent) {
+		l.sent = append(l.sent, subtract(r, l.acked)...)
+	}
+	return l, nil
+}
+
+// merge sorts the ranges and combines those which overlap or touch.
+func merge(ranges []Range) (merged []Range) {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].From < ranges[j].From })
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r.From <= merged[n-1].To {
+			if r.To > merged[n-1].To {
+				merged[n-1].To = r.To
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return
+}
+
+// subtract returns the parts of r which are not in the sorted, merged ranges.
+func subtract(r Range, ranges []Range) (remaining []Range) {
+	for _, s := range ranges {
+		if s.To <= r.From || s.From >= r.To {
+			continue
+		}
+		if s.From > r.From {
+			remaining = append(remaining, Range{From: r.From, To: s.From})
+		}
+		r.From = s.To
+		if r.From >= r.To {
+			return
+		}
+	}
+	return append(remaining, r)
+}
+
+// Acked returns true if the record was acknowledged by a previous run.
+func (l *Log) Acked(record int64) bool {
+	i := sort.Search(len(l.acked), func(i int) bool { return l.acked[i].To > record })
+	return i < len(l.acked) && l.acked[i].From <= record
+}
+
+// Unacked returns the ranges that a previous run sent, but which were not acknowledged.
+func (l *Log) Unacked() []Range {
+	return l.sent
+}
+
+// Send records that the ranges are about to be sent.
+func (l *Log) Send(ranges []Range) error {
+	return l.append(Entry{Op: "send", Ranges: ranges})
+}
+
+// Ack records that the ranges have been written.
+func (l *Log) Ack(ranges []Range) error {
+	return l.append(Entry{Op: "ack", Ranges: ranges})
+}
+
+func (l *Log) append(e Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	l.m.Lock()
+	defer l.m.Unlock()
+	if _, err = l.w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	// Write each entry to the file, and sync it to disk, so that it survives the process, or
+	// the machine, stopping.
+	if err = l.w.Flush(); err != nil {
+		return err
+	}
+	return l.f.Sync()
+}
+
+// Close the log.
+func (l *Log) Close() error {
+	return l.f.Close()
+}
+
+// Ranges returns the ranges of consecutive record numbers.
+func Ranges(records []int64) (ranges []Range) {
+	for _, r := range records {
+		if n := len(ranges); n > 0 && ranges[n-1].To == r {
+			ranges[n-1].To++
+			continue
+		}
+		ranges = append(ranges, Range{From: r, To: r + 1})
+	}
+	return
+}
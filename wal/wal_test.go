@@ -0,0 +1,111 @@
+package wal
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "import.wal")
+
+	l, err := Open(name)
+	if err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	l.Send([]Range{{From: 0, To: 25}})
+	l.Send([]Range{{From: 25, To: 50}})
+	l.Send([]Range{{From: 50, To: 60}, {From: 70, To: 85}})
+	l.Ack([]Range{{From: 25, To: 50}})
+	l.Ack([]Range{{From: 0, To: 25}})
+	l.Close()
+
+	// Simulate a crash while writing an entry.
+	f, _ := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"op":"ack","ran`)
+	f.Close()
+
+	l, err = Open(name)
+	if err != nil {
+		t.Fatalf("failed to reopen: %v", err)
+	}
+	defer l.Close()
+	var acked []int64
+	for i := int64(0); i < 90; i++ {
+		if l.Acked(i) {
+			acked = append(acked, i)
+		}
+	}
+	if diff := cmp.Diff([]Range{{From: 0, To: 50}}, Ranges(acked)); diff != "" {
+		t.Errorf("acked: %s", diff)
+	}
+	if diff := cmp.Diff([]Range{{From: 50, To: 60}, {From: 70, To: 85}}, l.Unacked()); diff != "" {
+		t.Errorf("unacked: %s", diff)
+	}
+}
+
+func TestLogAppendsAfterIncompleteLine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wal")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "import.wal")
+	if err = ioutil.WriteFile(name, []byte(`{"op":"ack","ranges":[{"from":0,"to":10}]}`+"\n"+`{"op":"ack","ran`), 0644); err != nil {
+		t.Fatalf("failed to write log: %v", err)
+	}
+
+	// Resume twice, so that the entries written after the incomplete line are read back.
+	for _, r := range []Range{{From: 10, To: 20}, {From: 20, To: 30}} {
+		l, err := Open(name)
+		if err != nil {
+			t.Fatalf("failed to open: %v", err)
+		}
+		if err = l.Ack([]Range{r}); err != nil {
+			t.Fatalf("failed to ack: %v", err)
+		}
+		if err = l.Ack([]Range{{From: r.From + 100, To: r.To + 100}}); err != nil {
+			t.Fatalf("failed to ack: %v", err)
+		}
+		l.Close()
+	}
+
+	l, err := Open(name)
+	if err != nil {
+		t.Fatalf("failed to reopen: %v", err)
+	}
+	defer l.Close()
+	var acked []int64
+	for i := int64(0); i < 200; i++ {
+		if l.Acked(i) {
+			acked = append(acked, i)
+		}
+	}
+	if diff := cmp.Diff([]Range{{From: 0, To: 30}, {From: 110, To: 130}}, Ranges(acked)); diff != "" {
+		t.Errorf("acked: %s", diff)
+	}
+}
+
+func TestRanges(t *testing.T) {
+	actual := Ranges([]int64{3, 4, 5, 7, 9, 10})
+	expected := []Range{{From: 3, To: 6}, {From: 7, To: 8}, {From: 9, To: 11}}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	actual := subtract(Range{From: 0, To: 100}, []Range{{From: 10, To: 20}, {From: 50, To: 100}})
+	expected := []Range{{From: 0, To: 10}, {From: 20, To: 50}}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Error(diff)
+	}
+}